@@ -3,16 +3,17 @@ module github.com/LanderTome/numerologyCalculator
 go 1.16
 
 require (
-	github.com/cheggaaa/pb/v3 v3.0.8
+	github.com/antzucaro/matchr v0.0.0-20221106193745-7bed6ef61ef9
 	github.com/deckarep/golang-set v1.7.1
-	github.com/mattn/go-sqlite3 v1.14.6 // indirect
+	github.com/mattn/go-runewidth v0.0.12 // indirect
 	github.com/mozillazg/go-unidecode v0.1.1
 	github.com/olekukonko/tablewriter v0.0.5
+	github.com/rivo/uniseg v0.2.0 // indirect
 	github.com/spkg/bom v1.0.0
 	github.com/xo/dburl v0.3.0
-	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 	gorm.io/driver/mysql v1.0.4
 	gorm.io/driver/postgres v1.0.8
 	gorm.io/driver/sqlite v1.1.4
 	gorm.io/gorm v1.20.12
+	modernc.org/sqlite v1.20.4
 )
@@ -12,7 +12,10 @@
 package numerology
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -42,23 +45,84 @@ type DateNumerology struct {
 
 // Event calculates the numerological number for a given date. Unlike LifePath calculations,
 // Master Numbers are always reduced. This calculation is generally used for events like
-// weddings or other special occasions.
+// weddings or other special occasions. The result is empty when the date cannot be converted to the Calendar.
 func (d DateNumerology) Event() (event NumerologicalResult) {
-	return calculateDate(d.Date, d.MasterNumbers, false)
+	if date, err := d.CalendarDate(); err == nil {
+		event = calculateDate(date, d.MasterNumbers, false)
+	}
+	return event
 }
 
 // LifePath calculates the numerological number for a given date, but stops reducing at
-// Master Numbers. This calculation is generally used with one's date of birth.
+// Master Numbers. This calculation is generally used with one's date of birth. The result is empty when the
+// date cannot be converted to the Calendar.
 func (d DateNumerology) LifePath() (lifePath NumerologicalResult) {
-	return calculateDate(d.Date, d.MasterNumbers, true)
+	if date, err := d.CalendarDate(); err == nil {
+		lifePath = calculateDate(date, d.MasterNumbers, true)
+	}
+	return lifePath
 }
 
-// Search executes a forward looking search of dates to find ones that satisfy given
-// numerological criteria. The argument opts contains the searching criteria. Offset in the
-// output is the offset to be used to get the next batch of results using the same query. If
-// offset is 0 then there are no more results.
+// CalendarDate converts the date to the Calendar of the DateOpts.
+func (d DateNumerology) CalendarDate() (CalendarDate, error) {
+	return ConvertDate(d.Date, d.Calendar)
+}
+
+// PersonalDay calculates the Personal Day number of a date for someone born on the date of the DateNumerology.
+// It is the Personal Year (birth month + birth day + year) plus the month and day of the date. Master Numbers
+// are not reduced, just like LifePath. Both dates are converted to the Calendar, and the result is empty when
+// either of them cannot be.
+func (d DateNumerology) PersonalDay(date time.Time) (personalDay NumerologicalResult) {
+	birth, err := d.CalendarDate()
+	if err != nil {
+		return personalDay
+	}
+	converted, err := ConvertDate(date, d.Calendar)
+	if err != nil {
+		return personalDay
+	}
+	var total int
+	for _, val := range []int{birth.Month, birth.Day, converted.Year, converted.Month, converted.Day} {
+		reduceSteps := reduceNumbers(val, d.MasterNumbers, []int{})
+		personalDay.Breakdown = append(personalDay.Breakdown, Breakdown{
+			Value:        reduceSteps[len(reduceSteps)-1],
+			ReduceSteps:  reduceSteps,
+			LetterValues: letterValuesFromNumber(val, d.MasterNumbers),
+		})
+		total += reduceSteps[len(reduceSteps)-1]
+	}
+	personalDay.ReduceSteps = reduceNumbers(total, d.MasterNumbers, []int{})
+	personalDay.Value = personalDay.ReduceSteps[len(personalDay.ReduceSteps)-1]
+	personalDay.Date = &converted
+	return personalDay
+}
+
+// Search executes a search of dates to find ones that satisfy given numerological criteria. The
+// argument opts contains the searching criteria. Offset in the output is the offset to be used to
+// get the next batch of results using the same query. If offset is 0 then there are no more results.
 func (d DateNumerology) Search(opts DateSearchOpts) (searchResults []DateNumerology, offset int64) {
-	return dateSearch(d.Date, d.MasterNumbers, &opts)
+	return dateSearch(d.Date, d.DateOpts, &opts)
+}
+
+// CountMatches counts how many dates Search would return for the given criteria across all of its
+// pages. Count and Offset are ignored.
+func (d DateNumerology) CountMatches(opts DateSearchOpts) int64 {
+	return dateSearchCount(d.Date, d.DateOpts, &opts)
+}
+
+// CheckSearch reports criteria that Search is unable to use without running the search. Search does not stop
+// for them, so a typo would otherwise just change the results. ex. A holiday calendar code of uk instead of gb
+// matches no holidays, and a Calendar that does not exist matches no dates. Search also logs a warning to
+// DefaultLogger for each of them.
+func (d DateNumerology) CheckSearch(opts DateSearchOpts) error {
+	if _, err := GetCalendar(d.Calendar); err != nil {
+		return err
+	}
+	_, unknown := newDateFilter(d.DateOpts, &opts)
+	if len(unknown) > 0 {
+		return fmt.Errorf("holiday calendars %v do not exist", strings.Join(unknown, ", "))
+	}
+	return nil
 }
 
 // NewDate is a wrapper to easily create a time.Time variable without entering hour, min, sec, nsec, loc
@@ -84,11 +148,11 @@ func letterValuesFromNumber(n int, masterNumbers []int) (letterValues []letterVa
 }
 
 // calculateDate outputs the numerological result of a date calculation.
-func calculateDate(date time.Time, masterNumbers []int, lifePath bool) (result NumerologicalResult) {
+func calculateDate(date CalendarDate, masterNumbers []int, lifePath bool) (result NumerologicalResult) {
 	var totalValue int
 	var breakdown []Breakdown
 
-	for _, val := range []int{date.Year(), int(date.Month()), date.Day()} {
+	for _, val := range []int{date.Year, date.Month, date.Day} {
 		reduceSteps := reduceNumbers(val, masterNumbers, []int{})
 		calc := Breakdown{
 			Value:        reduceSteps[len(reduceSteps)-1],
@@ -108,6 +172,7 @@ func calculateDate(date time.Time, masterNumbers []int, lifePath bool) (result N
 		Value:       reduceSteps[len(reduceSteps)-1],
 		ReduceSteps: reduceSteps,
 		Breakdown:   breakdown,
+		Date:        &date,
 	}
 }
 
@@ -128,25 +193,206 @@ func Dates(dates []time.Time, masterNumbers []int) (results []DateNumerology) {
 	return results
 }
 
-// dateSearch is the function that does the actual date searching.
-func dateSearch(startDate time.Time, masterNumbers []int, opts *DateSearchOpts) (searchResults []DateNumerology, offset int64) {
-	endDate := startDate.AddDate(0, opts.MonthsForward, 0)
-	for d := startDate.Add(time.Duration(24*opts.Offset) * time.Hour); d.Before(endDate); d = d.AddDate(0, 0, 1) {
-		if len(opts.Dow) > 0 && !inIntSlice(int(d.Weekday()), opts.Dow) {
+// karmicDebtNumbers are the numbers that are considered to carry a debt from a past life when they show up while
+// reducing a number.
+var karmicDebtNumbers = []int{13, 14, 16, 19}
+
+// hasKarmicDebt checks whether a Karmic Debt number is in any step of the reduction of a date or of its components.
+func hasKarmicDebt(calc NumerologicalResult) bool {
+	steps := append([]int{}, calc.ReduceSteps...)
+	for _, b := range calc.Breakdown {
+		steps = append(steps, b.ReduceSteps...)
+	}
+	for _, step := range steps {
+		if inIntSlice(step, karmicDebtNumbers) {
+			return true
+		}
+	}
+	return false
+}
+
+// dateFilter checks dates against the filters of a search. The holidays are kept between dates so that they are
+// only calculated once for each year.
+type dateFilter struct {
+	dateOpts *DateOpts
+	opts     *DateSearchOpts
+	include  *holidaySet
+	exclude  *holidaySet
+}
+
+// newDateFilter creates the filter of a search. Unknown is the holiday calendar codes of the criteria that do
+// not have a calendar.
+func newDateFilter(dateOpts *DateOpts, opts *DateSearchOpts) (filter dateFilter, unknown []string) {
+	include, unknownInclude := newHolidaySet(opts.IncludeHolidays)
+	exclude, unknownExclude := newHolidaySet(opts.ExcludeHolidays)
+	return dateFilter{dateOpts, opts, include, exclude}, append(unknownInclude, unknownExclude...)
+}
+
+// newSearchFilter creates the filter of a search and warns about the criteria that CheckSearch would report.
+func newSearchFilter(dateOpts *DateOpts, opts *DateSearchOpts) dateFilter {
+	if _, err := GetCalendar(dateOpts.Calendar); err != nil {
+		logTo(nil, LogWarning, "Calendar does not exist and no dates can be converted to it", Fields{"calendar": dateOpts.Calendar})
+	}
+	filter, unknown := newDateFilter(dateOpts, opts)
+	for _, code := range unknown {
+		logTo(nil, LogWarning, "Holiday calendar does not exist and has no holidays", Fields{"code": code})
+	}
+	return filter
+}
+
+// matches checks a date against the filters of a search. Days and months are checked in the calendar of the
+// DateOpts, and dates that cannot be converted to it never match.
+func (f dateFilter) matches(d time.Time) bool {
+	opts := f.opts
+	if len(opts.Dow) > 0 && !inIntSlice(int(d.Weekday()), opts.Dow) {
+		return false
+	}
+	date, err := ConvertDate(d, f.dateOpts.Calendar)
+	if err != nil {
+		return false
+	}
+	if len(opts.Days) > 0 && !inIntSlice(date.Day, opts.Days) {
+		return false
+	}
+	if len(opts.Months) > 0 && !inIntSlice(date.Month, opts.Months) {
+		return false
+	}
+	for _, blackout := range opts.Blackouts {
+		if blackout.contains(d) {
+			return false
+		}
+	}
+	if len(opts.IncludeHolidays) > 0 && !f.include.contains(d) {
+		return false
+	}
+	if len(opts.ExcludeHolidays) > 0 && f.exclude.contains(d) {
+		return false
+	}
+	calc := calculateDate(date, f.dateOpts.MasterNumbers, opts.LifePath)
+	if opts.AvoidKarmicDebt && hasKarmicDebt(calc) {
+		return false
+	}
+	return matchesNumbers(calc.ReduceSteps, opts.Match)
+}
+
+// DateRange is a range of dates from Start to End. Both dates are inclusive and only their year, month and day
+// are used.
+type DateRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// day returns the date at midday UTC, just like NewDate, so that days can be compared and counted.
+func day(t time.Time) time.Time {
+	return NewDate(t.Year(), int(t.Month()), t.Day())
+}
+
+// contains checks whether a date is in the range. A range with a zero End is the single day of its Start.
+func (r DateRange) contains(d time.Time) bool {
+	end := r.End
+	if end.IsZero() {
+		end = r.Start
+	}
+	d = day(d)
+	return !d.Before(day(r.Start)) && !d.After(day(end))
+}
+
+// days is the number of days in the range.
+func (r DateRange) days() int {
+	return int(day(r.End).Sub(day(r.Start)).Hours()/24) + 1
+}
+
+// dateWindows returns the ranges of dates that a search covers in the order that they are searched. Windows that
+// overlap or touch are merged, and empty ones are dropped.
+func dateWindows(startDate time.Time, opts *DateSearchOpts) (windows []DateRange) {
+	ranges := opts.Windows
+	if len(ranges) == 0 {
+		start := startDate
+		if !opts.Start.IsZero() {
+			start = opts.Start
+		}
+		end := opts.End
+		if end.IsZero() {
+			end = day(start).AddDate(0, opts.MonthsForward, -1)
+		}
+		ranges = []DateRange{{start, end}}
+	}
+	sorted := []DateRange{}
+	for _, r := range ranges {
+		if r = (DateRange{day(r.Start), day(r.End)}); !r.End.Before(r.Start) {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+	for _, r := range sorted {
+		if last := len(windows) - 1; last >= 0 && !r.Start.After(windows[last].End.AddDate(0, 0, 1)) {
+			if r.End.After(windows[last].End) {
+				windows[last].End = r.End
+			}
 			continue
 		}
-		calc := calculateDate(d, masterNumbers, opts.LifePath)
-		if inIntSlice(calc.Value, opts.Match) || len(opts.Match) == 0 {
-			if len(searchResults) == opts.Count {
-				offset = int64(d.Sub(startDate).Hours() / 24)
-				break
+		windows = append(windows, r)
+	}
+	if opts.Backward {
+		for i, j := 0, len(windows)-1; i < j; i, j = i+1, j-1 {
+			windows[i], windows[j] = windows[j], windows[i]
+		}
+	}
+	return windows
+}
+
+// forEachDate calls fn with every date of the windows in search order, starting after skip days. The position
+// is the number of days that were searched before the date. It stops when fn returns false.
+func forEachDate(windows []DateRange, backward bool, skip int, fn func(d time.Time, position int) bool) {
+	position := 0
+	for _, w := range windows {
+		days := w.days()
+		first := 0
+		if skip > position {
+			first = skip - position
+		}
+		for i := first; i < days; i++ {
+			d := w.Start.AddDate(0, 0, i)
+			if backward {
+				d = w.End.AddDate(0, 0, -i)
+			}
+			if !fn(d, position+i) {
+				return
 			}
-			searchResults = append(searchResults, DateNumerology{
-				Date:           d,
-				DateOpts:       &DateOpts{masterNumbers},
-				DateSearchOpts: opts,
-			})
 		}
+		position += days
 	}
+}
+
+// dateSearch is the function that does the actual date searching.
+func dateSearch(startDate time.Time, dateOpts *DateOpts, opts *DateSearchOpts) (searchResults []DateNumerology, offset int64) {
+	filter := newSearchFilter(dateOpts, opts)
+	forEachDate(dateWindows(startDate, opts), opts.Backward, opts.Offset, func(d time.Time, position int) bool {
+		if !filter.matches(d) {
+			return true
+		}
+		if len(searchResults) == opts.Count {
+			offset = int64(position)
+			return false
+		}
+		searchResults = append(searchResults, DateNumerology{
+			Date:           d,
+			DateOpts:       dateOpts,
+			DateSearchOpts: opts,
+		})
+		return true
+	})
 	return searchResults, offset
 }
+
+// dateSearchCount counts the dates that a search would return across all of its pages.
+func dateSearchCount(startDate time.Time, dateOpts *DateOpts, opts *DateSearchOpts) (count int64) {
+	filter := newSearchFilter(dateOpts, opts)
+	forEachDate(dateWindows(startDate, opts), opts.Backward, 0, func(d time.Time, position int) bool {
+		if filter.matches(d) {
+			count++
+		}
+		return true
+	})
+	return count
+}
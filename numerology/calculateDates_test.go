@@ -31,7 +31,7 @@ func TestCalculateDate(t *testing.T) {
 		wantResult DateNumerology
 	}{
 		{"DateNumerology", args{NewDate(2021, 1, 1), []int{11, 22, 33}},
-			DateNumerology{NewDate(2021, 1, 1), &DateOpts{[]int{11, 22, 33}}, nil},
+			DateNumerology{NewDate(2021, 1, 1), &DateOpts{MasterNumbers: []int{11, 22, 33}}, nil},
 		},
 	}
 	for _, tt := range tests {
@@ -54,7 +54,7 @@ func TestCalculateDates(t *testing.T) {
 		wantResults []DateNumerology
 	}{
 		{"DatesNumerology", args{[]time.Time{NewDate(2021, 2, 1)}, []int{11, 22}},
-			[]DateNumerology{{NewDate(2021, 2, 1), &DateOpts{[]int{11, 22}}, nil}},
+			[]DateNumerology{{NewDate(2021, 2, 1), &DateOpts{MasterNumbers: []int{11, 22}}, nil}},
 		},
 	}
 	for _, tt := range tests {
@@ -77,9 +77,9 @@ func TestDateNumerology_Event(t *testing.T) {
 		fields     fields
 		wantResult int
 	}{
-		{"1970-01-01", fields{NewDate(1970, 1, 1), &DateOpts{[]int{11, 22, 33}}, nil},
+		{"1970-01-01", fields{NewDate(1970, 1, 1), &DateOpts{MasterNumbers: []int{11, 22, 33}}, nil},
 			1},
-		{"1970-01-02", fields{NewDate(1970, 1, 2), &DateOpts{[]int{11, 22, 33}}, nil},
+		{"1970-01-02", fields{NewDate(1970, 1, 2), &DateOpts{MasterNumbers: []int{11, 22, 33}}, nil},
 			2},
 	}
 	for _, tt := range tests {
@@ -107,11 +107,11 @@ func TestDateNumerology_LifePath(t *testing.T) {
 		fields     fields
 		wantResult int
 	}{
-		{"2010-05-04", fields{NewDate(2010, 5, 4), &DateOpts{[]int{11, 22, 33}}, nil},
+		{"2010-05-04", fields{NewDate(2010, 5, 4), &DateOpts{MasterNumbers: []int{11, 22, 33}}, nil},
 			3},
-		{"1970-01-02", fields{NewDate(1970, 1, 2), &DateOpts{[]int{11, 22, 33}}, nil},
+		{"1970-01-02", fields{NewDate(1970, 1, 2), &DateOpts{MasterNumbers: []int{11, 22, 33}}, nil},
 			11},
-		{"1993-11-11", fields{NewDate(1993, 11, 11), &DateOpts{[]int{11, 22, 33, 44}}, nil},
+		{"1993-11-11", fields{NewDate(1993, 11, 11), &DateOpts{MasterNumbers: []int{11, 22, 33, 44}}, nil},
 			44},
 	}
 	for _, tt := range tests {
@@ -145,7 +145,7 @@ func TestDateNumerology_Search(t *testing.T) {
 		wantOffset int64
 	}{
 		{"1970-01-02",
-			fields{NewDate(1970, 1, 1), &DateOpts{[]int{11, 22, 33}}, nil},
+			fields{NewDate(1970, 1, 1), &DateOpts{MasterNumbers: []int{11, 22, 33}}, nil},
 			args{DateSearchOpts{
 				Count:         100,
 				Offset:        0,
@@ -174,6 +174,120 @@ func TestDateNumerology_Search(t *testing.T) {
 	}
 }
 
+func Test_dateSearchFilters(t *testing.T) {
+	days := func(results []DateNumerology) (days []string) {
+		for _, r := range results {
+			days = append(days, r.Date.Format("01-02"))
+		}
+		return days
+	}
+	tests := []struct {
+		name string
+		opts DateSearchOpts
+		want []string
+	}{
+		// January 5th, 14th and 23rd of 2021 reduce 11, 2.
+		{"Include", DateSearchOpts{Match: []int{2}}, []string{"01-05", "01-14", "01-23"}},
+		{"IncludeIntermediate", DateSearchOpts{Match: []int{11}}, []string{"01-05", "01-14", "01-23"}},
+		{"ExcludeIntermediate", DateSearchOpts{Match: []int{2, -11}}, nil},
+		{"Exclude", DateSearchOpts{Match: []int{-1, -2, -3, -4, -5, -6, -7, -8}}, []string{"01-03", "01-12", "01-21", "01-30"}},
+		{"Days", DateSearchOpts{Days: []int{1, 15}}, []string{"01-01", "01-15"}},
+		{"Months", DateSearchOpts{MonthsForward: 12, Months: []int{2, 3}, Days: []int{1}}, []string{"02-01", "03-01"}},
+		// The 7th, 16th and 25th reduce through 13 and the 13th is Karmic Debt itself.
+		{"AvoidKarmicDebt", DateSearchOpts{AvoidKarmicDebt: true, Match: []int{4, 5, 1}},
+			[]string{"01-04", "01-22", "01-31"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Count = 100
+			if opts.MonthsForward == 0 {
+				opts.MonthsForward = 1
+			}
+			got, _ := Date(NewDate(2021, 1, 1), []int{11, 22, 33}).Search(opts)
+			if !reflect.DeepEqual(days(got), tt.want) {
+				t.Errorf("Search() = %v, want %v", days(got), tt.want)
+			}
+		})
+	}
+}
+
+func Test_dateWindows(t *testing.T) {
+	r := func(start, end string) DateRange {
+		parse := func(s string) time.Time {
+			d, _ := time.Parse("2006-01-02", s)
+			return day(d)
+		}
+		return DateRange{parse(start), parse(end)}
+	}
+	tests := []struct {
+		name string
+		opts DateSearchOpts
+		want []DateRange
+	}{
+		{"MonthsForward", DateSearchOpts{MonthsForward: 2}, []DateRange{r("2021-01-01", "2021-02-28")}},
+		{"StartEnd", DateSearchOpts{Start: NewDate(2020, 12, 25), End: NewDate(2021, 1, 3)}, []DateRange{r("2020-12-25", "2021-01-03")}},
+		{"Merged", DateSearchOpts{Windows: []DateRange{r("2021-03-01", "2021-03-10"), r("2021-01-01", "2021-01-05"), r("2021-01-06", "2021-01-09"), r("2021-03-05", "2021-03-07")}},
+			[]DateRange{r("2021-01-01", "2021-01-09"), r("2021-03-01", "2021-03-10")}},
+		{"Empty", DateSearchOpts{Windows: []DateRange{r("2021-03-01", "2021-02-01")}}, nil},
+		{"Backward", DateSearchOpts{Backward: true, Windows: []DateRange{r("2021-01-01", "2021-01-05"), r("2021-03-01", "2021-03-10")}},
+			[]DateRange{r("2021-03-01", "2021-03-10"), r("2021-01-01", "2021-01-05")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dateWindows(NewDate(2021, 1, 1), &tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dateWindows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_dateSearchRanges(t *testing.T) {
+	date := Date(NewDate(2021, 1, 1), []int{11, 22, 33})
+	windows := []DateRange{{NewDate(2021, 1, 1), NewDate(2021, 1, 31)}, {NewDate(2021, 6, 1), NewDate(2021, 6, 30)}}
+
+	// Backward search returns the latest dates first.
+	results, _ := date.Search(DateSearchOpts{Count: 3, Match: []int{2}, Start: NewDate(2020, 1, 1), End: NewDate(2020, 12, 31), Backward: true})
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Date.Format("2006-01-02"))
+	}
+	if want := []string{"2020-12-31", "2020-12-22", "2020-12-13"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Search() backward = %v, want %v", got, want)
+	}
+
+	// Paging through several windows returns every match once, and the total is the number of them.
+	for _, backward := range []bool{false, true} {
+		opts := DateSearchOpts{Count: 2, Match: []int{3}, Windows: windows, Backward: backward}
+		var all []time.Time
+		for {
+			page, offset := date.Search(opts)
+			all = append(all, func() (dates []time.Time) {
+				for _, r := range page {
+					dates = append(dates, r.Date)
+				}
+				return dates
+			}()...)
+			if offset == 0 {
+				break
+			}
+			opts.Offset = int(offset)
+		}
+		if total := date.CountMatches(opts); total != int64(len(all)) || total == 0 {
+			t.Errorf("CountMatches() = %v, want %v", total, len(all))
+		}
+		for i, d := range all {
+			if d.Month() != time.January && d.Month() != time.June {
+				t.Errorf("Search() = %v, want dates in the windows", d)
+			}
+			if i > 0 && d.After(all[i-1]) == backward {
+				t.Errorf("Search() backward = %v, dates are out of order: %v", backward, all)
+				break
+			}
+		}
+	}
+}
+
 func TestNewDate(t *testing.T) {
 	type args struct {
 		year  int
@@ -22,6 +22,17 @@ type NameNumerology struct {
 	Name string
 	*NameOpts
 	*NameSearchOpts
+	// Dictionary is the dictionary that the name came from when it is the result of a search.
+	Dictionary string
+	// Origin and Meaning describe the searched name when it is the result of a search and its dictionary has name
+	// metadata. See also Variants and Nicknames.
+	Origin  string
+	Meaning string
+	// variants and nicknames are lists of names separated by |.
+	variants  string
+	nicknames string
+	// template is the full name with a ? in place of the searched name.
+	template string
 	mask     *maskStruct
 	counts   *map[int32]int
 	unknowns *unknownCharacters
@@ -103,6 +114,72 @@ func (n NameNumerology) Search(opts NameSearchOpts) (results []NameNumerology, o
 	}
 }
 
+// Explain reports how Search would look for names that satisfy the given criteria, including the generated SQL
+// and the values looked up in each column, without executing the search.
+func (n NameNumerology) Explain(opts NameSearchOpts) (explanation NameSearchExplanation, err error) {
+	switch strings.Count(n.Name, "?") {
+	case 0:
+		return NameSearchExplanation{}, errors.New("missing '?' in name")
+	case 1:
+		return nameSearchExplain(n.Name, n.NumberSystem, n.MasterNumbers, n.ReduceWords, opts)
+	default:
+		return NameSearchExplanation{}, errors.New("too many '?' (only able to search for one '?' at a time)")
+	}
+}
+
+// CountMatches counts how many names Search would return for the given criteria across all of its pages,
+// along with the number of names that were searched. It shows how restrictive the criteria are before the names
+// are fetched.
+func (n NameNumerology) CountMatches(opts NameSearchOpts) (count NameSearchCount, err error) {
+	switch strings.Count(n.Name, "?") {
+	case 0:
+		return NameSearchCount{}, errors.New("missing '?' in name")
+	case 1:
+		return nameSearchCount(n.Name, n.NumberSystem, n.MasterNumbers, n.ReduceWords, opts)
+	default:
+		return NameSearchCount{}, errors.New("too many '?' (only able to search for one '?' at a time)")
+	}
+}
+
+// Score calculates how well the name matches the weighted preferences in the search options that returned it.
+// Names that were not returned by a search have no weights so the score is always 0.
+func (n NameNumerology) Score() (score ScoreBreakdown) {
+	if n.NameSearchOpts == nil {
+		return ScoreBreakdown{}
+	}
+	return scoreName(n, n.NameSearchOpts.Weights)
+}
+
+// Variants are the other spellings of the searched name when it is the result of a search and its dictionary has
+// name metadata. ex. Katherine and Kathryn are variants of Catherine
+func (n NameNumerology) Variants() []string {
+	return splitNameList(n.variants)
+}
+
+// Nicknames are the short forms of the searched name when it is the result of a search and its dictionary has
+// name metadata. ex. Bill and Will are nicknames of William
+func (n NameNumerology) Nicknames() []string {
+	return splitNameList(n.nicknames)
+}
+
+// SpellingVariants calculates the other spellings of the searched name in place of it in the full name. Only the
+// spellings whose Full, Vowels, or Consonants numbers are different from the name are returned, because the
+// others are the same numerologically. The variants keep the origin, meaning and nicknames of the name, but have
+// no variants of their own. Names that were not returned by a search have no variants.
+func (n NameNumerology) SpellingVariants() (variants []NameNumerology) {
+	full, vowels, consonants := n.Full().Value, n.Vowels().Value, n.Consonants().Value
+	for _, v := range n.Variants() {
+		variant := n
+		variant.Name = strings.Replace(n.template, "?", ToAscii(v), 1)
+		variant.mask, variant.counts, variant.unknowns = nil, nil, nil
+		variant.variants = ""
+		if variant.Full().Value != full || variant.Vowels().Value != vowels || variant.Consonants().Value != consonants {
+			variants = append(variants, variant)
+		}
+	}
+	return variants
+}
+
 // Counts returns a map of each numerological value and how many times it appears in the name.
 func (n *NameNumerology) Counts() (counts map[int32]int) {
 	if n.counts == nil {
@@ -152,7 +229,7 @@ func Names(names []string, numberSystem NumberSystem, masterNumbers []int, reduc
 
 	for _, n := range names {
 		name := ToAscii(n)
-		results = append(results, NameNumerology{name, &opts, nil, nil, nil, nil})
+		results = append(results, NameNumerology{Name: name, NameOpts: &opts})
 	}
 	return
 }
@@ -316,7 +316,7 @@ func TestNameNumerology_Search(t *testing.T) {
 			HiddenPassions: []int{7, -3},
 			KarmicLessons:  []int{-6},
 			Database:       "sqlite://file::memory:?cache=shared",
-		}}, 3, 0, false},
+		}}, 2, 0, false},
 		{"HiddenPassionsSearch", fields{
 			Name: "John ? Doe",
 			NameOpts: &NameOpts{
@@ -379,7 +379,7 @@ func TestNameNumerology_Search(t *testing.T) {
 			HiddenPassions: []int{4},
 			KarmicLessons:  []int{7, -3},
 			Database:       "sqlite://file::memory:?cache=shared",
-		}}, 25, 10108, false},
+		}}, 25, 4103, false},
 		{"Error John Doe", fields{
 			Name: "John Doe",
 			NameOpts: &NameOpts{
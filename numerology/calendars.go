@@ -0,0 +1,336 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CalendarDate is a date in a calendar. Months are numbered from 1 in the order of the calendar's year.
+// ex. {Calendar: "hebrew", Year: 5784, Month: 1, Day: 15} is 15 Nisan 5784
+type CalendarDate struct {
+	// Calendar is the code of the calendar that the date is in. ex. "hebrew"
+	Calendar string `json:"calendar"`
+	Year     int    `json:"year"`
+	Month    int    `json:"month"`
+	Day      int    `json:"day"`
+
+	// LeapMonth is set for the month that is added in leap years of the Chinese calendar. It has the same number
+	// as the month before it.
+	LeapMonth bool `json:"leap_month,omitempty"`
+}
+
+// Calendar converts dates of the Gregorian calendar to another calendar. The built-in calendars are calculated
+// offline from the rules of each calendar. Custom calendars can be added with RegisterCalendar.
+type Calendar interface {
+	Convert(date time.Time) (CalendarDate, error)
+}
+
+// CalendarFunc is a function that can be used as a Calendar.
+type CalendarFunc func(date time.Time) (CalendarDate, error)
+
+// Convert calls the function.
+func (f CalendarFunc) Convert(date time.Time) (CalendarDate, error) {
+	return f(date)
+}
+
+// GregorianCalendar is the code of the Gregorian calendar. Dates use it when they do not have a calendar.
+const GregorianCalendar = "gregorian"
+
+// calendars are the calendars that dates can be converted to by their lowercase code.
+var calendars = map[string]Calendar{
+	GregorianCalendar: CalendarFunc(gregorianDate),
+	"hebrew":          CalendarFunc(hebrewDate),
+	"islamic":         CalendarFunc(islamicDate),
+	"persian":         CalendarFunc(persianDate),
+	"chinese":         CalendarFunc(chineseDate),
+}
+
+// calendarCode converts the code of a calendar to the lowercase key of calendars. An empty code is the
+// Gregorian calendar.
+func calendarCode(code string) string {
+	key := strings.ToLower(strings.TrimSpace(code))
+	if key == "" {
+		return GregorianCalendar
+	}
+	return key
+}
+
+// RegisterCalendar adds a calendar that dates can be converted to by its code. The code is not case sensitive
+// and replaces a calendar that has the same code, including the built-in ones. Calendars should be registered
+// when the program starts.
+func RegisterCalendar(code string, calendar Calendar) error {
+	if strings.TrimSpace(code) == "" {
+		return errors.New("calendar has no code")
+	}
+	if calendar == nil {
+		return fmt.Errorf("calendar %v is nil", code)
+	}
+	calendars[calendarCode(code)] = calendar
+	return nil
+}
+
+// GetCalendar returns the calendar with the code. ex. "hebrew", "islamic", "persian", "chinese"
+func GetCalendar(code string) (Calendar, error) {
+	calendar, ok := calendars[calendarCode(code)]
+	if !ok {
+		return nil, errors.New("unknown calendar: " + code)
+	}
+	return calendar, nil
+}
+
+// CalendarCodes returns the codes of the calendars that dates can be converted to, in alphabetical order.
+func CalendarCodes() (codes []string) {
+	for code := range calendars {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// ConvertDate converts the year, month and day of a Gregorian date to the calendar with the code. An error is
+// returned when the calendar does not exist or cannot convert the date.
+func ConvertDate(date time.Time, code string) (CalendarDate, error) {
+	calendar, err := GetCalendar(code)
+	if err != nil {
+		return CalendarDate{}, err
+	}
+	converted, err := calendar.Convert(day(date))
+	if err != nil {
+		return CalendarDate{}, err
+	}
+	converted.Calendar = calendarCode(code)
+	return converted, nil
+}
+
+// floorDiv divides rounding towards negative infinity, which the calendar arithmetic depends on.
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// floorMod is the remainder of floorDiv, which always has the sign of b.
+func floorMod(a, b int) int {
+	return a - b*floorDiv(a, b)
+}
+
+// julianDayNumber returns the Julian Day Number of a date, which counts days from 1 January 4713 BC in the
+// Julian calendar. Converters count days with it so that they do not depend on each other.
+func julianDayNumber(date time.Time) int {
+	midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return floorDiv(int(midnight.Unix()), 86400) + 2440588
+}
+
+// rataDie counts days from 1 January 1 in the Gregorian calendar, which is day 1. It is used by the formulas of
+// the Hebrew and Islamic calendars.
+func rataDie(jdn int) int {
+	return jdn - 1721425
+}
+
+func gregorianDate(date time.Time) (CalendarDate, error) {
+	return CalendarDate{Year: date.Year(), Month: int(date.Month()), Day: date.Day()}, nil
+}
+
+// islamicEpoch is 1 Muharram 1 AH (16 July 622 in the Julian calendar) as a rataDie.
+const islamicEpoch = 227015
+
+// islamicNewMonth is the rataDie of the first day of a month of the Islamic calendar.
+func islamicNewMonth(year, month int) int {
+	return 29*(month-1) + floorDiv(6*month-1, 11) + (year-1)*354 + floorDiv(3+11*year, 30) + islamicEpoch
+}
+
+// islamicDate converts a date to the arithmetical (tabular) Islamic calendar, where 11 of every 30 years are leap
+// years. Dates that are set by sighting the new moon can be a day or two apart from it.
+func islamicDate(date time.Time) (CalendarDate, error) {
+	rd := rataDie(julianDayNumber(date))
+	year := floorDiv(30*(rd-islamicEpoch)+10646, 10631)
+	month := floorDiv(11*(rd-islamicNewMonth(year, 1))+330, 325)
+	return CalendarDate{Year: year, Month: month, Day: rd - islamicNewMonth(year, month) + 1}, nil
+}
+
+// hebrewEpoch is 1 Tishri 1 AM (7 October 3761 BC in the Julian calendar) as a rataDie.
+const hebrewEpoch = -1373427
+
+// Months of the Hebrew calendar that are used in its rules.
+const (
+	nisan      = 1
+	iyyar      = 2
+	tammuz     = 4
+	elul       = 6
+	tishri     = 7
+	marheshvan = 8
+	kislev     = 9
+	tevet      = 10
+	adar       = 12
+	adarII     = 13
+)
+
+// hebrewLeapYear checks whether a year has the extra month Adar II. 7 of every 19 years do.
+func hebrewLeapYear(year int) bool {
+	return floorMod(7*year+1, 19) < 7
+}
+
+// hebrewElapsedDays is the number of days from the epoch to the molad (mean new moon) of Tishri of a year,
+// postponed a day when it falls on a Sunday, Wednesday or Friday.
+func hebrewElapsedDays(year int) int {
+	months := floorDiv(235*year-234, 19)
+	parts := 12084 + 13753*months
+	days := 29*months + floorDiv(parts, 25920)
+	if floorMod(3*(days+1), 7) < 3 {
+		days++
+	}
+	return days
+}
+
+// hebrewNewYear is the rataDie of 1 Tishri of a year, after the postponements that keep years to their allowed
+// lengths.
+func hebrewNewYear(year int) int {
+	correction := 0
+	if hebrewElapsedDays(year+1)-hebrewElapsedDays(year) == 356 {
+		correction = 2
+	} else if hebrewElapsedDays(year)-hebrewElapsedDays(year-1) == 382 {
+		correction = 1
+	}
+	return hebrewEpoch + hebrewElapsedDays(year) + correction
+}
+
+// hebrewMonthDays is the number of days in a month of a year.
+func hebrewMonthDays(year, month int) int {
+	yearDays := hebrewNewYear(year+1) - hebrewNewYear(year)
+	switch {
+	case month == iyyar, month == tammuz, month == elul, month == tevet, month == adarII:
+		return 29
+	case month == adar && !hebrewLeapYear(year):
+		return 29
+	case month == marheshvan && yearDays%10 != 5:
+		// Marheshvan only has 30 days in years of 355 or 385 days.
+		return 29
+	case month == kislev && yearDays%10 == 3:
+		// Kislev only has 29 days in years of 353 or 383 days.
+		return 29
+	}
+	return 30
+}
+
+// hebrewDay is the rataDie of a date of the Hebrew calendar. The year starts with Tishri, but months are
+// numbered from Nisan.
+func hebrewDay(year, month, d int) int {
+	rd := hebrewNewYear(year) + d - 1
+	lastMonth := adar
+	if hebrewLeapYear(year) {
+		lastMonth = adarII
+	}
+	if month < tishri {
+		for m := tishri; m <= lastMonth; m++ {
+			rd += hebrewMonthDays(year, m)
+		}
+		for m := nisan; m < month; m++ {
+			rd += hebrewMonthDays(year, m)
+		}
+	} else {
+		for m := tishri; m < month; m++ {
+			rd += hebrewMonthDays(year, m)
+		}
+	}
+	return rd
+}
+
+// hebrewDate converts a date to the Hebrew calendar. Months are numbered from Nisan, as in the Torah, so
+// Tishri, the first month of the year, is 7 and Adar II of leap years is 13.
+func hebrewDate(date time.Time) (CalendarDate, error) {
+	rd := rataDie(julianDayNumber(date))
+	year := floorDiv(98496*(rd-hebrewEpoch), 35975351)
+	for hebrewNewYear(year+1) <= rd {
+		year++
+	}
+	month := nisan
+	if rd < hebrewDay(year, nisan, 1) {
+		month = tishri
+	}
+	for rd > hebrewDay(year, month, hebrewMonthDays(year, month)) {
+		month++
+	}
+	return CalendarDate{Year: year, Month: month, Day: rd - hebrewDay(year, month, 1) + 1}, nil
+}
+
+// persianBreaks are the years of the Solar Hijri calendar where the pattern of its leap years changes. They keep
+// the arithmetic in step with the astronomical calendar, where the year starts on the day of the March equinox
+// in Tehran, from 1 to 3177 AP.
+var persianBreaks = []int{-61, 9, 38, 199, 426, 686, 756, 818, 1111, 1181, 1210, 1635, 2060, 2097, 2192, 2262,
+	2324, 2394, 2456, 3178}
+
+// persianYear returns the day of March that a year of the Solar Hijri calendar starts on, and the number of
+// years since the last leap year, which is 0 in leap years.
+func persianYear(year int) (march int, sinceLeap int, err error) {
+	if year < persianBreaks[0] || year >= persianBreaks[len(persianBreaks)-1] {
+		return 0, 0, fmt.Errorf("persian calendar cannot convert year %v", year)
+	}
+	leaps := -14
+	previous := persianBreaks[0]
+	jump := 0
+	for _, b := range persianBreaks[1:] {
+		jump = b - previous
+		if year < b {
+			break
+		}
+		leaps += jump/33*8 + jump%33/4
+		previous = b
+	}
+	n := year - previous
+	leaps += n/33*8 + (n%33+3)/4
+	if jump%33 == 4 && jump-n == 4 {
+		leaps++
+	}
+	gregorianYear := year + 621
+	gregorianLeaps := gregorianYear/4 - (gregorianYear/100+1)*3/4 - 150
+	march = 20 + leaps - gregorianLeaps
+	if jump-n < 6 {
+		n = n - jump + (jump+4)/33*33
+	}
+	sinceLeap = ((n+1)%33 - 1) % 4
+	if sinceLeap == -1 {
+		sinceLeap = 4
+	}
+	return march, sinceLeap, nil
+}
+
+// persianDate converts a date to the Solar Hijri calendar of Iran and Afghanistan. The first 6 months have 31
+// days, the next 5 have 30, and Esfand has 29 or 30.
+func persianDate(date time.Time) (CalendarDate, error) {
+	year := date.Year() - 621
+	march, sinceLeap, err := persianYear(year)
+	if err != nil {
+		return CalendarDate{}, err
+	}
+	days := julianDayNumber(date) - julianDayNumber(NewDate(date.Year(), 3, march))
+	if days >= 0 && days <= 185 {
+		return CalendarDate{Year: year, Month: 1 + days/31, Day: days%31 + 1}, nil
+	}
+	if days >= 0 {
+		days -= 186
+	} else {
+		// The date is in the last months of the previous year.
+		year--
+		days += 179
+		if sinceLeap == 1 {
+			days++
+		}
+	}
+	return CalendarDate{Year: year, Month: 7 + days/30, Day: days%30 + 1}, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvertDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		date     time.Time
+		calendar string
+		want     CalendarDate
+	}{
+		{"Gregorian", NewDate(2021, 3, 21), "", CalendarDate{"gregorian", 2021, 3, 21, false}},
+		{"Rosh Hashanah", NewDate(2021, 9, 7), "hebrew", CalendarDate{"hebrew", 5782, 7, 1, false}},
+		{"Passover", NewDate(2024, 4, 23), "Hebrew", CalendarDate{"hebrew", 5784, 1, 15, false}},
+		{"Purim in a leap year", NewDate(2024, 3, 24), "hebrew", CalendarDate{"hebrew", 5784, 13, 14, false}},
+		{"Purim", NewDate(2023, 3, 7), "hebrew", CalendarDate{"hebrew", 5783, 12, 14, false}},
+		{"Ramadan", NewDate(2024, 3, 11), "islamic", CalendarDate{"islamic", 1445, 9, 1, false}},
+		{"Islamic end of year", NewDate(2021, 8, 9), "islamic", CalendarDate{"islamic", 1442, 12, 30, false}},
+		{"Nowruz", NewDate(2021, 3, 21), "persian", CalendarDate{"persian", 1400, 1, 1, false}},
+		{"Persian leap day", NewDate(2025, 3, 20), "persian", CalendarDate{"persian", 1403, 12, 30, false}},
+		{"Shahrivar", NewDate(2021, 9, 7), "persian", CalendarDate{"persian", 1400, 6, 16, false}},
+		{"Chinese New Year", NewDate(2021, 2, 12), "chinese", CalendarDate{"chinese", 2021, 1, 1, false}},
+		{"Chinese New Year's Eve", NewDate(2021, 2, 11), "chinese", CalendarDate{"chinese", 2020, 12, 30, false}},
+		{"Chinese leap month", NewDate(2023, 3, 22), "chinese", CalendarDate{"chinese", 2023, 2, 1, true}},
+		{"After a Chinese leap month", NewDate(2023, 4, 20), "chinese", CalendarDate{"chinese", 2023, 3, 1, false}},
+		{"Chinese leap 11th month", NewDate(2033, 12, 22), "chinese", CalendarDate{"chinese", 2033, 11, 1, true}},
+		{"Beijing mean time", NewDate(1900, 1, 31), "chinese", CalendarDate{"chinese", 1900, 1, 1, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertDate(tt.date, tt.calendar)
+			if err != nil {
+				t.Fatalf("ConvertDate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ConvertDate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if _, err := ConvertDate(NewDate(2021, 1, 1), "mayan"); err == nil {
+		t.Errorf("ConvertDate() error = nil, want an error for an unknown calendar")
+	}
+	if _, err := ConvertDate(NewDate(3800, 1, 1), "persian"); err == nil {
+		t.Errorf("ConvertDate() error = nil, want an error for a year out of range")
+	}
+}
+
+func Test_chineseNewYear(t *testing.T) {
+	newYears := []time.Time{
+		NewDate(1985, 2, 20), NewDate(1990, 1, 27), NewDate(1995, 1, 31), NewDate(2000, 2, 5), NewDate(2005, 2, 9),
+		NewDate(2010, 2, 14), NewDate(2015, 2, 19), NewDate(2020, 1, 25), NewDate(2025, 1, 29), NewDate(2030, 2, 3),
+	}
+	for _, newYear := range newYears {
+		got, _ := chineseDate(newYear)
+		if got.Month != 1 || got.Day != 1 || got.LeapMonth || got.Year != newYear.Year() {
+			t.Errorf("chineseDate(%v) = %+v, want the new year", newYear.Format("2006-01-02"), got)
+		}
+	}
+}
+
+func Test_hebrewYearLengths(t *testing.T) {
+	// Every year of the Hebrew calendar has 353, 354, 355, 383, 384 or 385 days, and every date converts back
+	// to itself.
+	for year := 5700; year < 5900; year++ {
+		days := hebrewNewYear(year+1) - hebrewNewYear(year)
+		if !inIntSlice(days, []int{353, 354, 355, 383, 384, 385}) {
+			t.Errorf("year %v has %v days", year, days)
+		}
+	}
+	for d := NewDate(2019, 1, 1); d.Before(NewDate(2025, 1, 1)); d = d.AddDate(0, 0, 1) {
+		h, _ := hebrewDate(d)
+		if rd := hebrewDay(h.Year, h.Month, h.Day); rd != rataDie(julianDayNumber(d)) {
+			t.Fatalf("hebrewDate(%v) = %+v, which is %v days off", d.Format("2006-01-02"), h, rd-rataDie(julianDayNumber(d)))
+		}
+	}
+}
+
+func TestDateNumerology_Calendar(t *testing.T) {
+	d := DateNumerology{Date: NewDate(2024, 4, 23), DateOpts: &DateOpts{MasterNumbers: []int{11, 22, 33}, Calendar: "hebrew"}}
+	// 5784 (24 = 6) + 1 + 15 (6) = 13 = 4
+	event := d.Event()
+	if event.Value != 4 || !reflect.DeepEqual(event.ReduceSteps, []int{13, 4}) {
+		t.Errorf("Event() = %v %v, want 4 [13 4]", event.Value, event.ReduceSteps)
+	}
+	if want := (CalendarDate{"hebrew", 5784, 1, 15, false}); event.Date == nil || *event.Date != want {
+		t.Errorf("Event() date = %+v, want %+v", event.Date, want)
+	}
+	if lifePath := d.LifePath(); lifePath.Value != 4 {
+		t.Errorf("LifePath() = %v, want 4", lifePath.Value)
+	}
+
+	d.Calendar = "persian"
+	d.Date = NewDate(3800, 1, 1)
+	if event := d.Event(); event.Value != 0 || event.Date != nil {
+		t.Errorf("Event() = %+v, want an empty result for a date that cannot be converted", event)
+	}
+}
+
+func Test_dateSearchCalendar(t *testing.T) {
+	d := DateNumerology{Date: NewDate(2020, 1, 1), DateOpts: &DateOpts{MasterNumbers: []int{11, 22, 33}, Calendar: "hebrew"}}
+	// Rosh Hashanah is the 1st of Tishri.
+	opts := DateSearchOpts{Count: 10, End: NewDate(2024, 12, 31), Months: []int{7}, Days: []int{1}}
+	results, _ := d.Search(opts)
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Date.Format("2006-01-02"))
+		if r.Calendar != "hebrew" {
+			t.Errorf("Search() calendar = %q, want hebrew", r.Calendar)
+		}
+	}
+	want := []string{"2020-09-19", "2021-09-07", "2022-09-26", "2023-09-16", "2024-10-03"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Search() = %v, want %v", got, want)
+	}
+	if count := d.CountMatches(opts); count != int64(len(want)) {
+		t.Errorf("CountMatches() = %v, want %v", count, len(want))
+	}
+	if err := d.CheckSearch(opts); err != nil {
+		t.Errorf("CheckSearch() error = %v", err)
+	}
+
+	d.Calendar = "hebrw"
+	if err := d.CheckSearch(opts); err == nil {
+		t.Errorf("CheckSearch() error = nil, want an error for an unknown calendar")
+	}
+	if results, _ := d.Search(opts); len(results) != 0 {
+		t.Errorf("Search() = %v, want no dates for an unknown calendar", results)
+	}
+}
+
+func TestRegisterCalendar(t *testing.T) {
+	// A calendar that counts the days of the year.
+	ordinal := CalendarFunc(func(date time.Time) (CalendarDate, error) {
+		return CalendarDate{Year: date.Year(), Month: 1, Day: date.YearDay()}, nil
+	})
+	if err := RegisterCalendar("Ordinal", ordinal); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(calendars, "ordinal")
+	got, err := ConvertDate(NewDate(2021, 2, 1), "ordinal")
+	if want := (CalendarDate{"ordinal", 2021, 1, 32, false}); err != nil || got != want {
+		t.Errorf("ConvertDate() = %+v, %v, want %+v", got, err, want)
+	}
+	if err := RegisterCalendar(" ", ordinal); err == nil {
+		t.Errorf("RegisterCalendar() error = nil, want an error for an empty code")
+	}
+	want := []string{"chinese", "gregorian", "hebrew", "islamic", "ordinal", "persian"}
+	if got := CalendarCodes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CalendarCodes() = %v, want %v", got, want)
+	}
+}
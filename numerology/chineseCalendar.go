@@ -0,0 +1,163 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"math"
+	"time"
+)
+
+// The Chinese calendar is lunisolar. Each month starts on the day of a new moon in China, and the month that
+// contains the winter solstice is always the 11th. When there are 13 new moons from one 11th month to the next,
+// the first month without a major solar term (a multiple of 30° of the Sun's longitude) is a leap month. The
+// new moons and the longitude of the Sun are calculated with the formulas of Jean Meeus' Astronomical
+// Algorithms, which are accurate to within minutes.
+
+// synodicMonth is the mean number of days from one new moon to the next.
+const synodicMonth = 29.530588861
+
+// sin of an angle in degrees.
+func sin(degrees float64) float64 {
+	return math.Sin(degrees * math.Pi / 180)
+}
+
+// deltaT is the number of days that Terrestrial Time, which the formulas use, is ahead of Universal Time at a
+// Julian Date. It is a long term estimate that is within a minute for the 20th and 21st centuries.
+func deltaT(jd float64) float64 {
+	u := (jd - 2385800.5) / 36525
+	return (-20 + 32*u*u) / 86400
+}
+
+// newMoon is the Julian Date in Universal Time of the kth new moon since January 2000.
+func newMoon(k int) float64 {
+	kf := float64(k)
+	t := kf / 1236.85
+	jde := 2451550.09766 + synodicMonth*kf + 0.00015437*t*t - 0.000000150*t*t*t + 0.00000000073*t*t*t*t
+	e := 1 - 0.002516*t - 0.0000074*t*t
+	m := 2.5534 + 29.10535670*kf - 0.0000014*t*t - 0.00000011*t*t*t
+	mm := 201.5643 + 385.81693528*kf + 0.0107582*t*t + 0.00001238*t*t*t - 0.000000058*t*t*t*t
+	f := 160.7108 + 390.67050284*kf - 0.0016118*t*t - 0.00000227*t*t*t + 0.000000011*t*t*t*t
+	omega := 124.7746 - 1.56375588*kf + 0.0020672*t*t + 0.00000215*t*t*t
+	jde += -0.40720*sin(mm) + 0.17241*e*sin(m) + 0.01608*sin(2*mm) + 0.01039*sin(2*f) +
+		0.00739*e*sin(mm-m) - 0.00514*e*sin(mm+m) + 0.00208*e*e*sin(2*m) - 0.00111*sin(mm-2*f) -
+		0.00057*sin(mm+2*f) + 0.00056*e*sin(2*mm+m) - 0.00042*sin(3*mm) + 0.00042*e*sin(m+2*f) +
+		0.00038*e*sin(m-2*f) - 0.00024*e*sin(2*mm-m) - 0.00017*sin(omega) - 0.00007*sin(mm+2*m) +
+		0.00004*sin(2*mm-2*f) + 0.00004*sin(3*m) + 0.00003*sin(mm+m-2*f) + 0.00003*sin(2*mm+2*f) -
+		0.00003*sin(mm+m+2*f) + 0.00003*sin(mm-m+2*f) - 0.00002*sin(mm-m-2*f) - 0.00002*sin(3*mm+m) +
+		0.00002*sin(4*mm)
+	// Planetary arguments
+	for i, a := range [][3]float64{
+		{299.77, 0.107408, 0.000325}, {251.88, 0.016321, 0.000165}, {251.83, 26.651886, 0.000164},
+		{349.42, 36.412478, 0.000126}, {84.66, 18.206239, 0.000110}, {141.74, 53.303771, 0.000062},
+		{207.14, 2.453732, 0.000060}, {154.84, 7.306860, 0.000056}, {34.52, 27.261239, 0.000047},
+		{207.19, 0.121824, 0.000042}, {291.34, 1.844379, 0.000040}, {161.72, 24.198154, 0.000037},
+		{239.56, 25.513099, 0.000035}, {331.55, 3.592518, 0.000023},
+	} {
+		angle := a[0] + a[1]*kf
+		if i == 0 {
+			angle -= 0.009173 * t * t
+		}
+		jde += a[2] * sin(angle)
+	}
+	return jde - deltaT(jde)
+}
+
+// solarLongitude is the apparent longitude of the Sun in degrees at a Julian Date in Universal Time.
+func solarLongitude(jd float64) float64 {
+	t := (jd + deltaT(jd) - 2451545) / 36525
+	l := 280.46646 + 36000.76983*t + 0.0003032*t*t
+	m := 357.52911 + 35999.05029*t - 0.0001537*t*t
+	c := (1.914602-0.004817*t-0.000014*t*t)*sin(m) + (0.019993-0.000101*t)*sin(2*m) + 0.000289*sin(3*m)
+	omega := 125.04 - 1934.136*t
+	return math.Mod(math.Mod(l+c-0.00569-0.00478*sin(omega), 360)+360, 360)
+}
+
+// chineseOffset is the time zone of the Chinese calendar in days on a Julian Day Number. The mean solar time of
+// Beijing was used until 1929.
+func chineseOffset(jdn int) float64 {
+	if jdn < julianDayNumber(NewDate(1929, 1, 1)) {
+		return (116 + 25.0/60) / 360
+	}
+	return 8.0 / 24
+}
+
+// chineseDay is the Julian Day Number of the day in China of a Julian Date in Universal Time.
+func chineseDay(jd float64) int {
+	day := int(math.Floor(jd + 0.5 + 8.0/24))
+	return int(math.Floor(jd + 0.5 + chineseOffset(day)))
+}
+
+// chineseMidnight is the Julian Date in Universal Time of the start of a day in China.
+func chineseMidnight(jdn int) float64 {
+	return float64(jdn) - 0.5 - chineseOffset(jdn)
+}
+
+// firstNewMoon is the number of the first new moon that is on or after a day in China.
+func firstNewMoon(jdn int) int {
+	k := int(math.Floor((chineseMidnight(jdn)-2451550.09766)/synodicMonth)) - 1
+	for chineseDay(newMoon(k)) < jdn {
+		k++
+	}
+	return k
+}
+
+// majorSolarTerm is the number of the last major solar term before a day started in China.
+func majorSolarTerm(jdn int) int {
+	return int(solarLongitude(chineseMidnight(jdn)) / 30)
+}
+
+// eleventhMonth is the number of the new moon that starts the 11th month, which contains the winter solstice of
+// a year.
+func eleventhMonth(year int) int {
+	solstice := julianDayNumber(NewDate(year, 12, 15))
+	for solarLongitude(chineseMidnight(solstice+1)) < 270 {
+		solstice++
+	}
+	return firstNewMoon(solstice+1) - 1
+}
+
+// chineseDate converts a date to the Chinese calendar. The year is the Gregorian year that the Chinese New Year
+// is in, so the 11th and 12th months are in the year before the Gregorian one.
+func chineseDate(date time.Time) (CalendarDate, error) {
+	jdn := julianDayNumber(date)
+	year := date.Year()
+	first := eleventhMonth(year)
+	if chineseDay(newMoon(first)) > jdn {
+		year--
+		first = eleventhMonth(year)
+	}
+	next := eleventhMonth(year + 1)
+	leapYear := next-first == 13
+
+	result := CalendarDate{Year: year, Month: 11}
+	start := chineseDay(newMoon(first))
+	leapFound := false
+	for k := first + 1; k < next; k++ {
+		monthStart := chineseDay(newMoon(k))
+		if monthStart > jdn {
+			break
+		}
+		if leapYear && !leapFound && majorSolarTerm(monthStart) == majorSolarTerm(chineseDay(newMoon(k+1))) {
+			leapFound = true
+			result.LeapMonth = true
+		} else {
+			result.Month = result.Month%12 + 1
+			result.LeapMonth = false
+			if result.Month == 1 {
+				result.Year = year + 1
+			}
+		}
+		start = monthStart
+	}
+	result.Day = jdn - start + 1
+	return result, nil
+}
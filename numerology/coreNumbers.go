@@ -123,6 +123,10 @@ type NumerologicalResult struct {
 
 	// Breakdown contains each individual Breakdown that contributed to the final calculation.
 	Breakdown []Breakdown `json:"breakdown"`
+
+	// Date is the date that a date calculation was made from, in the calendar that it was converted to. It is nil
+	// for names.
+	Date *CalendarDate `json:"date,omitempty"`
 }
 
 // Debug returns a printable string that offers a simplistic summary of the numerological conversion.
@@ -210,6 +214,8 @@ func maskConstructor(s string) maskStruct {
 	runes := []rune(strings.ToLower(s))
 	for i, letter := range runes {
 		// Special rules for "Y" from https://www.worldnumerology.com/numerology-Y-vowel-consonant.htm
+		// Change vowelPolicy whenever these rules change so that dictionaries calculated with the old rules are
+		// migrated.
 		var m bool
 		if letter == 'y' {
 			switch {
@@ -12,27 +12,21 @@
 package numerology
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"github.com/cheggaaa/pb/v3"
-	"github.com/spkg/bom"
 	"github.com/xo/dburl"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
-	"gorm.io/driver/sqlite" // Requires Cgo when compiling and therefore doesn't work well for some target architectures.
-	// "github.com/cloudquery/sqlite" // Cgo-free version of sqlite. Doesn't yet support all features of native sqlite but works fine here.
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
-	"io/fs"
-	"log"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"sort"
-	"strconv"
-	"strings"
+	"sync"
 )
 
 // DB holds the database connection used of name searches. Gorm is used which means that only SQLite, MySQL,
@@ -46,37 +40,68 @@ var dbLogger = logger.Default.LogMode(logger.Silent)
 //
 // The longest English word 'pneumonoultramicroscopicsilicovolcanoconiosis'
 // maxes out at only 218.
+//
+// There is one row for each name. A name that is used for both genders keeps the weighted count of each.
 type precalculatedNumerology struct {
-	Id                    int64 `gorm:"primaryKey"`
-	Name                  string
-	Gender                string `gorm:"index;type:varchar(1)"`
-	PythagoreanFull       uint8  `gorm:"index"`
-	PythagoreanVowels     uint8  `gorm:"index"`
-	PythagoreanConsonants uint8  `gorm:"index"`
-	ChaldeanFull          uint8  `gorm:"index"`
-	ChaldeanVowels        uint8  `gorm:"index"`
-	ChaldeanConsonants    uint8  `gorm:"index"`
-	P1                    uint8  // Pythagorean count for number 1
-	P2                    uint8  // Pythagorean count for number 2
-	P3                    uint8  // Pythagorean count for number 3
-	P4                    uint8  // Pythagorean count for number 4
-	P5                    uint8  // Pythagorean count for number 5
-	P6                    uint8  // Pythagorean count for number 6
-	P7                    uint8  // Pythagorean count for number 7
-	P8                    uint8  // Pythagorean count for number 8
-	P9                    uint8  // Pythagorean count for number 9
-	C1                    uint8  // Chaldean count for number 1
-	C2                    uint8  // Chaldean count for number 2
-	C3                    uint8  // Chaldean count for number 3
-	C4                    uint8  // Chaldean count for number 4
-	C5                    uint8  // Chaldean count for number 5
-	C6                    uint8  // Chaldean count for number 6
-	C7                    uint8  // Chaldean count for number 7
-	C8                    uint8  // Chaldean count for number 8
+	Id   int64 `gorm:"primaryKey"`
+	Name string
+	// Gender is the gender that the name is most often used for.
+	Gender string `gorm:"index;type:varchar(1)"`
+	// PopularityRank is the popularity of the name in its dictionary. The most popular name is rank 1.
+	// MaleRank and FemaleRank are the popularity among the names used for each gender. They are 0 when the
+	// name has not been used for the gender.
+	PopularityRank int64 `gorm:"index"`
+	MaleRank       int64 `gorm:"index"`
+	FemaleRank     int64 `gorm:"index"`
+	MaleCount      int64
+	FemaleCount    int64
+	// UnisexRatio is the count of the less common gender divided by the count of the more common gender. It is
+	// 1 when a name is used equally for both genders and 0 when it is only used for one.
+	UnisexRatio float64 `gorm:"index"`
+	// Trend is positive for names that have become more popular and negative for names that have become less
+	// popular. See popularityTrend.
+	Trend float64 `gorm:"index"`
+	// Origin, Meaning, Variants and Nicknames come from the name metadata files of the dictionary. Variants and
+	// Nicknames are lists of names separated by |.
+	Origin                string `gorm:"index;type:varchar(64)"`
+	Meaning               string
+	Variants              string
+	Nicknames             string
+	PythagoreanFull       uint8 `gorm:"index"`
+	PythagoreanVowels     uint8 `gorm:"index"`
+	PythagoreanConsonants uint8 `gorm:"index"`
+	ChaldeanFull          uint8 `gorm:"index"`
+	ChaldeanVowels        uint8 `gorm:"index"`
+	ChaldeanConsonants    uint8 `gorm:"index"`
+	P1                    uint8 // Pythagorean count for number 1
+	P2                    uint8 // Pythagorean count for number 2
+	P3                    uint8 // Pythagorean count for number 3
+	P4                    uint8 // Pythagorean count for number 4
+	P5                    uint8 // Pythagorean count for number 5
+	P6                    uint8 // Pythagorean count for number 6
+	P7                    uint8 // Pythagorean count for number 7
+	P8                    uint8 // Pythagorean count for number 8
+	P9                    uint8 // Pythagorean count for number 9
+	C1                    uint8 // Chaldean count for number 1
+	C2                    uint8 // Chaldean count for number 2
+	C3                    uint8 // Chaldean count for number 3
+	C4                    uint8 // Chaldean count for number 4
+	C5                    uint8 // Chaldean count for number 5
+	C6                    uint8 // Chaldean count for number 6
+	C7                    uint8 // Chaldean count for number 7
+	C8                    uint8 // Chaldean count for number 8
+	// Phonetic encodings of the name that are used to find names that sound alike.
+	Soundex            string `gorm:"index;type:varchar(4)"`
+	MetaphonePrimary   string `gorm:"index;type:varchar(4)"`
+	MetaphoneAlternate string `gorm:"index;type:varchar(4)"`
+	// NumberSystems holds the values of the number systems that were added by RegisterNumberSystem by their key.
+	// They are kept in a side table of the dictionary. See numberSystemsTable.
+	NumberSystems map[string]precalculatedSystemNumerology `gorm:"-"`
 }
 
 // connectToDatabase parses a given DSN and establishes a connection to the database using Gorm. Only SQLite,
-// PostgreSQL, and MySQL are currently supported.
+// PostgreSQL, and MySQL are currently supported. A sqlite:// DSN uses the driver chosen by the build tags. A
+// moderncsqlite:// DSN always uses the Cgo-free driver.
 func connectToDatabase(dsn string) error {
 	if DB == nil {
 		u, err := dburl.Parse(dsn)
@@ -85,7 +110,9 @@ func connectToDatabase(dsn string) error {
 		}
 		switch u.OriginalScheme {
 		case "sqlite":
-			DB, err = gorm.Open(sqlite.Open(u.DSN), &gorm.Config{Logger: dbLogger})
+			DB, err = gorm.Open(openSQLite(u.DSN, sqliteDriver), &gorm.Config{Logger: dbLogger})
+		case "moderncsqlite", "modernsqlite":
+			DB, err = gorm.Open(openSQLite(u.DSN, pureGoSQLiteDriver), &gorm.Config{Logger: dbLogger})
 		case "postgres":
 			DB, err = gorm.Open(postgres.Open(u.DSN), &gorm.Config{Logger: dbLogger})
 		case "mysql":
@@ -110,46 +137,60 @@ func setStructField(pcn *precalculatedNumerology, field string, value uint8) {
 // namePopularity is a sortable slice used for ordering names before putting in the database.
 type namePopularity []nameEntry
 
-func (a namePopularity) Len() int           { return len(a) }
-func (a namePopularity) Less(i, j int) bool { return a[i].Popularity < a[j].Popularity }
-func (a namePopularity) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a namePopularity) Len() int { return len(a) }
+func (a namePopularity) Less(i, j int) bool {
+	// Names that are equally popular are kept in alphabetical order so that the ranks are always the same.
+	if a[i].Popularity() == a[j].Popularity() {
+		return a[i].Name > a[j].Name
+	}
+	return a[i].Popularity() < a[j].Popularity()
+}
+func (a namePopularity) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 
 type nameEntry struct {
-	Name       string
-	Gender     uint8
-	Popularity int
+	Name        string
+	MaleCount   int
+	FemaleCount int
+	// Trend is how the popularity of the name has changed. See popularityTrend.
+	Trend float64
 }
 
-// Extract all the names from the CSV files in the directory, merge the names, and create a sorted slice of results.
-func extractNamesFromFiles(directory string) (namePopularity, error) {
-	log.Printf("Extracting names from %v", directory)
-	namePopularityMap := map[uint8]map[string]int{
-		'M': {},
-		'F': {},
-	}
-	files, err := fs.Glob(os.DirFS(directory), "*.csv")
+// Popularity is the weighted count of the name for both genders.
+func (e nameEntry) Popularity() int {
+	return e.MaleCount + e.FemaleCount
+}
+
+// readSourceFiles reads all the source files in the directory, merges the names, and creates a sorted slice of
+// results along with the rows that could not be read. Progress is called after each file when it is not nil.
+func readSourceFiles(directory string, progress func(done int, total int)) (namePopularity, []RejectedRow, error) {
+	files, err := listSourceFiles(directory)
 	if err != nil {
-		return namePopularity{}, errors.New("unable to scan directory")
+		return namePopularity{}, nil, err
 	}
-	totalFiles := len(files)
-	bar := pb.Full.Start(totalFiles)
+	rejects := []RejectedRow{}
+	fileRecords := make([][]sourceRecord, len(files))
 	for i, fn := range files {
-		bar.Increment()
-		// Weighting comes into play when there are multiple files imported together. Older names are weighted less than modern names.
-		// The assumption is that each file is a year and earlier years are sorted in ascending order.
-		weight := float64(i+1) / float64(totalFiles)
-
-		file, err := os.Open(filepath.Join(directory, fn))
+		if progress != nil {
+			progress(i, len(files))
+		}
+		reader, err := openSourceFile(filepath.Join(directory, fn))
 		if err != nil {
-			log.Printf("Error opening file %v. --Skipping--", filepath.Join(directory, fn))
+			rejects = append(rejects, RejectedRow{File: fn, Reason: "unable to open file"})
 			continue
 		}
-		// Look through files and extract the data
-		// bom.NewReader gets rid of UTF-8 byte order marks that can cause problems.
-		fscanner := bufio.NewScanner(bom.NewReader(file))
-		for fscanner.Scan() {
-			cols := strings.Split(fscanner.Text(), ",")
-			lname, gender, c := strings.TrimSpace(cols[0]), strings.TrimSpace(strings.ToUpper(cols[1]))[0], strings.TrimSpace(cols[2])
+		for {
+			record, err := reader.next()
+			if err == io.EOF {
+				break
+			}
+			if reject, ok := err.(*RejectedRow); ok {
+				rejects = append(rejects, *reject)
+				continue
+			}
+			if err != nil {
+				rejects = append(rejects, RejectedRow{File: fn, Reason: err.Error()})
+				break
+			}
 			/*
 				----- This section is taken out because it only applies to some datasets. -----
 				// USA Census file names are truncated at 15 characters. There are only a few dozen and they are all
@@ -158,39 +199,105 @@ func extractNamesFromFiles(directory string) (namePopularity, error) {
 					continue
 				}
 			*/
-			origCount, err := strconv.Atoi(c)
-			if err != nil {
-				// If name count cannot be converted to a number then just skip the name.
-				log.Printf("Unable to convert popularity from string to number. %v,%v,%v", lname, gender, c)
-				continue
+			fileRecords[i] = append(fileRecords[i], record)
+		}
+		reader.Close()
+	}
+	if progress != nil {
+		progress(len(files), len(files))
+	}
+
+	weighting, err := readWeighting(directory)
+	if err != nil {
+		return namePopularity{}, rejects, err
+	}
+	return mergeSourceRecords(files, fileRecords, weighting), rejects, nil
+}
+
+// mergeSourceRecords merges the records of each of the files into a slice of names that is sorted by popularity.
+// The counts are weighted by the period that they are from, and the trends compare the older periods with the
+// recent ones.
+func mergeSourceRecords(files []string, fileRecords [][]sourceRecord, weighting Weighting) namePopularity {
+	years := map[int]bool{}
+	allHaveYears := true
+	for _, records := range fileRecords {
+		for _, record := range records {
+			if record.Year == 0 {
+				allHaveYears = false
+			}
+			years[record.Year] = true
+		}
+	}
+	// Weighting comes into play when there are multiple years imported together. Older names are usually weighted
+	// less than modern names. When every name has a year, from the file name or a year column, the periods are the
+	// years. Otherwise the assumption is that each file is a year and earlier years are sorted in ascending order.
+	periods := len(files)
+	yearPosition := map[int]int{}
+	latestYear := 0
+	if allHaveYears && len(years) > 0 {
+		sortedYears := []int{}
+		for y := range years {
+			sortedYears = append(sortedYears, y)
+		}
+		sort.Ints(sortedYears)
+		for i, y := range sortedYears {
+			yearPosition[y] = i
+		}
+		periods = len(sortedYears)
+		latestYear = sortedYears[len(sortedYears)-1]
+	}
+	// The trend compares the older half of the periods with the recent half. The middle period of an odd number of
+	// periods is in neither.
+	type trendCounts struct{ older, recent int }
+	nameTrends := map[string]*trendCounts{}
+	var totals trendCounts
+	namePopularityMap := map[string]*nameEntry{}
+	for i, records := range fileRecords {
+		for _, record := range records {
+			period := WeightPeriod{File: files[i], Position: i, Periods: periods, Age: periods - 1 - i}
+			if allHaveYears {
+				period.Year, period.Position, period.Age = record.Year, yearPosition[record.Year], latestYear-record.Year
 			}
 			// Weight the popularity of the name.
-			count := int(math.Ceil(float64(origCount) * weight))
-			if c, ok := namePopularityMap[gender][lname]; ok {
-				namePopularityMap[gender][lname] = c + count
+			count := int(math.Ceil(float64(record.Count) * weighting.Weight(period)))
+			entry, ok := namePopularityMap[record.Name]
+			if !ok {
+				entry = &nameEntry{Name: record.Name}
+				namePopularityMap[record.Name] = entry
+				nameTrends[record.Name] = &trendCounts{}
+			}
+			if record.Gender == 'M' {
+				entry.MaleCount += count
 			} else {
-				namePopularityMap[gender][lname] = count
+				entry.FemaleCount += count
+			}
+			switch {
+			case period.Position < periods/2:
+				nameTrends[record.Name].older += record.Count
+				totals.older += record.Count
+			case period.Position >= periods-periods/2:
+				nameTrends[record.Name].recent += record.Count
+				totals.recent += record.Count
 			}
 		}
 	}
-	bar.Finish()
+	for name, entry := range namePopularityMap {
+		t := nameTrends[name]
+		entry.Trend = popularityTrend(t.older, totals.older, t.recent, totals.recent)
+	}
 
 	// Put names in a slice of structs so we can sort it using the standard library.
 	var Names namePopularity
-	for gender, v := range namePopularityMap {
-		for keyName, count := range v {
-			Names = append(Names, nameEntry{
-				Name:       keyName,
-				Gender:     gender,
-				Popularity: count,
-			})
-			// After name is put in struct, delete it from the map to conserve resources.
-			delete(namePopularityMap[gender], keyName)
+	for keyName, entry := range namePopularityMap {
+		if entry.Popularity() > 0 {
+			Names = append(Names, *entry)
 		}
+		// After name is put in slice, delete it from the map to conserve resources.
+		delete(namePopularityMap, keyName)
 	}
 	// Sort in descending order of popularity.
 	sort.Sort(sort.Reverse(Names))
-	return Names, nil
+	return Names
 }
 
 // getAllDirectories gets all the directories in the baseDir folder.
@@ -205,9 +312,29 @@ func getAllDirectories(baseDir string) []string {
 	return directories
 }
 
+// dictionaryIndexes are the indexed columns of a dictionary table. Each index is named <table>_idx_<column>.
+var dictionaryIndexes = []string{
+	"chaldean_consonants",
+	"chaldean_full",
+	"chaldean_vowels",
+	"pythagorean_consonants",
+	"pythagorean_full",
+	"pythagorean_vowels",
+	"gender",
+	"popularity_rank",
+	"male_rank",
+	"female_rank",
+	"unisex_ratio",
+	"trend",
+	"origin",
+	"soundex",
+	"metaphone_primary",
+	"metaphone_alternate",
+}
+
 // Create the table if it is not already created.
-func setupDatabaseTable(table string) error {
-	if err := DB.Table(table).AutoMigrate(&precalculatedNumerology{}); err != nil {
+func setupDatabaseTable(db *gorm.DB, table string) error {
+	if err := db.Table(table).AutoMigrate(&precalculatedNumerology{}); err != nil {
 		return err
 	}
 
@@ -215,29 +342,187 @@ func setupDatabaseTable(table string) error {
 	// all the indexes. This causes and error since you can only have one index of each name.
 	// Manually rename the indexes after GORM creates them.
 	idxPrefix := "idx_precalculated_numerologies_"
-	indexes := []string{
-		"chaldean_consonants",
-		"chaldean_full",
-		"chaldean_vowels",
-		"pythagorean_consonants",
-		"pythagorean_full",
-		"pythagorean_vowels",
-		"gender",
-	}
-	for _, idx := range indexes {
-		if err := DB.Table(table).Migrator().RenameIndex(
+	for _, idx := range dictionaryIndexes {
+		// If the table was already set up then AutoMigrate has just made a duplicate of the renamed index.
+		if db.Table(table).Migrator().HasIndex(&precalculatedNumerology{}, table+"_idx_"+idx) {
+			if err := db.Table(table).Migrator().DropIndex(&precalculatedNumerology{}, idxPrefix+idx); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := db.Table(table).Migrator().RenameIndex(
 			&precalculatedNumerology{},
 			idxPrefix+idx, table+"_idx_"+idx,
 		); err != nil {
 			return err
 		}
-		if err := DB.Table(table).Migrator().DropIndex(&precalculatedNumerology{}, idxPrefix+idx); err != nil {
+		if err := db.Table(table).Migrator().DropIndex(&precalculatedNumerology{}, idxPrefix+idx); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// precalculateName calculates the numerological values of a name that are stored in the database. An error is
+// returned if the name has characters that cannot be calculated.
+func precalculateName(entry nameEntry) (precalculatedNumerology, error) {
+	pythagorean := Name(entry.Name, Pythagorean, []int{}, false)
+	chaldean := Name(entry.Name, Chaldean, []int{}, false)
+
+	// Check for unacceptable characters
+	if len(pythagorean.UnknownCharacters()) > 0 || len(chaldean.UnknownCharacters()) > 0 {
+		return precalculatedNumerology{}, fmt.Errorf("unacceptable characters in name: %v", entry.Name)
+	}
+
+	gender, unisexRatio := "M", 0.0
+	if entry.FemaleCount > entry.MaleCount {
+		gender = "F"
+	}
+	if entry.MaleCount > 0 && entry.FemaleCount > 0 {
+		if gender == "M" {
+			unisexRatio = float64(entry.FemaleCount) / float64(entry.MaleCount)
+		} else {
+			unisexRatio = float64(entry.MaleCount) / float64(entry.FemaleCount)
+		}
+	}
+
+	phonetics := getPhoneticKeys(entry.Name)
+	dbEntry := precalculatedNumerology{
+		Name:                  entry.Name,
+		Gender:                gender,
+		MaleCount:             int64(entry.MaleCount),
+		FemaleCount:           int64(entry.FemaleCount),
+		UnisexRatio:           unisexRatio,
+		Trend:                 entry.Trend,
+		PythagoreanFull:       uint8(pythagorean.Full().Breakdown[0].ReduceSteps[0]),
+		PythagoreanVowels:     uint8(pythagorean.Vowels().Breakdown[0].ReduceSteps[0]),
+		PythagoreanConsonants: uint8(pythagorean.Consonants().Breakdown[0].ReduceSteps[0]),
+		ChaldeanFull:          uint8(chaldean.Full().Breakdown[0].ReduceSteps[0]),
+		ChaldeanVowels:        uint8(chaldean.Vowels().Breakdown[0].ReduceSteps[0]),
+		ChaldeanConsonants:    uint8(chaldean.Consonants().Breakdown[0].ReduceSteps[0]),
+		Soundex:               phonetics.Soundex,
+		MetaphonePrimary:      phonetics.MetaphonePrimary,
+		MetaphoneAlternate:    phonetics.MetaphoneAlternate,
+	}
+
+	// Use reflection to populate these fields in the struct. There may be a better way to do this.
+	pCounts := pythagorean.Counts()
+	for k, v := range pCounts {
+		field := fmt.Sprintf("P%v", k)
+		setStructField(&dbEntry, field, uint8(v))
+	}
+	cCounts := chaldean.Counts()
+	for k, v := range cCounts {
+		field := fmt.Sprintf("C%v", k)
+		setStructField(&dbEntry, field, uint8(v))
+	}
+	numberSystems, err := precalculateNumberSystems(entry.Name)
+	if err != nil {
+		return precalculatedNumerology{}, err
+	}
+	dbEntry.NumberSystems = numberSystems
+	return dbEntry, nil
+}
+
+// precalculateNames calculates the numerological values of names that are in order of popularity and ranks
+// them. Names with characters that cannot be calculated are left out. The names are calculated by a pool of
+// workers, but the rows are always in the same order as the names.
+func precalculateNames(names namePopularity) (rows []precalculatedNumerology) {
+	type result struct {
+		row precalculatedNumerology
+		err error
+	}
+	results := make([]result, len(names))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				row, err := precalculateName(names[i])
+				results[i] = result{row, err}
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, r := range results {
+		if r.err != nil {
+			continue
+		}
+		r.row.PopularityRank = int64(len(rows) + 1)
+		rows = append(rows, r.row)
+	}
+	rankGender(rows, func(r *precalculatedNumerology) (*int64, int64) { return &r.MaleRank, r.MaleCount })
+	rankGender(rows, func(r *precalculatedNumerology) (*int64, int64) { return &r.FemaleRank, r.FemaleCount })
+	return rows
+}
+
+// rankGender ranks the names that have been used for a gender by the count of that gender. Field returns the
+// rank field to set and the count of the gender. Names that are equally popular are ranked alphabetically.
+func rankGender(rows []precalculatedNumerology, field func(r *precalculatedNumerology) (*int64, int64)) {
+	var used []int
+	for i := range rows {
+		if _, count := field(&rows[i]); count > 0 {
+			used = append(used, i)
+		}
+	}
+	sort.SliceStable(used, func(i, j int) bool {
+		_, a := field(&rows[used[i]])
+		_, b := field(&rows[used[j]])
+		if a == b {
+			return rows[used[i]].Name < rows[used[j]].Name
+		}
+		return a > b
+	})
+	for rank, i := range used {
+		r, _ := field(&rows[i])
+		*r = int64(rank + 1)
+	}
+}
+
+// insertBatchSize is the number of rows that are inserted by each INSERT statement.
+const insertBatchSize = 500
+
+// transactionSize is the number of rows that are committed by each transaction. An import that is interrupted
+// only loses the rows of the transaction that it was in the middle of.
+const transactionSize = 5000
+
+// insertNames inserts the rows into a table in batches. Each group of transactionSize rows is committed in its
+// own transaction. Progress is called after each transaction when it is not nil.
+func insertNames(table string, rows []precalculatedNumerology, progress func(done int, total int)) error {
+	for start := 0; start < len(rows); start += transactionSize {
+		end := start + transactionSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Table(table).CreateInBatches(rows[start:end], insertBatchSize).Error; err != nil {
+				return err
+			}
+			return writeNumberSystems(tx, table, rows[start:end])
+		}); err != nil {
+			return fmt.Errorf("unable to insert records into database table %v: %v", table, err)
+		}
+		if progress != nil {
+			progress(end, len(rows))
+		}
+	}
+	return nil
+}
+
+// importInterrupted reports whether a table that is not empty is from an import that did not finish. The files
+// of an import are recorded as pending before its rows are inserted and are only marked as finished after the
+// last row, so an interrupted import still has pending files in the import log.
+func importInterrupted(table string) bool {
+	return len(pendingSourceFiles(DB, table)) > 0
+}
+
 // CreateDatabase function creates and populates the database table with the pre-populated numerological
 // calculations. The argument dsn is the connection string for the database that will utilized. The argument
 // baseDir is the directory where the CSV files are stored that contain the names that will populate the
@@ -245,86 +530,117 @@ func setupDatabaseTable(table string) error {
 // sources.
 //
 // The format for the CSV files is name, gender, popularity with no header. Gender is just a letter 'M' for
-// male or 'F' female. Popularity is used to determine the sort order of the names. Each reoccurrence of the
-// same name aggregates the popularity.
+// male or 'F' female. Popularity is used to rank the names. Each reoccurrence of the same name aggregates the
+// popularity of its gender, and a name that is used for both genders is ranked by the total of both.
 //
-//  john,M,10000
-//  sara,F,9000
-//  jack,M,8000
+//	john,M,10000
+//	sara,F,9000
+//	jack,M,8000
+//
+// A dsn of memory:// loads the names into memory instead of a database. The names can then be searched by
+// using the same dsn in NameSearchOpts.Database.
+//
+// CreateDatabase is quiet. Use CreateDatabaseWithOpts to follow the progress and to get a report of the import.
 func CreateDatabase(dsn string, baseDir string) error {
+	_, err := CreateDatabaseWithOpts(dsn, baseDir, ImportOpts{})
+	return err
+}
+
+// CreateDatabaseWithOpts is CreateDatabase with options for the progress and the logging of the import. The
+// report says what was done with each dictionary, including the ones that were skipped and why, the rows of the
+// source files that could not be read, and the names that have characters that cannot be calculated. The report
+// has the dictionaries that were done before an error.
+func CreateDatabaseWithOpts(dsn string, baseDir string, opts ImportOpts) (ImportReport, error) {
+	run := &importRun{opts: opts}
+	if isMemoryDSN(dsn) {
+		err := run.createMemoryDatabase(baseDir)
+		return run.report, err
+	}
 	directories := getAllDirectories(baseDir)
 
-	log.Println("Connecting to database...")
+	run.log(LogInfo, "Connecting to database", nil)
 	if err := connectToDatabase(dsn); err != nil {
-		return errors.New("unable to connect to database. " + err.Error())
+		return run.report, errors.New("unable to connect to database. " + err.Error())
+	}
+	if err := DB.AutoMigrate(&importLog{}, &dictionaryMetadata{}); err != nil {
+		return run.report, err
 	}
 	// Iterate over each of the folders and make a separate db table for each.
 	for _, dir := range directories {
+		d := run.dictionary(dir)
 		// Create the table if it is not already created.
-		if err := setupDatabaseTable(dir); err != nil {
-			return err
+		if err := setupDatabaseTable(DB, dir); err != nil {
+			return run.report, err
 		}
 
-		// Make sure the table is empty. If it is not, then adding entries could mess it up.
+		// Make sure the table is empty. If it is not, then adding entries could mess it up. The exception is an
+		// import that was interrupted, which picks up where it left off.
 		var count int64
 		DB.Table(dir).Count(&count)
-		if count > 0 {
-			log.Printf("Table %v is not empty. Skipping.", dir)
+		if count > 0 && !importInterrupted(dir) {
+			run.skip(d, SkipNotEmpty)
 			continue
 		}
 
-		names, err := extractNamesFromFiles(filepath.Join(baseDir, dir))
+		files, err := getSourceFiles(filepath.Join(baseDir, dir))
 		if err != nil {
-			return err
+			return run.report, err
 		}
-
-		log.Printf("Populating database table %v", dir)
-		bar := pb.Full.Start(len(names))
-		for _, entry := range names {
-			bar.Increment()
-			// Precalculate the numerological numbers we want to put in the database.
-			pythagorean := Name(entry.Name, Pythagorean, []int{}, false)
-			chaldean := Name(entry.Name, Chaldean, []int{}, false)
-
-			// Check for unacceptable characters
-			if len(pythagorean.UnknownCharacters()) > 0 || len(chaldean.UnknownCharacters()) > 0 {
-				log.Println(fmt.Sprintf("Skipping name with unacceptable characters: %v", entry.Name))
-				continue
+		// The rows of an interrupted import were calculated from its files, so it can only be resumed with the
+		// same files. Otherwise the table is emptied and the import is started again.
+		if count > 0 && !sameSourceFiles(pendingSourceFiles(DB, dir), files) {
+			run.log(LogWarning, "Source files changed since the import was interrupted. Starting it again", Fields{"dictionary": dir})
+			if err := DB.Migrator().DropTable(dir, numberSystemsTable(dir)); err != nil {
+				return run.report, fmt.Errorf("unable to empty database table %v: %v", dir, err)
 			}
-
-			dbEntry := precalculatedNumerology{
-				Name:                  entry.Name,
-				Gender:                string(entry.Gender),
-				PythagoreanFull:       uint8(pythagorean.Full().Breakdown[0].ReduceSteps[0]),
-				PythagoreanVowels:     uint8(pythagorean.Vowels().Breakdown[0].ReduceSteps[0]),
-				PythagoreanConsonants: uint8(pythagorean.Consonants().Breakdown[0].ReduceSteps[0]),
-				ChaldeanFull:          uint8(chaldean.Full().Breakdown[0].ReduceSteps[0]),
-				ChaldeanVowels:        uint8(chaldean.Vowels().Breakdown[0].ReduceSteps[0]),
-				ChaldeanConsonants:    uint8(chaldean.Consonants().Breakdown[0].ReduceSteps[0]),
-			}
-
-			// Use reflection to populate these fields in the struct. There may be a better way to do this.
-			pCounts := pythagorean.Counts()
-			for k, v := range pCounts {
-				field := fmt.Sprintf("P%v", k)
-				setStructField(&dbEntry, field, uint8(v))
-			}
-			cCounts := chaldean.Counts()
-			for k, v := range cCounts {
-				field := fmt.Sprintf("C%v", k)
-				setStructField(&dbEntry, field, uint8(v))
+			if err := setupDatabaseTable(DB, dir); err != nil {
+				return run.report, err
 			}
+			count = 0
+		}
+		names, err := run.readNames(d, filepath.Join(baseDir, dir))
+		if err != nil {
+			return run.report, err
+		}
 
-			// Insert the record into the database.
-			if err := DB.Table(dir).Create(&dbEntry).Error; err != nil {
-				log.Println(fmt.Sprintf("unable to insert record into database: %v", dbEntry))
-				continue
-			}
+		rows := run.calculate(d, names)
+		metadata, err := run.readNameMetadata(d, filepath.Join(baseDir, dir))
+		if err != nil {
+			return run.report, err
+		}
+		applyNameMetadata(rows, metadata)
+		if count > 0 {
+			d.Resumed = count
+			run.log(LogInfo, "Resuming population of database table", Fields{"dictionary": dir, "names": count})
+		} else {
+			run.log(LogInfo, "Populating database table", Fields{"dictionary": dir, "names": len(rows)})
+		}
+		// The rows are always calculated in the same order so an interrupted import skips the rows that it
+		// already inserted.
+		if int(count) > len(rows) {
+			return run.report, fmt.Errorf("unable to resume populating table %v because it has more names than its files", dir)
+		}
+		// Record the files as pending so that the import can be resumed if it is interrupted.
+		if err := recordImport(DB, dir, files, true); err != nil {
+			return run.report, err
+		}
+		if err := insertNames(dir, rows[count:], func(done int, total int) {
+			run.progress(ProgressInserting, done, total)
+		}); err != nil {
+			return run.report, err
+		}
+		d.Inserted = len(rows) - int(count)
+		// Mark the import as finished so that UpdateDatabase knows what has been applied.
+		if err := finishImport(DB, dir); err != nil {
+			return run.report, err
+		}
+		forgetDictionary(dir)
+		if err := writeMetadata(DB, currentMetadata(dir, filepath.Join(baseDir, dir), files)); err != nil {
+			return run.report, err
 		}
-		bar.Finish()
 	}
-	log.Println("Vacuuming database to complete process.")
+	run.log(LogInfo, "Vacuuming database", nil)
 	// Vacuum the database to make sure any extra space is reclaimed.
 	DB.Raw("VACUUM;")
-	return nil
+	return run.report, nil
 }
@@ -14,7 +14,9 @@ package numerology
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
 	"testing"
 )
 
@@ -80,3 +82,171 @@ func TestCreateDatabase(t *testing.T) {
 	}
 	DB = nil
 }
+
+func Test_precalculateNames(t *testing.T) {
+	names := namePopularity{
+		{"Kelly", 300, 400, 0},
+		{"James", 500, 0, 0},
+		{"Mary", 0, 450, 0},
+		{"Jordan", 200, 100, 0},
+		{"Zoë", 0, 10, 0},
+	}
+	sort.Sort(sort.Reverse(names))
+	rows := precalculateNames(names)
+	type want struct {
+		popularityRank, maleRank, femaleRank int64
+		gender                               string
+		unisexRatio                          float64
+	}
+	wants := map[string]want{
+		"Kelly":  {1, 2, 2, "F", 0.75},
+		"James":  {2, 1, 0, "M", 0},
+		"Mary":   {3, 0, 1, "F", 0},
+		"Jordan": {4, 3, 3, "M", 0.5},
+		"Zoë":    {5, 0, 4, "F", 0},
+	}
+	if len(rows) != len(wants) {
+		t.Fatalf("precalculateNames() = %v rows, want %v", len(rows), len(wants))
+	}
+	for _, r := range rows {
+		got := want{r.PopularityRank, r.MaleRank, r.FemaleRank, r.Gender, r.UnisexRatio}
+		if got != wants[r.Name] {
+			t.Errorf("precalculateNames() %v = %v, want %v", r.Name, got, wants[r.Name])
+		}
+	}
+}
+
+func Test_precalculateNamesOrder(t *testing.T) {
+	_, b, _, _ := runtime.Caller(0)
+	names, _, err := readSourceFiles(filepath.Join(filepath.Dir(b), "..", "test_names", "usa_census"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The rows calculated by the workers are in the same order as when they are calculated one at a time.
+	rows := precalculateNames(names)
+	for i, entry := range names[:500] {
+		want, err := precalculateName(entry)
+		if err != nil {
+			continue
+		}
+		got := rows[i]
+		got.PopularityRank, got.MaleRank, got.FemaleRank = 0, 0, 0
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("precalculateNames() row %v = %v, want %v", i, got, want)
+		}
+	}
+	if again := precalculateNames(names); !reflect.DeepEqual(rows, again) {
+		t.Errorf("precalculateNames() is not the same every time")
+	}
+}
+
+func TestCreateDatabaseResume(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := filepath.Join(baseDir, "resume_test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "2020.csv"), []byte("Mary,F,300\nJohn,M,200\nKelly,F,60\nKelly,M,50\nZoe,F,40\nAdam,M,30\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	if err := connectToDatabase(dsn); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DB.Migrator().DropTable("resume_test")
+		DB.Where("dictionary = ?", "resume_test").Delete(&importLog{})
+		DB = nil
+	}()
+
+	names, _, err := readSourceFiles(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := precalculateNames(names)
+	for i := range want {
+		want[i].Id = int64(i + 1)
+	}
+
+	// A table without a pending import is not resumed, even though its ranks are from 1 to its number of rows.
+	if err := setupDatabaseTable(DB, "resume_test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := insertNames("resume_test", want[:2], nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateDatabase(dsn, baseDir); err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	var count int64
+	DB.Table("resume_test").Count(&count)
+	if count != 2 {
+		t.Errorf("CreateDatabase() count = %v, want a table that is not empty to be skipped", count)
+	}
+
+	// Simulate an import that died after inserting the first rows.
+	files, err := getSourceFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recordImport(DB, "resume_test", files, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateDatabase(dsn, baseDir); err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	var got []precalculatedNumerology
+	DB.Table("resume_test").Order("id asc").Find(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CreateDatabase() resumed rows = %v, want %v", got, want)
+	}
+	if applied, _ := appliedSourceFiles(DB, "resume_test"); !sameSourceFiles(applied, files) || importInterrupted("resume_test") {
+		t.Errorf("CreateDatabase() applied files = %v, want the import to be finished", applied)
+	}
+
+	// A finished import is not resumed again.
+	if err := CreateDatabase(dsn, baseDir); err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	DB.Table("resume_test").Count(&count)
+	if count != int64(len(want)) {
+		t.Errorf("CreateDatabase() count = %v, want %v", count, len(want))
+	}
+
+	// An import whose files changed after it was interrupted is started again instead of being resumed.
+	if err := DB.Migrator().DropTable("resume_test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := setupDatabaseTable(DB, "resume_test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := insertNames("resume_test", want[:2], nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordImport(DB, "resume_test", files, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "2020.csv"), []byte("Zoe,F,500\nAdam,M,400\nMary,F,300\nJohn,M,200\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateDatabase(dsn, baseDir); err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	names, _, err = readSourceFiles(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = precalculateNames(names)
+	for i := range want {
+		want[i].Id = int64(i + 1)
+	}
+	got = nil
+	DB.Table("resume_test").Order("id asc").Find(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CreateDatabase() rows after the files changed = %v, want %v", got, want)
+	}
+	if len(pendingSourceFiles(DB, "resume_test")) > 0 {
+		t.Errorf("CreateDatabase() left the import pending")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+//go:generate sh -c "gzip -9 -n -c ../test_names/usa_census/test_names.csv > embedded/usa_census.csv.gz"
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+)
+
+// EmbeddedDictionary is the name of the dictionary that is built into the library. It is searched when
+// NameSearchOpts has neither a Database nor a Dictionary and there is no database connection, so names can be
+// searched without creating a database first. It has the same names as test_names/usa_census.
+const EmbeddedDictionary = "usa_census"
+
+// embeddedDictionaries holds the embedded dictionary once it has been calculated. It is kept apart from
+// memoryDictionaries so that a dictionary of the same name can still be loaded into memory.
+var embeddedDictionaries = map[string]*memoryDictionary{}
+var embeddedOnce sync.Once
+var embeddedErr error
+
+// usesEmbeddedDictionary reports whether a search with the DSN uses the embedded dictionary.
+func usesEmbeddedDictionary(dsn string) bool {
+	return dsn == "" && DB == nil
+}
+
+// openEmbeddedDictionary returns a store that searches the embedded dictionary. The names are calculated the
+// first time that it is used, which takes about a second.
+func openEmbeddedDictionary() (nameStore, error) {
+	embeddedOnce.Do(func() {
+		var rows []precalculatedNumerology
+		rows, embeddedErr = readEmbeddedNames(embeddedNames)
+		if embeddedErr == nil {
+			embeddedDictionaries[EmbeddedDictionary] = newMemoryDictionary(rows)
+		}
+	})
+	if embeddedErr != nil {
+		return nil, embeddedErr
+	}
+	// A number system may have been registered since the names were calculated.
+	if d := embeddedDictionaries[EmbeddedDictionary]; d.fingerprint != numberSystemsFingerprint() {
+		embeddedDictionaries[EmbeddedDictionary] = recalculateMemoryDictionary(d)
+	}
+	return memoryStore{embeddedDictionaries}, nil
+}
+
+// readEmbeddedNames reads the gzipped CSV of the embedded dictionary and calculates its rows.
+func readEmbeddedNames(data []byte) (rows []precalculatedNumerology, err error) {
+	if len(data) == 0 {
+		return nil, errors.New("there is no database to search because the library was built without the embedded dictionary (the noembed build tag)")
+	}
+	input, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+	const file = EmbeddedDictionary + ".csv"
+	reader := newDelimitedReader(sourceFileBase{file: file}, input, ',')
+	records := []sourceRecord{}
+	for {
+		record, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	rows = precalculateNames(mergeSourceRecords([]string{file}, [][]sourceRecord{records}, LinearWeighting{}))
+	for i := range rows {
+		// Ids are given out in order just like they are by createMemoryDatabase.
+		rows[i].Id = int64(i + 1)
+	}
+	return rows, nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_embeddedDictionaryMatchesMemory(t *testing.T) {
+	connection := DB
+	DB = nil
+	defer func() { DB = connection }()
+
+	tests := []struct {
+		name  string
+		query string
+		opts  NameSearchOpts
+	}{
+		{"Common", "?", NameSearchOpts{Count: 50, Full: []int{1, 4}}},
+		{"Uncommon", "? Doe", NameSearchOpts{Count: 50, Sort: UncommonSort, Vowels: []int{3, -11}}},
+		{"Gender", "?", NameSearchOpts{Count: 50, Offset: 300, Gender: Male, Vowels: []int{6}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embeddedResults, embeddedOffset, err := nameSearch(tt.query, Pythagorean, []int{11, 22, 33}, true, tt.opts)
+			if err != nil {
+				t.Fatalf("nameSearch() error = %v", err)
+			}
+			opts := tt.opts
+			opts.Dictionary = "usa_census"
+			opts.Database = "memory://"
+			memoryResults, memoryOffset, err := nameSearch(tt.query, Pythagorean, []int{11, 22, 33}, true, opts)
+			if err != nil {
+				t.Fatalf("nameSearch() error = %v", err)
+			}
+			embeddedList, memoryNames := []string{}, []string{}
+			for _, r := range embeddedResults {
+				embeddedList = append(embeddedList, r.Name)
+			}
+			for _, r := range memoryResults {
+				memoryNames = append(memoryNames, r.Name)
+			}
+			if len(embeddedList) == 0 {
+				t.Errorf("embedded dictionary returned no names")
+			}
+			if !reflect.DeepEqual(embeddedList, memoryNames) || embeddedOffset != memoryOffset {
+				t.Errorf("embedded results do not match memory. embedded = %v %v, memory = %v %v", embeddedList, embeddedOffset, memoryNames, memoryOffset)
+			}
+		})
+	}
+}
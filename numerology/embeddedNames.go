@@ -0,0 +1,24 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+//go:build !noembed
+// +build !noembed
+
+package numerology
+
+import (
+	_ "embed"
+)
+
+// embeddedNames is the gzipped CSV of the embedded dictionary. Build with the noembed tag to leave it out.
+//
+//go:embed embedded/usa_census.csv.gz
+var embeddedNames []byte
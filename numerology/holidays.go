@@ -0,0 +1,276 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Holiday is a holiday on a date. The date is at midday UTC, just like NewDate.
+type Holiday struct {
+	Name string    `json:"name"`
+	Date time.Time `json:"date"`
+}
+
+// HolidayCalendar calculates the holidays of a year. The holidays are calculated offline from rules, so a
+// calendar has to be updated when the holidays of its country change. Custom calendars, like the days that a
+// venue is closed every year, can be added with RegisterHolidayCalendar.
+type HolidayCalendar interface {
+	Holidays(year int) []Holiday
+}
+
+// holidayRule is the date of a holiday in a year. It returns false in the years that the holiday is not held.
+type holidayRule struct {
+	name string
+	date func(year int) (time.Time, bool)
+}
+
+// ruleCalendar is a calendar of holidays that are calculated from rules.
+type ruleCalendar []holidayRule
+
+// Holidays returns the holidays of the year in order of their dates.
+func (c ruleCalendar) Holidays(year int) (holidays []Holiday) {
+	for _, rule := range c {
+		if d, ok := rule.date(year); ok {
+			holidays = append(holidays, Holiday{Name: rule.name, Date: d})
+		}
+	}
+	sort.SliceStable(holidays, func(i, j int) bool { return holidays[i].Date.Before(holidays[j].Date) })
+	return holidays
+}
+
+// fixedHoliday is a holiday that is on the same day every year. ex. Christmas
+func fixedHoliday(name string, month time.Month, day int) holidayRule {
+	return holidayRule{name, func(year int) (time.Time, bool) {
+		return NewDate(year, int(month), day), true
+	}}
+}
+
+// weekdayHoliday is a holiday on the nth weekday of a month. ex. The 4th Thursday of November. A negative n
+// counts from the end of the month, so -1 is the last one.
+func weekdayHoliday(name string, month time.Month, weekday time.Weekday, n int) holidayRule {
+	return holidayRule{name, func(year int) (time.Time, bool) {
+		if n < 0 {
+			last := NewDate(year, int(month)+1, 0)
+			return last.AddDate(0, 0, -((int(last.Weekday())-int(weekday)+7)%7 + 7*(-n-1))), true
+		}
+		first := NewDate(year, int(month), 1)
+		return first.AddDate(0, 0, (int(weekday)-int(first.Weekday())+7)%7+7*(n-1)), true
+	}}
+}
+
+// weekdayBeforeHoliday is a holiday on the last weekday before a day of a month. ex. The Monday before May 25th
+func weekdayBeforeHoliday(name string, month time.Month, day int, weekday time.Weekday) holidayRule {
+	return holidayRule{name, func(year int) (time.Time, bool) {
+		before := NewDate(year, int(month), day-1)
+		return before.AddDate(0, 0, -((int(before.Weekday()) - int(weekday) + 7) % 7)), true
+	}}
+}
+
+// easterHoliday is a movable feast that is a number of days from Easter Sunday. ex. -2 is Good Friday
+func easterHoliday(name string, days int) holidayRule {
+	return holidayRule{name, func(year int) (time.Time, bool) {
+		return Easter(year).AddDate(0, 0, days), true
+	}}
+}
+
+// since limits a holiday to the years from the one that it was first held.
+func since(firstYear int, rule holidayRule) holidayRule {
+	return holidayRule{rule.name, func(year int) (time.Time, bool) {
+		if year < firstYear {
+			return time.Time{}, false
+		}
+		return rule.date(year)
+	}}
+}
+
+// Easter calculates the date of Easter Sunday in the Gregorian calendar with the anonymous Gregorian algorithm.
+func Easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return NewDate(year, month, day)
+}
+
+// holidayCalendars are the calendars that can be used in date searches by their lowercase code. The built-in
+// calendars are the public holidays of countries by their ISO 3166 code. They are the dates of the holidays
+// themselves, not the weekdays that are given off when a holiday falls on a weekend.
+var holidayCalendars = map[string]HolidayCalendar{
+	"us": ruleCalendar{
+		fixedHoliday("New Year's Day", time.January, 1),
+		weekdayHoliday("Martin Luther King Jr. Day", time.January, time.Monday, 3),
+		weekdayHoliday("Washington's Birthday", time.February, time.Monday, 3),
+		weekdayHoliday("Memorial Day", time.May, time.Monday, -1),
+		since(2021, fixedHoliday("Juneteenth", time.June, 19)),
+		fixedHoliday("Independence Day", time.July, 4),
+		weekdayHoliday("Labor Day", time.September, time.Monday, 1),
+		weekdayHoliday("Columbus Day", time.October, time.Monday, 2),
+		fixedHoliday("Veterans Day", time.November, 11),
+		weekdayHoliday("Thanksgiving Day", time.November, time.Thursday, 4),
+		fixedHoliday("Christmas Day", time.December, 25),
+	},
+	"ca": ruleCalendar{
+		fixedHoliday("New Year's Day", time.January, 1),
+		easterHoliday("Good Friday", -2),
+		weekdayBeforeHoliday("Victoria Day", time.May, 25, time.Monday),
+		fixedHoliday("Canada Day", time.July, 1),
+		weekdayHoliday("Labour Day", time.September, time.Monday, 1),
+		since(2021, fixedHoliday("National Day for Truth and Reconciliation", time.September, 30)),
+		weekdayHoliday("Thanksgiving", time.October, time.Monday, 2),
+		fixedHoliday("Remembrance Day", time.November, 11),
+		fixedHoliday("Christmas Day", time.December, 25),
+		fixedHoliday("Boxing Day", time.December, 26),
+	},
+	"gb": ruleCalendar{
+		fixedHoliday("New Year's Day", time.January, 1),
+		easterHoliday("Good Friday", -2),
+		easterHoliday("Easter Monday", 1),
+		weekdayHoliday("Early May Bank Holiday", time.May, time.Monday, 1),
+		weekdayHoliday("Spring Bank Holiday", time.May, time.Monday, -1),
+		weekdayHoliday("Summer Bank Holiday", time.August, time.Monday, -1),
+		fixedHoliday("Christmas Day", time.December, 25),
+		fixedHoliday("Boxing Day", time.December, 26),
+	},
+	"ie": ruleCalendar{
+		fixedHoliday("New Year's Day", time.January, 1),
+		since(2023, weekdayHoliday("St. Brigid's Day", time.February, time.Monday, 1)),
+		fixedHoliday("St. Patrick's Day", time.March, 17),
+		easterHoliday("Easter Monday", 1),
+		weekdayHoliday("May Bank Holiday", time.May, time.Monday, 1),
+		weekdayHoliday("June Bank Holiday", time.June, time.Monday, 1),
+		weekdayHoliday("August Bank Holiday", time.August, time.Monday, 1),
+		weekdayHoliday("October Bank Holiday", time.October, time.Monday, -1),
+		fixedHoliday("Christmas Day", time.December, 25),
+		fixedHoliday("St. Stephen's Day", time.December, 26),
+	},
+	"de": ruleCalendar{
+		fixedHoliday("Neujahr", time.January, 1),
+		easterHoliday("Karfreitag", -2),
+		easterHoliday("Ostermontag", 1),
+		fixedHoliday("Tag der Arbeit", time.May, 1),
+		easterHoliday("Christi Himmelfahrt", 39),
+		easterHoliday("Pfingstmontag", 50),
+		fixedHoliday("Tag der Deutschen Einheit", time.October, 3),
+		fixedHoliday("Erster Weihnachtstag", time.December, 25),
+		fixedHoliday("Zweiter Weihnachtstag", time.December, 26),
+	},
+	"fr": ruleCalendar{
+		fixedHoliday("Jour de l'an", time.January, 1),
+		easterHoliday("Lundi de Pâques", 1),
+		fixedHoliday("Fête du Travail", time.May, 1),
+		fixedHoliday("Victoire 1945", time.May, 8),
+		easterHoliday("Ascension", 39),
+		easterHoliday("Lundi de Pentecôte", 50),
+		fixedHoliday("Fête nationale", time.July, 14),
+		fixedHoliday("Assomption", time.August, 15),
+		fixedHoliday("Toussaint", time.November, 1),
+		fixedHoliday("Armistice 1918", time.November, 11),
+		fixedHoliday("Noël", time.December, 25),
+	},
+}
+
+// holidayCalendarCode checks the code of a holiday calendar and converts it to the lowercase key of
+// holidayCalendars.
+func holidayCalendarCode(code string) (string, error) {
+	key := strings.ToLower(strings.TrimSpace(code))
+	if key == "" {
+		return "", errors.New("holiday calendar has no code")
+	}
+	return key, nil
+}
+
+// RegisterHolidayCalendar adds a holiday calendar that can be used in date searches by its code. The code is
+// not case sensitive and replaces a calendar that has the same code, including the built-in ones. Holiday
+// calendars should be registered when the program starts.
+func RegisterHolidayCalendar(code string, calendar HolidayCalendar) error {
+	key, err := holidayCalendarCode(code)
+	if err != nil {
+		return err
+	}
+	if calendar == nil {
+		return fmt.Errorf("holiday calendar %v is nil", code)
+	}
+	holidayCalendars[key] = calendar
+	return nil
+}
+
+// GetHolidayCalendar returns the holiday calendar with the code. ex. "us", "gb", "de"
+func GetHolidayCalendar(code string) (HolidayCalendar, error) {
+	key, err := holidayCalendarCode(code)
+	if err != nil {
+		return nil, err
+	}
+	calendar, ok := holidayCalendars[key]
+	if !ok {
+		return nil, errors.New("unknown holiday calendar: " + code)
+	}
+	return calendar, nil
+}
+
+// HolidayCalendarCodes returns the codes of the holiday calendars that can be used in date searches, in
+// alphabetical order.
+func HolidayCalendarCodes() (codes []string) {
+	for code := range holidayCalendars {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// holidaySet finds out whether dates are holidays in any of a set of calendars. The holidays of each year are
+// only calculated once.
+type holidaySet struct {
+	calendars []HolidayCalendar
+	years     map[int]map[time.Time]bool
+}
+
+// newHolidaySet returns the holidays of the calendars with the codes. Codes of calendars that do not exist have
+// no holidays, and they are returned in unknown so that they can be reported.
+func newHolidaySet(codes []string) (set *holidaySet, unknown []string) {
+	set = &holidaySet{years: map[int]map[time.Time]bool{}}
+	for _, code := range codes {
+		calendar, err := GetHolidayCalendar(code)
+		if err != nil {
+			unknown = append(unknown, code)
+			continue
+		}
+		set.calendars = append(set.calendars, calendar)
+	}
+	return set, unknown
+}
+
+func (s *holidaySet) contains(d time.Time) bool {
+	d = day(d)
+	holidays, ok := s.years[d.Year()]
+	if !ok {
+		holidays = map[time.Time]bool{}
+		for _, calendar := range s.calendars {
+			for _, h := range calendar.Holidays(d.Year()) {
+				holidays[day(h.Date)] = true
+			}
+		}
+		s.years[d.Year()] = holidays
+	}
+	return holidays[d]
+}
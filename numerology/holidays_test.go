@@ -0,0 +1,166 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEaster(t *testing.T) {
+	tests := []struct {
+		year int
+		want time.Time
+	}{
+		{1818, NewDate(1818, 3, 22)},
+		{2000, NewDate(2000, 4, 23)},
+		{2021, NewDate(2021, 4, 4)},
+		{2022, NewDate(2022, 4, 17)},
+		{2024, NewDate(2024, 3, 31)},
+		{2038, NewDate(2038, 4, 25)},
+	}
+	for _, tt := range tests {
+		if got := Easter(tt.year); !got.Equal(tt.want) {
+			t.Errorf("Easter(%v) = %v, want %v", tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestHolidayCalendar(t *testing.T) {
+	tests := []struct {
+		code    string
+		year    int
+		holiday string
+		want    time.Time
+	}{
+		{"US", 2021, "Martin Luther King Jr. Day", NewDate(2021, 1, 18)},
+		{"us", 2021, "Memorial Day", NewDate(2021, 5, 31)},
+		{"us", 2021, "Juneteenth", NewDate(2021, 6, 19)},
+		{"us", 2020, "Juneteenth", time.Time{}},
+		{"us", 2021, "Thanksgiving Day", NewDate(2021, 11, 25)},
+		{"ca", 2020, "Victoria Day", NewDate(2020, 5, 18)},
+		{"ca", 2021, "Victoria Day", NewDate(2021, 5, 24)},
+		{"gb", 2021, "Summer Bank Holiday", NewDate(2021, 8, 30)},
+		{"gb", 2022, "Good Friday", NewDate(2022, 4, 15)},
+		{"ie", 2021, "October Bank Holiday", NewDate(2021, 10, 25)},
+		{"de", 2021, "Christi Himmelfahrt", NewDate(2021, 5, 13)},
+		{"fr", 2021, "Lundi de Pentecôte", NewDate(2021, 5, 24)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code+" "+tt.holiday, func(t *testing.T) {
+			calendar, err := GetHolidayCalendar(tt.code)
+			if err != nil {
+				t.Fatalf("GetHolidayCalendar() error = %v", err)
+			}
+			var got time.Time
+			for _, h := range calendar.Holidays(tt.year) {
+				if h.Name == tt.holiday {
+					got = h.Date
+				}
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Holidays(%v) %v = %v, want %v", tt.year, tt.holiday, got, tt.want)
+			}
+		})
+	}
+	if _, err := GetHolidayCalendar("xx"); err == nil {
+		t.Errorf("GetHolidayCalendar() error = nil, want an error")
+	}
+}
+
+// venueCalendar is a custom holiday calendar of the days that a venue is closed.
+type venueCalendar struct{}
+
+func (venueCalendar) Holidays(year int) []Holiday {
+	return []Holiday{{"Closed", NewDate(year, 7, 2)}}
+}
+
+func Test_dateSearchHolidays(t *testing.T) {
+	if err := RegisterHolidayCalendar("Venue", venueCalendar{}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(holidayCalendars, "venue")
+
+	days := func(opts DateSearchOpts) (days []string) {
+		opts.Count = 100
+		opts.Start, opts.End = NewDate(2021, 7, 1), NewDate(2021, 7, 6)
+		results, _ := Date(NewDate(2021, 1, 1), []int{11, 22, 33}).Search(opts)
+		for _, r := range results {
+			days = append(days, r.Date.Format("01-02"))
+		}
+		return days
+	}
+	tests := []struct {
+		name string
+		opts DateSearchOpts
+		want []string
+	}{
+		{"Blackouts", DateSearchOpts{Blackouts: []DateRange{{Start: NewDate(2021, 7, 2)}, {NewDate(2021, 7, 4), NewDate(2021, 7, 5)}}},
+			[]string{"07-01", "07-03", "07-06"}},
+		{"IncludeHolidays", DateSearchOpts{IncludeHolidays: []string{"us", "ca", "venue"}}, []string{"07-01", "07-02", "07-04"}},
+		{"ExcludeHolidays", DateSearchOpts{ExcludeHolidays: []string{"us", "venue"}}, []string{"07-01", "07-03", "07-05", "07-06"}},
+		{"UnknownCalendar", DateSearchOpts{IncludeHolidays: []string{"xx"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := days(tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateNumerology_CheckSearch(t *testing.T) {
+	var warnings []string
+	DefaultLogger = LoggerFunc(func(level LogLevel, message string, fields Fields) {
+		if level == LogWarning {
+			warnings = append(warnings, fmt.Sprint(fields["code"]))
+		}
+	})
+	defer func() { DefaultLogger = nil }()
+
+	date := Date(NewDate(2021, 1, 1), []int{11, 22, 33})
+	tests := []struct {
+		name    string
+		opts    DateSearchOpts
+		unknown []string
+	}{
+		{"Known", DateSearchOpts{IncludeHolidays: []string{"us"}, ExcludeHolidays: []string{"GB"}}, nil},
+		{"Include", DateSearchOpts{IncludeHolidays: []string{"uk"}}, []string{"uk"}},
+		{"Exclude", DateSearchOpts{ExcludeHolidays: []string{"us", "uk", "xx"}}, []string{"uk", "xx"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings = nil
+			err := date.CheckSearch(tt.opts)
+			if (err != nil) != (len(tt.unknown) > 0) {
+				t.Fatalf("CheckSearch() error = %v, want unknown codes %v", err, tt.unknown)
+			}
+			for _, code := range tt.unknown {
+				if !strings.Contains(err.Error(), code) {
+					t.Errorf("CheckSearch() error = %v, want %v in it", err, code)
+				}
+			}
+			if len(warnings) > 0 {
+				t.Errorf("CheckSearch() logged %v", warnings)
+			}
+			tt.opts.Count, tt.opts.MonthsForward = 1, 1
+			date.Search(tt.opts)
+			if !reflect.DeepEqual(warnings, tt.unknown) {
+				t.Errorf("Search() warned about %v, want %v", warnings, tt.unknown)
+			}
+		})
+	}
+}
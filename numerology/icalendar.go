@@ -0,0 +1,154 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CalendarEvent is an all day event of an iCalendar export.
+type CalendarEvent struct {
+	// UID identifies the event so that importing the same export again updates the events instead of adding them.
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+}
+
+// icalendarNow is the time that the events are stamped with. It is a variable so that tests can fix it.
+var icalendarNow = time.Now
+
+// icalendarProductId is the PRODID of the exports.
+const icalendarProductId = "-//LanderTome//numerologyCalculator//EN"
+
+// icalendarDate formats a date as an iCalendar DATE value.
+func icalendarDate(d time.Time) string {
+	return day(d).Format("20060102")
+}
+
+// escapeICalendarText escapes the characters of a TEXT value that have special meaning. RFC 5545 3.3.11
+func escapeICalendarText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// foldICalendarLine splits a content line into lines of no more than 75 octets. Each line after the first
+// starts with a space. Lines are only split between characters so that UTF-8 stays valid. RFC 5545 3.1
+func foldICalendarLine(line string) string {
+	var b strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// The space at the start of the next line counts towards its length.
+		limit = 74
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// WriteICalendar writes the events as an RFC 5545 iCalendar file that can be imported by calendar apps. Each
+// event takes the whole day of its date.
+func WriteICalendar(w io.Writer, events []CalendarEvent) error {
+	out := bufio.NewWriter(w)
+	write := func(name string, value string) {
+		out.WriteString(foldICalendarLine(name+":"+value) + "\r\n")
+	}
+	stamp := icalendarNow().UTC().Format("20060102T150405Z")
+	write("BEGIN", "VCALENDAR")
+	write("VERSION", "2.0")
+	write("PRODID", icalendarProductId)
+	write("CALSCALE", "GREGORIAN")
+	for _, e := range events {
+		write("BEGIN", "VEVENT")
+		write("UID", e.UID)
+		write("DTSTAMP", stamp)
+		write("DTSTART;VALUE=DATE", icalendarDate(e.Date))
+		write("DTEND;VALUE=DATE", icalendarDate(day(e.Date).AddDate(0, 0, 1)))
+		write("SUMMARY", escapeICalendarText(e.Summary))
+		write("DESCRIPTION", escapeICalendarText(e.Description))
+		write("TRANSP", "TRANSPARENT")
+		write("END", "VEVENT")
+	}
+	write("END", "VCALENDAR")
+	return out.Flush()
+}
+
+// describeResult describes the value of a calculation and how it was reached. Labels name the parts of the
+// breakdown in order. ex. "Year 2021 = 5"
+func describeResult(result NumerologicalResult, labels []string) string {
+	steps := func(reduceSteps []int) string {
+		var s []string
+		for _, step := range reduceSteps {
+			s = append(s, strconv.Itoa(step))
+		}
+		return strings.Join(s, " = ")
+	}
+	lines := []string{fmt.Sprintf("Value: %v", result.Value)}
+	for i, b := range result.Breakdown {
+		line := steps(b.ReduceSteps)
+		if i < len(labels) {
+			line = labels[i] + " " + line
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "Reduce: "+steps(result.ReduceSteps))
+	return strings.Join(lines, "\n")
+}
+
+// DateSearchEvents converts the results of a date search into events for WriteICalendar. Each event has the
+// Event number of its date, or the Life Path number when the search was for Life Path numbers, along with its
+// breakdown in the description.
+func DateSearchEvents(results []DateNumerology) (events []CalendarEvent) {
+	for _, r := range results {
+		kind, result := "Event", r.Event()
+		if r.DateSearchOpts != nil && r.DateSearchOpts.LifePath {
+			kind, result = "Life Path", r.LifePath()
+		}
+		events = append(events, CalendarEvent{
+			UID:         fmt.Sprintf("%v-%v@numerologycalculator", icalendarDate(r.Date), strings.ToLower(strings.ReplaceAll(kind, " ", "-"))),
+			Date:        r.Date,
+			Summary:     fmt.Sprintf("%v number %v", kind, result.Value),
+			Description: describeResult(result, []string{"Year", "Month", "Day"}),
+		})
+	}
+	return events
+}
+
+// PersonalDayEvents returns an event with the Personal Day number of every date in the range for someone born on
+// the date of the DateNumerology, so that the cycle can be exported with WriteICalendar. A range with a zero End is
+// the single day of its Start, like the ranges of DateSearchOpts.
+func (d DateNumerology) PersonalDayEvents(dates DateRange) (events []CalendarEvent) {
+	if dates.End.IsZero() {
+		dates.End = dates.Start
+	}
+	forEachDate([]DateRange{{day(dates.Start), day(dates.End)}}, false, 0, func(date time.Time, position int) bool {
+		result := d.PersonalDay(date)
+		events = append(events, CalendarEvent{
+			UID:         fmt.Sprintf("%v-personal-day-%v@numerologycalculator", icalendarDate(date), icalendarDate(d.Date)),
+			Date:        date,
+			Summary:     fmt.Sprintf("Personal Day %v", result.Value),
+			Description: describeResult(result, []string{"Birth month", "Birth day", "Year", "Month", "Day"}),
+		})
+		return true
+	})
+	return events
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_foldICalendarLine(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("é", 60)
+	folded := foldICalendarLine(line)
+	for i, l := range strings.Split(folded, "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("foldICalendarLine() line %v has %v octets", i, len(l))
+		}
+		if i > 0 && !strings.HasPrefix(l, " ") {
+			t.Errorf("foldICalendarLine() line %v does not start with a space", i)
+		}
+	}
+	if got := strings.ReplaceAll(folded, "\r\n ", ""); got != line {
+		t.Errorf("foldICalendarLine() unfolds to %v, want %v", got, line)
+	}
+}
+
+func Test_escapeICalendarText(t *testing.T) {
+	if got, want := escapeICalendarText("a,b;c\\d\ne"), `a\,b\;c\\d\ne`; got != want {
+		t.Errorf("escapeICalendarText() = %v, want %v", got, want)
+	}
+}
+
+func TestDateNumerology_PersonalDay(t *testing.T) {
+	// 7 + 6 (15) + 5 (2021) + 1 + 4 = 23 = 5
+	got := Date(NewDate(1990, 7, 15), []int{11, 22, 33}).PersonalDay(NewDate(2021, 1, 4))
+	if got.Value != 5 || len(got.Breakdown) != 5 {
+		t.Errorf("PersonalDay() = %v, want 5", got)
+	}
+}
+
+func TestWriteICalendar(t *testing.T) {
+	icalendarNow = func() time.Time { return time.Date(2021, 1, 1, 9, 30, 0, 0, time.UTC) }
+	defer func() { icalendarNow = time.Now }()
+
+	results, _ := Date(NewDate(2021, 1, 1), []int{11, 22, 33}).Search(DateSearchOpts{Count: 1, Match: []int{1}, MonthsForward: 1})
+	var buf bytes.Buffer
+	if err := WriteICalendar(&buf, DateSearchEvents(results)); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//LanderTome//numerologyCalculator//EN",
+		"CALSCALE:GREGORIAN",
+		"BEGIN:VEVENT",
+		"UID:20210104-event@numerologycalculator",
+		"DTSTAMP:20210101T093000Z",
+		"DTSTART;VALUE=DATE:20210104",
+		"DTEND;VALUE=DATE:20210105",
+		"SUMMARY:Event number 1",
+		`DESCRIPTION:Value: 1\nYear 2021 = 5\nMonth 1\nDay 4\nReduce: 10 = 1`,
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	if got := buf.String(); got != want {
+		t.Errorf("WriteICalendar() = %q, want %q", got, want)
+	}
+
+	events := Date(NewDate(1990, 7, 15), []int{11, 22, 33}).PersonalDayEvents(DateRange{NewDate(2021, 1, 1), NewDate(2021, 1, 31)})
+	if len(events) != 31 || events[3].Summary != "Personal Day 5" {
+		t.Errorf("PersonalDayEvents() = %v events, want 31 with Personal Day 5 on January 4th", len(events))
+	}
+	events = Date(NewDate(1990, 7, 15), []int{11, 22, 33}).PersonalDayEvents(DateRange{Start: NewDate(2021, 1, 4)})
+	if len(events) != 1 || events[0].Summary != "Personal Day 5" {
+		t.Errorf("PersonalDayEvents() = %v events, want Personal Day 5 for a range with a zero End", len(events))
+	}
+}
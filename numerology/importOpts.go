@@ -0,0 +1,209 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// LogLevel is how important a message is.
+type LogLevel string
+
+const (
+	LogInfo    LogLevel = "info"
+	LogWarning LogLevel = "warning"
+)
+
+// Fields are the values that go with a log message. ex. {"dictionary": "usa_census", "names": 18274}
+type Fields map[string]interface{}
+
+// Logger receives the messages of the functions that create and maintain dictionaries. The message is the same
+// for every occurrence and the details are in the fields, so that they can be passed to a structured logger.
+type Logger interface {
+	Log(level LogLevel, message string, fields Fields)
+}
+
+// LoggerFunc lets an ordinary function be used as a Logger.
+type LoggerFunc func(level LogLevel, message string, fields Fields)
+
+// Log calls f.
+func (f LoggerFunc) Log(level LogLevel, message string, fields Fields) {
+	f(level, message, fields)
+}
+
+// StandardLogger writes the messages to the standard library log with the fields as key=value pairs after the
+// message.
+var StandardLogger Logger = LoggerFunc(func(level LogLevel, message string, fields Fields) {
+	keys := []string{}
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{message}
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%v=%v", k, fields[k]))
+	}
+	log.Printf("[%v] %v", level, strings.Join(parts, " "))
+})
+
+// DefaultLogger is used by the functions that create and maintain dictionaries when there is no Logger in their
+// options, or when they have no options. It is nil by default so that nothing is logged. Set it to
+// StandardLogger to see the messages in the standard library log.
+var DefaultLogger Logger
+
+// logTo sends a message to the logger, or to DefaultLogger when the logger is nil.
+func logTo(logger Logger, level LogLevel, message string, fields Fields) {
+	if logger == nil {
+		logger = DefaultLogger
+	}
+	if logger != nil {
+		logger.Log(level, message, fields)
+	}
+}
+
+// The phases of an import that progress is reported for.
+const (
+	// ProgressReading counts the source files of a dictionary that have been read.
+	ProgressReading = "reading"
+	// ProgressInserting counts the names that have been inserted into a table.
+	ProgressInserting = "inserting"
+	// ProgressUpdating counts the names that have been inserted, updated or deleted by UpdateDatabase.
+	ProgressUpdating = "updating"
+)
+
+// ImportOpts are the options of CreateDatabaseWithOpts and UpdateDatabaseWithOpts. The zero value imports
+// quietly.
+type ImportOpts struct {
+	// Progress is called as each phase of the import of a dictionary moves along. Done counts up to total.
+	Progress func(phase string, done int, total int)
+
+	// Logger receives the messages of the import. DefaultLogger is used when it is nil.
+	Logger Logger
+}
+
+// The reasons that a dictionary is skipped by an import.
+const (
+	SkipNotEmpty = "table is not empty"
+	SkipUpToDate = "source files have not changed"
+	SkipLoaded   = "dictionary is already loaded in memory"
+)
+
+// DictionaryImport describes what an import did with one dictionary.
+type DictionaryImport struct {
+	Dictionary string `json:"dictionary"`
+
+	// Skipped is true when the dictionary was left as it was. SkipReason says why.
+	Skipped    bool   `json:"skipped"`
+	SkipReason string `json:"skip_reason,omitempty"`
+
+	// Resumed is the number of names that an interrupted import had already inserted.
+	Resumed int64 `json:"resumed,omitempty"`
+
+	// Inserted, Updated and Deleted count the names that were changed.
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Deleted  int `json:"deleted"`
+
+	// Rejected are the rows of the source files and name metadata files that could not be read.
+	Rejected []RejectedRow `json:"rejected,omitempty"`
+
+	// UnacceptableNames are the names that were left out because they have characters that cannot be calculated.
+	UnacceptableNames []string `json:"unacceptable_names,omitempty"`
+}
+
+// ImportReport describes what an import did with each of the dictionaries in the base directory.
+type ImportReport struct {
+	Dictionaries []DictionaryImport `json:"dictionaries"`
+}
+
+// importRun carries the options of an import and collects its report.
+type importRun struct {
+	opts   ImportOpts
+	report ImportReport
+}
+
+func (run *importRun) log(level LogLevel, message string, fields Fields) {
+	logTo(run.opts.Logger, level, message, fields)
+}
+
+func (run *importRun) progress(phase string, done int, total int) {
+	if run.opts.Progress != nil {
+		run.opts.Progress(phase, done, total)
+	}
+}
+
+// dictionary adds a dictionary to the report. The returned pointer is only good until the next dictionary is added.
+func (run *importRun) dictionary(name string) *DictionaryImport {
+	run.report.Dictionaries = append(run.report.Dictionaries, DictionaryImport{Dictionary: name})
+	return &run.report.Dictionaries[len(run.report.Dictionaries)-1]
+}
+
+// skip records that a dictionary was skipped.
+func (run *importRun) skip(d *DictionaryImport, reason string) {
+	d.Skipped, d.SkipReason = true, reason
+	run.log(LogInfo, "Skipping dictionary", Fields{"dictionary": d.Dictionary, "reason": reason})
+}
+
+// reject records the rows of a dictionary that could not be read.
+func (run *importRun) reject(d *DictionaryImport, rejects []RejectedRow) {
+	if len(rejects) == 0 {
+		return
+	}
+	d.Rejected = append(d.Rejected, rejects...)
+	for _, reject := range rejects {
+		run.log(LogWarning, "Rejected row", Fields{
+			"dictionary": d.Dictionary, "file": reject.File, "row": reject.Row, "reason": reject.Reason, "text": reject.Text,
+		})
+	}
+}
+
+// readNames reads the names of the source files of a dictionary directory.
+func (run *importRun) readNames(d *DictionaryImport, directory string) (namePopularity, error) {
+	run.log(LogInfo, "Extracting names", Fields{"dictionary": d.Dictionary, "directory": directory})
+	names, rejects, err := readSourceFiles(directory, func(done int, total int) {
+		run.progress(ProgressReading, done, total)
+	})
+	run.reject(d, rejects)
+	return names, err
+}
+
+// readNameMetadata reads the name metadata files of a dictionary directory.
+func (run *importRun) readNameMetadata(d *DictionaryImport, directory string) (map[string]nameMetadata, error) {
+	metadata, rejects, err := readNameMetadata(directory)
+	run.reject(d, rejects)
+	return metadata, err
+}
+
+// calculate calculates the names of a dictionary and records the names that were left out.
+func (run *importRun) calculate(d *DictionaryImport, names namePopularity) []precalculatedNumerology {
+	rows := precalculateNames(names)
+	run.unacceptable(d, names, rows)
+	return rows
+}
+
+// unacceptable records the names that precalculateNames left out of the rows because they have characters that
+// cannot be calculated.
+func (run *importRun) unacceptable(d *DictionaryImport, names namePopularity, rows []precalculatedNumerology) {
+	calculated := map[string]bool{}
+	for _, row := range rows {
+		calculated[row.Name] = true
+	}
+	for _, n := range names {
+		if !calculated[n.Name] {
+			d.UnacceptableNames = append(d.UnacceptableNames, n.Name)
+			run.log(LogWarning, "Skipping name with unacceptable characters", Fields{"dictionary": d.Dictionary, "name": n.Name})
+		}
+	}
+}
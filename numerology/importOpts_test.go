@@ -0,0 +1,127 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCreateDatabaseWithOpts(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := filepath.Join(baseDir, "report_test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeSourceFile(t, dir, "a.csv", "Mary,F,300\nJ@ck,M,200\nbroken\n")
+	writeSourceFile(t, dir, "b.csv", "John,M,100\n")
+	defer delete(memoryDictionaries, "report_test")
+
+	var phases []string
+	var messages []string
+	opts := ImportOpts{
+		Progress: func(phase string, done int, total int) {
+			if done == total {
+				phases = append(phases, phase)
+			}
+		},
+		Logger: LoggerFunc(func(level LogLevel, message string, fields Fields) {
+			if level == LogWarning {
+				messages = append(messages, message)
+			}
+		}),
+	}
+	report, err := CreateDatabaseWithOpts("memory://", baseDir, opts)
+	if err != nil {
+		t.Fatalf("CreateDatabaseWithOpts() error = %v", err)
+	}
+	want := ImportReport{Dictionaries: []DictionaryImport{{
+		Dictionary:        "report_test",
+		Inserted:          2,
+		Rejected:          []RejectedRow{{File: "a.csv", Row: 3, Text: "broken", Reason: "unknown gender"}},
+		UnacceptableNames: []string{"J@ck"},
+	}}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("CreateDatabaseWithOpts() = %+v, want %+v", report, want)
+	}
+	if want := []string{ProgressReading}; !reflect.DeepEqual(phases, want) {
+		t.Errorf("CreateDatabaseWithOpts() progress = %v, want %v", phases, want)
+	}
+	if want := []string{"Rejected row", "Skipping name with unacceptable characters"}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("CreateDatabaseWithOpts() warnings = %v, want %v", messages, want)
+	}
+
+	// A dictionary that is already loaded is skipped.
+	report, err = CreateDatabaseWithOpts("memory://", baseDir, ImportOpts{})
+	if err != nil {
+		t.Fatalf("CreateDatabaseWithOpts() error = %v", err)
+	}
+	want = ImportReport{Dictionaries: []DictionaryImport{{Dictionary: "report_test", Skipped: true, SkipReason: SkipLoaded}}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("CreateDatabaseWithOpts() = %+v, want %+v", report, want)
+	}
+}
+
+func TestUpdateDatabaseWithOpts(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := filepath.Join(baseDir, "report_update_test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeSourceFile(t, dir, "a.csv", "Mary,F,300\nJohn,M,200\n")
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	defer func() {
+		DB.Migrator().DropTable("report_update_test")
+		DB.Where("dictionary = ?", "report_update_test").Delete(&importLog{})
+		DB.Where("dictionary = ?", "report_update_test").Delete(&dictionaryMetadata{})
+		DB = nil
+	}()
+
+	report, err := CreateDatabaseWithOpts(dsn, baseDir, ImportOpts{})
+	if err != nil {
+		t.Fatalf("CreateDatabaseWithOpts() error = %v", err)
+	}
+	if got := report.Dictionaries[0].Inserted; got != 2 {
+		t.Errorf("CreateDatabaseWithOpts() inserted = %v, want 2", got)
+	}
+
+	writeSourceFile(t, dir, "a.csv", "Mary,F,300\nKelly,F,250\n")
+	var progress [][2]int
+	opts := ImportOpts{Progress: func(phase string, done int, total int) {
+		if phase == ProgressUpdating {
+			progress = append(progress, [2]int{done, total})
+		}
+	}}
+	report, err = UpdateDatabaseWithOpts(dsn, baseDir, true, opts)
+	if err != nil {
+		t.Fatalf("UpdateDatabaseWithOpts() error = %v", err)
+	}
+	d := report.Dictionaries[0]
+	if d.Inserted != 1 || d.Deleted != 1 || d.Skipped {
+		t.Errorf("UpdateDatabaseWithOpts() = %+v, want 1 inserted and 1 deleted", d)
+	}
+	if len(progress) == 0 || progress[len(progress)-1][0] != progress[len(progress)-1][1] {
+		t.Errorf("UpdateDatabaseWithOpts() progress = %v, want it to finish", progress)
+	}
+
+	// Nothing has changed so the dictionary is skipped.
+	report, err = UpdateDatabaseWithOpts(dsn, baseDir, true, ImportOpts{})
+	if err != nil {
+		t.Fatalf("UpdateDatabaseWithOpts() error = %v", err)
+	}
+	if d := report.Dictionaries[0]; !d.Skipped || d.SkipReason != SkipUpToDate {
+		t.Errorf("UpdateDatabaseWithOpts() = %+v, want skipped", d)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DictionaryProblem is a name in a dictionary that CheckDictionary found a problem with.
+type DictionaryProblem struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+	// Fields are the fields of a mismatched name whose stored values are not the values that are calculated now.
+	Fields []string `json:"fields,omitempty"`
+	// DuplicateOf is the id of the name that a duplicate name is a copy of.
+	DuplicateOf int64 `json:"duplicate_of,omitempty"`
+}
+
+// DictionaryReport is the result of checking a dictionary with CheckDictionary.
+type DictionaryReport struct {
+	Dictionary string `json:"dictionary"`
+	// Names is the number of names that were checked.
+	Names int `json:"names"`
+	// Mismatches are names whose stored values are not the values that this version of the library calculates.
+	Mismatches []DictionaryProblem `json:"mismatches,omitempty"`
+	// UnknownCharacters are names that have characters that cannot be calculated.
+	UnknownCharacters []DictionaryProblem `json:"unknown_characters,omitempty"`
+	// Duplicates are names that are in the dictionary more than once, ignoring case. The most popular copy of a
+	// name is kept and the others are the duplicates.
+	Duplicates []DictionaryProblem `json:"duplicates,omitempty"`
+	// MissingIndexes are the indexes that the table of the dictionary should have but does not.
+	MissingIndexes []string `json:"missing_indexes,omitempty"`
+	// Repaired is true when the problems were fixed.
+	Repaired bool `json:"repaired"`
+}
+
+// OK is true when no problems were found.
+func (r DictionaryReport) OK() bool {
+	return len(r.Mismatches) == 0 && len(r.UnknownCharacters) == 0 && len(r.Duplicates) == 0 && len(r.MissingIndexes) == 0
+}
+
+// mismatchedFields returns the names of the fields that are different in the two rows.
+func mismatchedFields(stored precalculatedNumerology, calculated precalculatedNumerology) (fields []string) {
+	a, b := reflect.ValueOf(stored), reflect.ValueOf(calculated)
+	for i := 0; i < a.NumField(); i++ {
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			fields = append(fields, a.Type().Field(i).Name)
+		}
+	}
+	return fields
+}
+
+// dictionaryRepairs are the changes needed to fix the problems in a dictionary.
+type dictionaryRepairs struct {
+	Updates []precalculatedNumerology
+	Deletes []precalculatedNumerology
+}
+
+// checkRows checks the rows of a dictionary and returns the changes that would fix them. Every row is calculated
+// again with the current rules, in the same way as MigrateDatabase. Names with unknown characters and duplicate
+// names are deleted by the repairs.
+func checkRows(report *DictionaryReport, rows []precalculatedNumerology) (repairs dictionaryRepairs) {
+	report.Names = len(rows)
+	sorted := make([]precalculatedNumerology, len(rows))
+	copy(sorted, rows)
+	// The most popular copy of a name comes first so that it is the one that is kept. Rows that were inserted by
+	// hand have a rank of 0, so they are the least popular.
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].PopularityRank, sorted[j].PopularityRank
+		if (a == 0) != (b == 0) {
+			return b == 0
+		}
+		if a != b {
+			return a < b
+		}
+		return sorted[i].Id < sorted[j].Id
+	})
+	kept := map[string]int64{}
+	for _, r := range sorted {
+		row, err := recalculateRow(r)
+		if err != nil {
+			report.UnknownCharacters = append(report.UnknownCharacters, DictionaryProblem{Id: r.Id, Name: r.Name})
+			repairs.Deletes = append(repairs.Deletes, r)
+			continue
+		}
+		key := strings.ToLower(r.Name)
+		if id, ok := kept[key]; ok {
+			report.Duplicates = append(report.Duplicates, DictionaryProblem{Id: r.Id, Name: r.Name, DuplicateOf: id})
+			repairs.Deletes = append(repairs.Deletes, r)
+			continue
+		}
+		kept[key] = r.Id
+		if fields := mismatchedFields(r, row); len(fields) > 0 {
+			report.Mismatches = append(report.Mismatches, DictionaryProblem{Id: r.Id, Name: r.Name, Fields: fields})
+			repairs.Updates = append(repairs.Updates, row)
+		}
+	}
+	return repairs
+}
+
+// CheckDictionary checks that every name in a dictionary has the values that this version of the library
+// calculates for it. Rows are calculated again just like MigrateDatabase does, so the ids, ranks and name
+// metadata are not checked. It also finds names with characters that cannot be calculated, names that are in the
+// dictionary more than once, and indexes that are missing from the table.
+//
+// When repair is true the problems are fixed in one transaction. Mismatched names are updated, the missing
+// indexes are created, and names with unknown characters and duplicate names are deleted. The ranks of the other
+// names are not changed. The report lists the problems that were found before they were fixed.
+func CheckDictionary(dsn string, dictionary string, repair bool) (report DictionaryReport, err error) {
+	table := strings.ToLower(dictionary)
+	report.Dictionary = table
+	if isMemoryDSN(dsn) {
+		d, ok := memoryDictionaries[table]
+		if !ok {
+			return report, fmt.Errorf("dictionary %v is not loaded in memory", table)
+		}
+		rows := d.rows()
+		repairs := checkRows(&report, rows)
+		if repair && !report.OK() {
+			memoryDictionaries[table] = newMemoryDictionary(applyRepairs(rows, repairs))
+			forgetDictionary(table)
+			report.Repaired = true
+		}
+		return report, nil
+	}
+
+	logTo(nil, LogInfo, "Connecting to database", nil)
+	if err := connectToDatabase(dsn); err != nil {
+		return report, errors.New("unable to connect to database. " + err.Error())
+	}
+	if !DB.Migrator().HasTable(table) {
+		return report, fmt.Errorf("database table %v does not exist", table)
+	}
+	var rows []precalculatedNumerology
+	if err := DB.Table(table).Find(&rows).Error; err != nil {
+		return report, err
+	}
+	if err := loadNumberSystems(DB, table, rows); err != nil {
+		return report, err
+	}
+	repairs := checkRows(&report, rows)
+	for _, idx := range dictionaryIndexes {
+		if !DB.Table(table).Migrator().HasIndex(&precalculatedNumerology{}, table+"_idx_"+idx) {
+			report.MissingIndexes = append(report.MissingIndexes, table+"_idx_"+idx)
+		}
+	}
+	logTo(nil, LogInfo, "Checked database table", Fields{
+		"dictionary": table, "mismatches": len(report.Mismatches), "unknown_characters": len(report.UnknownCharacters),
+		"duplicates": len(report.Duplicates), "missing_indexes": len(report.MissingIndexes),
+	})
+	if !repair || report.OK() {
+		return report, nil
+	}
+
+	if err := DB.AutoMigrate(&dictionaryMetadata{}); err != nil {
+		return report, err
+	}
+	if len(report.MissingIndexes) > 0 {
+		if err := setupDatabaseTable(DB, table); err != nil {
+			return report, err
+		}
+	}
+	metadata, _ := readMetadata(DB, table)
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, r := range repairs.Updates {
+			if err := tx.Table(table).Save(&r).Error; err != nil {
+				return fmt.Errorf("unable to update record in database: %v", r.Name)
+			}
+		}
+		for _, r := range repairs.Deletes {
+			if err := tx.Table(table).Delete(&precalculatedNumerology{}, r.Id).Error; err != nil {
+				return fmt.Errorf("unable to delete record from database: %v", r.Name)
+			}
+		}
+		if err := writeNumberSystems(tx, table, repairs.Updates); err != nil {
+			return err
+		}
+		if err := deleteNumberSystems(tx, table, repairs.Deletes); err != nil {
+			return err
+		}
+		// Every name now has the values of this version of the library. The source of the dictionary is still
+		// the same.
+		updated := currentMetadata(table, metadata.Source, nil)
+		updated.SourceChecksum = metadata.SourceChecksum
+		return writeMetadata(tx, updated)
+	}); err != nil {
+		return report, err
+	}
+	// The largest value and the order of the names may have changed.
+	forgetDictionary(table)
+	report.Repaired = true
+	return report, nil
+}
+
+// applyRepairs returns the rows with the repairs made to them.
+func applyRepairs(rows []precalculatedNumerology, repairs dictionaryRepairs) (repaired []precalculatedNumerology) {
+	updates := map[int64]precalculatedNumerology{}
+	for _, r := range repairs.Updates {
+		updates[r.Id] = r
+	}
+	deletes := map[int64]bool{}
+	for _, r := range repairs.Deletes {
+		deletes[r.Id] = true
+	}
+	for _, r := range rows {
+		if deletes[r.Id] {
+			continue
+		}
+		if u, ok := updates[r.Id]; ok {
+			r = u
+		}
+		repaired = append(repaired, r)
+	}
+	return repaired
+}
@@ -0,0 +1,133 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// damagedRows returns the rows of a small dictionary with one name that has a wrong value, one name with an
+// unknown character and a less popular copy of another name.
+func damagedRows() []precalculatedNumerology {
+	names := namePopularity{{"Mary", 0, 300, 0}, {"John", 200, 0, 0}, {"Kelly", 50, 60, 0}}
+	sort.Sort(sort.Reverse(names))
+	rows := precalculateNames(names)
+	rows[1].PythagoreanFull = 99
+	rows[1].P1 = 7
+	rows = append(rows,
+		precalculatedNumerology{Name: "J0hn", Gender: "M", PopularityRank: 4},
+		precalculatedNumerology{Name: "mary", Gender: "F", PopularityRank: 5},
+	)
+	for i := range rows {
+		rows[i].Id = int64(i + 1)
+	}
+	return rows
+}
+
+func Test_checkRows(t *testing.T) {
+	report := DictionaryReport{}
+	repairs := checkRows(&report, damagedRows())
+	if report.Names != 5 {
+		t.Errorf("checkRows() names = %v, want 5", report.Names)
+	}
+	if len(report.Mismatches) != 1 || report.Mismatches[0].Name != "John" {
+		t.Fatalf("checkRows() mismatches = %v", report.Mismatches)
+	}
+	if want := []string{"PythagoreanFull", "P1"}; !reflect.DeepEqual(report.Mismatches[0].Fields, want) {
+		t.Errorf("checkRows() fields = %v, want %v", report.Mismatches[0].Fields, want)
+	}
+	if len(report.UnknownCharacters) != 1 || report.UnknownCharacters[0].Name != "J0hn" {
+		t.Errorf("checkRows() unknown characters = %v", report.UnknownCharacters)
+	}
+	if len(report.Duplicates) != 1 || report.Duplicates[0].Name != "mary" || report.Duplicates[0].DuplicateOf != 1 {
+		t.Errorf("checkRows() duplicates = %v", report.Duplicates)
+	}
+	if len(repairs.Updates) != 1 || len(repairs.Deletes) != 2 {
+		t.Errorf("checkRows() repairs = %v %v", len(repairs.Updates), len(repairs.Deletes))
+	}
+}
+
+func Test_checkRowsUnranked(t *testing.T) {
+	// A copy of a name that was inserted by hand has no rank, so the ranked copy is kept.
+	ranked, err := precalculateName(nameEntry{"Mary", 0, 450, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranked.Id, ranked.PopularityRank, ranked.FemaleRank = 1, 3, 1
+	unranked := ranked
+	unranked.Id, unranked.PopularityRank, unranked.FemaleRank = 2, 0, 0
+	report := DictionaryReport{}
+	repairs := checkRows(&report, []precalculatedNumerology{unranked, ranked})
+	if len(report.Duplicates) != 1 || report.Duplicates[0].Id != 2 || report.Duplicates[0].DuplicateOf != 1 {
+		t.Errorf("checkRows() duplicates = %v, want the unranked copy", report.Duplicates)
+	}
+	if len(repairs.Deletes) != 1 || repairs.Deletes[0].Id != 2 {
+		t.Errorf("checkRows() deletes = %v, want the unranked copy", repairs.Deletes)
+	}
+}
+
+func Test_CheckDictionary(t *testing.T) {
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	if err := connectToDatabase(dsn); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DB.Migrator().DropTable("integrity_test")
+		DB.Where("dictionary = ?", "integrity_test").Delete(&dictionaryMetadata{})
+		delete(memoryDictionaries, "integrity_test")
+		DB = nil
+	}()
+	if err := setupDatabaseTable(DB, "integrity_test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Table("integrity_test").CreateInBatches(damagedRows(), insertBatchSize).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Table("integrity_test").Migrator().DropIndex(&precalculatedNumerology{}, "integrity_test_idx_soundex"); err != nil {
+		t.Fatal(err)
+	}
+	memoryDictionaries["integrity_test"] = newMemoryDictionary(damagedRows())
+
+	for _, dsn := range []string{dsn, "memory://"} {
+		t.Run(dsn, func(t *testing.T) {
+			report, err := CheckDictionary(dsn, "Integrity_Test", false)
+			if err != nil {
+				t.Fatalf("CheckDictionary() error = %v", err)
+			}
+			if report.OK() || report.Repaired {
+				t.Fatalf("CheckDictionary() report = %+v", report)
+			}
+			if len(report.Mismatches) != 1 || len(report.UnknownCharacters) != 1 || len(report.Duplicates) != 1 {
+				t.Errorf("CheckDictionary() report = %+v", report)
+			}
+			if dsn != "memory://" && !reflect.DeepEqual(report.MissingIndexes, []string{"integrity_test_idx_soundex"}) {
+				t.Errorf("CheckDictionary() missing indexes = %v", report.MissingIndexes)
+			}
+
+			report, err = CheckDictionary(dsn, "integrity_test", true)
+			if err != nil || !report.Repaired {
+				t.Fatalf("CheckDictionary() repair = %+v, error = %v", report, err)
+			}
+			report, err = CheckDictionary(dsn, "integrity_test", false)
+			if err != nil || !report.OK() || report.Names != 3 {
+				t.Errorf("CheckDictionary() after repair = %+v, error = %v", report, err)
+			}
+		})
+	}
+
+	if _, err := CheckDictionary("memory://", "missing", false); err == nil {
+		t.Errorf("CheckDictionary() of a missing dictionary did not return an error")
+	}
+}
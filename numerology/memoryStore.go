@@ -0,0 +1,659 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"fmt"
+	"gorm.io/gorm/schema"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// memoryDictionaries holds the dictionaries that have been loaded into memory. The key is the lowercase
+// dictionary name.
+var memoryDictionaries = map[string]*memoryDictionary{}
+
+// bitmap is a set of row positions. Bit i is set when row i is in the set.
+type bitmap []uint64
+
+func newBitmap(rows int) bitmap {
+	return make(bitmap, (rows+63)/64)
+}
+
+func (b bitmap) set(i int) {
+	b[i/64] |= 1 << uint(i%64)
+}
+
+func (b bitmap) has(i int) bool {
+	return b[i/64]&(1<<uint(i%64)) != 0
+}
+
+// and keeps only the rows that are also in o.
+func (b bitmap) and(o bitmap) {
+	for i := range b {
+		b[i] &= o[i]
+	}
+}
+
+// or adds the rows that are in o.
+func (b bitmap) or(o bitmap) {
+	for i := range b {
+		b[i] |= o[i]
+	}
+}
+
+// memoryDictionary is a dictionary that is held in memory as columns of values instead of rows. Indexed columns
+// also have a bitmap for every value so that lookups do not have to look at every row.
+type memoryDictionary struct {
+	ids []int64
+	// ranks holds the rank columns by their database column name. ex. popularity_rank, male_rank
+	ranks map[string][]int64
+	// orders holds the rows of each rank column in order of rank. Rows without a rank are left out.
+	orders             map[string][]int
+	names              []string
+	genders            []string
+	maleCounts         []int64
+	femaleCounts       []int64
+	unisexRatios       []float64
+	trends             []float64
+	origins            []string
+	meanings           []string
+	variants           []string
+	nicknames          []string
+	soundex            []string
+	metaphonePrimary   []string
+	metaphoneAlternate []string
+	// columns holds the numerological columns by their database column name. ex. pythagorean_full, p1
+	columns map[string][]uint8
+	// bitmaps holds the rows for each value of the indexed numerological columns.
+	bitmaps map[string]map[uint8]bitmap
+	// genderBitmaps holds the rows of the names that have been used for each gender.
+	genderBitmaps map[string]bitmap
+	largest       int
+	// numberSystems are the keys of the registered number systems that the dictionary has values for. Their
+	// values are in columns that are named by numberSystemColumns.
+	numberSystems []string
+	// fingerprint is the numberSystemsFingerprint of when the dictionary was calculated.
+	fingerprint string
+}
+
+// memoryColumn is a numerological field of precalculatedNumerology and the name of its database column. The
+// columns of a registered number system have the key of the number system and the number of the value instead of
+// a field. The number is 0 for the full value, -1 for the vowels and -2 for the consonants, and otherwise the
+// number that is counted.
+type memoryColumn struct {
+	field        int
+	name         string
+	indexed      bool
+	numberSystem string
+	number       int
+}
+
+// registeredMemoryColumns are the columns of a registered number system.
+func registeredMemoryColumns(c numberSystemColumns) []memoryColumn {
+	columns := []memoryColumn{
+		{name: c.Full, indexed: true, numberSystem: c.Key, number: 0},
+		{name: c.Vowels, indexed: true, numberSystem: c.Key, number: -1},
+		{name: c.Consonants, indexed: true, numberSystem: c.Key, number: -2},
+	}
+	for n := 1; n <= 9; n++ {
+		columns = append(columns, memoryColumn{name: c.Count(n), numberSystem: c.Key, number: n})
+	}
+	return columns
+}
+
+// value returns the value of the column of a registered number system.
+func (c memoryColumn) value(v precalculatedSystemNumerology) uint8 {
+	switch c.number {
+	case 0:
+		return v.FullValue
+	case -1:
+		return v.VowelsValue
+	case -2:
+		return v.ConsonantsValue
+	}
+	return v.count(c.number)
+}
+
+// setValue sets the value of the column of a registered number system.
+func (c memoryColumn) setValue(v *precalculatedSystemNumerology, value uint8) {
+	switch c.number {
+	case 0:
+		v.FullValue = value
+	case -1:
+		v.VowelsValue = value
+	case -2:
+		v.ConsonantsValue = value
+	default:
+		v.setCount(c.number, value)
+	}
+}
+
+// memoryColumns finds the numerological fields of precalculatedNumerology. The column names are the ones that
+// Gorm uses so that a nameQuery refers to the same columns no matter which store it is run against.
+func memoryColumns() (columns []memoryColumn) {
+	naming := schema.NamingStrategy{}
+	t := reflect.TypeOf(precalculatedNumerology{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type.Kind() != reflect.Uint8 {
+			continue
+		}
+		columns = append(columns, memoryColumn{field: i, name: naming.ColumnName("", f.Name), indexed: strings.Contains(f.Tag.Get("gorm"), "index")})
+	}
+	return columns
+}
+
+// newMemoryDictionary builds a memoryDictionary from the rows of a dictionary.
+func newMemoryDictionary(rows []precalculatedNumerology) *memoryDictionary {
+	d := &memoryDictionary{
+		ranks:   map[string][]int64{},
+		orders:  map[string][]int{},
+		columns: map[string][]uint8{},
+		bitmaps: map[string]map[uint8]bitmap{},
+		genderBitmaps: map[string]bitmap{
+			"M": newBitmap(len(rows)),
+			"F": newBitmap(len(rows)),
+		},
+	}
+	d.fingerprint = numberSystemsFingerprint()
+	columns := memoryColumns()
+	for _, ns := range registeredNumberSystems() {
+		d.numberSystems = append(d.numberSystems, numberSystemKey(ns))
+		columns = append(columns, registeredMemoryColumns(columnsOf(ns))...)
+	}
+	for _, c := range columns {
+		d.columns[c.name] = make([]uint8, len(rows))
+		if c.indexed {
+			d.bitmaps[c.name] = map[uint8]bitmap{}
+		}
+	}
+	for i, r := range rows {
+		d.ids = append(d.ids, r.Id)
+		for _, column := range []string{"popularity_rank", "male_rank", "female_rank"} {
+			d.ranks[column] = append(d.ranks[column], r.rank(column))
+		}
+		d.names = append(d.names, r.Name)
+		d.genders = append(d.genders, r.Gender)
+		d.maleCounts = append(d.maleCounts, r.MaleCount)
+		d.femaleCounts = append(d.femaleCounts, r.FemaleCount)
+		d.unisexRatios = append(d.unisexRatios, r.UnisexRatio)
+		d.trends = append(d.trends, r.Trend)
+		d.origins = append(d.origins, r.Origin)
+		d.meanings = append(d.meanings, r.Meaning)
+		d.variants = append(d.variants, r.Variants)
+		d.nicknames = append(d.nicknames, r.Nicknames)
+		d.soundex = append(d.soundex, r.Soundex)
+		d.metaphonePrimary = append(d.metaphonePrimary, r.MetaphonePrimary)
+		d.metaphoneAlternate = append(d.metaphoneAlternate, r.MetaphoneAlternate)
+		if r.MaleRank > 0 {
+			d.genderBitmaps["M"].set(i)
+		}
+		if r.FemaleRank > 0 {
+			d.genderBitmaps["F"].set(i)
+		}
+
+		v := reflect.ValueOf(r)
+		for _, c := range columns {
+			var value uint8
+			if c.numberSystem != "" {
+				value = c.value(r.NumberSystems[c.numberSystem])
+			} else {
+				value = uint8(v.Field(c.field).Uint())
+			}
+			d.columns[c.name][i] = value
+			if c.indexed {
+				if _, ok := d.bitmaps[c.name][value]; !ok {
+					d.bitmaps[c.name][value] = newBitmap(len(rows))
+				}
+				d.bitmaps[c.name][value].set(i)
+			}
+		}
+		if int(r.PythagoreanFull) > d.largest {
+			d.largest = int(r.PythagoreanFull)
+		}
+		if int(r.ChaldeanFull) > d.largest {
+			d.largest = int(r.ChaldeanFull)
+		}
+		for _, values := range r.NumberSystems {
+			if int(values.FullValue) > d.largest {
+				d.largest = int(values.FullValue)
+			}
+		}
+	}
+	for column, ranks := range d.ranks {
+		order := []int{}
+		for i, rank := range ranks {
+			if rank > 0 {
+				order = append(order, i)
+			}
+		}
+		sort.Slice(order, func(i, j int) bool { return ranks[order[i]] < ranks[order[j]] })
+		d.orders[column] = order
+	}
+	return d
+}
+
+// rows rebuilds the rows of the dictionary from its columns.
+func (d *memoryDictionary) rows() []precalculatedNumerology {
+	columns := memoryColumns()
+	rows := make([]precalculatedNumerology, len(d.ids))
+	for i := range rows {
+		rows[i] = precalculatedNumerology{
+			Id:                 d.ids[i],
+			Name:               d.names[i],
+			Gender:             d.genders[i],
+			PopularityRank:     d.ranks["popularity_rank"][i],
+			MaleRank:           d.ranks["male_rank"][i],
+			FemaleRank:         d.ranks["female_rank"][i],
+			MaleCount:          d.maleCounts[i],
+			FemaleCount:        d.femaleCounts[i],
+			UnisexRatio:        d.unisexRatios[i],
+			Trend:              d.trends[i],
+			Origin:             d.origins[i],
+			Meaning:            d.meanings[i],
+			Variants:           d.variants[i],
+			Nicknames:          d.nicknames[i],
+			Soundex:            d.soundex[i],
+			MetaphonePrimary:   d.metaphonePrimary[i],
+			MetaphoneAlternate: d.metaphoneAlternate[i],
+		}
+		v := reflect.ValueOf(&rows[i]).Elem()
+		for _, c := range columns {
+			v.Field(c.field).SetUint(uint64(d.columns[c.name][i]))
+		}
+		for _, key := range d.numberSystems {
+			if rows[i].NumberSystems == nil {
+				rows[i].NumberSystems = map[string]precalculatedSystemNumerology{}
+			}
+			values := precalculatedSystemNumerology{NumberSystem: key}
+			for _, c := range registeredMemoryColumns(numberSystemColumnsOfKey(key)) {
+				c.setValue(&values, d.columns[c.name][i])
+			}
+			rows[i].NumberSystems[key] = values
+		}
+	}
+	return rows
+}
+
+// isMemoryDSN reports whether the DSN refers to the in-memory dictionaries instead of a SQL database.
+func isMemoryDSN(dsn string) bool {
+	return strings.HasPrefix(strings.ToLower(dsn), "memory:")
+}
+
+// createMemoryDatabase loads each folder in the baseDir into memory as a dictionary. Dictionaries that are
+// already loaded are skipped just like tables that are not empty.
+func (run *importRun) createMemoryDatabase(baseDir string) error {
+	for _, dir := range getAllDirectories(baseDir) {
+		dictionary := strings.ToLower(dir)
+		report := run.dictionary(dictionary)
+		if d, ok := memoryDictionaries[dictionary]; ok && len(d.ids) > 0 {
+			run.skip(report, SkipLoaded)
+			continue
+		}
+		names, err := run.readNames(report, filepath.Join(baseDir, dir))
+		if err != nil {
+			return err
+		}
+		rows := run.calculate(report, names)
+		metadata, err := run.readNameMetadata(report, filepath.Join(baseDir, dir))
+		if err != nil {
+			return err
+		}
+		applyNameMetadata(rows, metadata)
+		report.Inserted = len(rows)
+		for i := range rows {
+			// Ids are given out in order just like an auto increment primary key.
+			rows[i].Id = int64(i + 1)
+		}
+		memoryDictionaries[dictionary] = newMemoryDictionary(rows)
+		forgetDictionary(dictionary)
+	}
+	return nil
+}
+
+// memoryStore searches the dictionaries that are loaded in memory. It returns the same results as the SQL
+// databases for the same query. Dictionaries is either memoryDictionaries or the embedded dictionary.
+type memoryStore struct {
+	dictionaries map[string]*memoryDictionary
+}
+
+func (s memoryStore) count(dictionary string) int64 {
+	if d, ok := s.dictionaries[dictionary]; ok {
+		return int64(len(d.ids))
+	}
+	return 0
+}
+
+func (s memoryStore) largestValue(dictionary string) int {
+	if d, ok := s.dictionaries[dictionary]; ok {
+		return d.largest
+	}
+	return 100
+}
+
+// metadata is current because the dictionaries in memory are calculated when they are loaded, unless a number
+// system has been registered since then.
+func (s memoryStore) metadata(dictionary string) (dictionaryMetadata, bool) {
+	metadata := currentMetadata(dictionary, "", nil)
+	if d, ok := s.dictionaries[dictionary]; ok {
+		// A number system may have been registered since the dictionary was calculated.
+		metadata.NumberSystems = d.fingerprint
+	}
+	return metadata, true
+}
+
+func (s memoryStore) supportsPattern() bool {
+	return true
+}
+
+func (s memoryStore) explain(dictionary string, q nameQuery, sort string, offset int, seed int64, limit int) string {
+	return ""
+}
+
+// missingColumns is always empty because the dictionaries in memory are loaded with every column.
+func (s memoryStore) missingColumns(dictionary string) []string {
+	return nil
+}
+
+func (s memoryStore) find(dictionary string, q nameQuery, sortBy string, offset int, seed int64, limit int) (names []precalculatedNumerology, err error) {
+	d, ok := s.dictionaries[dictionary]
+	if !ok {
+		return nil, nil
+	}
+	// The pattern and the columns of the conditions are checked once so that every row does not have to.
+	var pattern *regexp.Regexp
+	if q.Pattern != "" {
+		if pattern, err = regexp.Compile(q.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid name pattern: %v", err)
+		}
+	}
+	for _, group := range q.Conditions {
+		for _, c := range group {
+			for _, column := range []string{c.Left, c.Right} {
+				if _, ok := d.columns[column]; column != "" && !ok {
+					return nil, fmt.Errorf("unable to search dictionary %v: unknown column %v", dictionary, column)
+				}
+			}
+		}
+	}
+	// Common and uncommon sorts start at the first row with a rank of at least start and stop after the last row
+	// with a rank of at most end.
+	var start, end int64
+	if limit > 0 {
+		switch strings.ToLower(sortBy) {
+		case UncommonSort:
+			start = q.UncommonRank
+			if int64(offset) > start {
+				start = int64(offset)
+			}
+		case RandomSort:
+		default:
+			start = int64(offset)
+			end = q.CommonRank
+		}
+	}
+	rows := d.match(q)
+
+	ranks, order := d.ranks[q.RankColumn], d.orders[q.RankColumn]
+	first := sort.Search(len(order), func(i int) bool { return ranks[order[i]] >= start })
+	for _, i := range order[first:] {
+		if end > 0 && ranks[i] > end {
+			break
+		}
+		if rows.has(i) && d.matchRow(i, q, pattern) {
+			names = append(names, precalculatedNumerology{
+				Id:             d.ids[i],
+				Name:           d.names[i],
+				PopularityRank: d.ranks["popularity_rank"][i],
+				MaleRank:       d.ranks["male_rank"][i],
+				FemaleRank:     d.ranks["female_rank"][i],
+				Origin:         d.origins[i],
+				Meaning:        d.meanings[i],
+				Variants:       d.variants[i],
+				Nicknames:      d.nicknames[i],
+			})
+		}
+	}
+	if limit == 0 {
+		return names, nil
+	}
+
+	if strings.ToLower(sortBy) == RandomSort {
+		sortLikeSQLiteRandom(names, randomSortMultiplier(seed))
+		if offset >= len(names) {
+			return nil, nil
+		}
+		names = names[offset:]
+	}
+	if len(names) > limit {
+		names = names[:limit]
+	}
+	return names, nil
+}
+
+// match uses the bitmaps to find the rows that satisfy the gender and the lookups of the query.
+func (d *memoryDictionary) match(q nameQuery) bitmap {
+	rows := newBitmap(len(d.ids))
+	for i := range rows {
+		rows[i] = ^uint64(0)
+	}
+	if g, ok := d.genderBitmaps[q.Gender]; ok {
+		rows.and(g)
+	}
+	for _, l := range q.Lookups {
+		values := newBitmap(len(d.ids))
+		for _, v := range l.Values {
+			if v < 0 || v > 255 {
+				continue
+			}
+			if b, ok := d.bitmaps[l.Column][uint8(v)]; ok {
+				values.or(b)
+			}
+		}
+		rows.and(values)
+	}
+	// Clear the bits past the last row.
+	if extra := len(rows)*64 - len(d.ids); extra > 0 {
+		rows[len(rows)-1] &= ^uint64(0) >> uint(extra)
+	}
+	return rows
+}
+
+// matchRow checks the parts of the query that the bitmaps cannot answer. The pattern is the compiled q.Pattern.
+func (d *memoryDictionary) matchRow(i int, q nameQuery, pattern *regexp.Regexp) bool {
+	name := d.names[i]
+	lower := strings.ToLower(name)
+	if q.Gender == "U" && d.unisexRatios[i] < q.UnisexRatio {
+		return false
+	}
+	if len(q.Origins) > 0 && !inStringSlice(strings.ToLower(d.origins[i]), q.Origins) {
+		return false
+	}
+	switch trend := d.trends[i]; q.Trend {
+	case TrendingNames:
+		if trend < trendingThreshold {
+			return false
+		}
+	case ClassicNames:
+		if trend < -classicThreshold || trend > classicThreshold {
+			return false
+		}
+	}
+	if q.Like != "" && !likeMatch(q.Like, lower) {
+		return false
+	}
+	length := utf8.RuneCountInString(name)
+	if (q.MinLength > 0 && length < q.MinLength) || (q.MaxLength > 0 && length > q.MaxLength) {
+		return false
+	}
+	if len(q.FirstLetters) > 0 {
+		r, _ := utf8.DecodeRuneInString(name)
+		if !inStringSlice(strings.ToLower(string(r)), q.FirstLetters) {
+			return false
+		}
+	}
+	if len(q.Exclude) > 0 && inStringSlice(lower, q.Exclude) {
+		return false
+	}
+	if len(q.Include) > 0 && !inStringSlice(lower, q.Include) {
+		return false
+	}
+	if pattern != nil && !pattern.MatchString(name) {
+		return false
+	}
+	if q.SoundsLike != nil {
+		metaphones := q.SoundsLike.metaphones()
+		if d.soundex[i] != q.SoundsLike.Soundex &&
+			!inStringSlice(d.metaphonePrimary[i], metaphones) &&
+			!inStringSlice(d.metaphoneAlternate[i], metaphones) {
+			return false
+		}
+	}
+	for _, group := range q.Conditions {
+		if !d.matchGroup(i, group) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchGroup checks whether any of the conditions of the group are satisfied by the row. The columns of the
+// conditions have already been checked by find.
+func (d *memoryDictionary) matchGroup(i int, group conditionGroup) bool {
+	if len(group) == 0 {
+		return true
+	}
+	for _, c := range group {
+		left := int(d.columns[c.Left][i])
+		right := c.Adjustment
+		if c.Right != "" {
+			right += int(d.columns[c.Right][i])
+		}
+		var ok bool
+		switch c.Comparator {
+		case "=":
+			ok = left == right
+		case "<":
+			ok = left < right
+		case "<=":
+			ok = left <= right
+		case ">":
+			ok = left > right
+		case ">=":
+			ok = left >= right
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// likeMatch reports whether s matches a LIKE pattern where '%' matches any number of characters and '_'
+// matches a single character.
+func likeMatch(pattern string, s string) bool {
+	p, t := []rune(pattern), []rune(s)
+	// Position to go back to when the characters after a '%' stop matching.
+	star, mark := -1, 0
+	i, j := 0, 0
+	for j < len(t) {
+		switch {
+		case i < len(p) && (p[i] == '_' || p[i] == t[j]):
+			i++
+			j++
+		case i < len(p) && p[i] == '%':
+			star, mark = i, j
+			i++
+		case star >= 0:
+			mark++
+			i, j = star+1, mark
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '%' {
+		i++
+	}
+	return i == len(p)
+}
+
+// sortLikeSQLiteRandom puts names in the same order as the random sort of SQLite, which orders by part of the
+// text of id * multiplier. See gormStore.addSort.
+func sortLikeSQLiteRandom(names []precalculatedNumerology, multiplier float64) {
+	keys := map[int64]string{}
+	for _, n := range names {
+		id := strconv.FormatInt(n.Id, 10)
+		text := sqliteRealText(float64(n.Id) * multiplier)
+		// substr is 1 based so skipping length(id) + 1 characters starts at length(id) + 2.
+		if len(text) > len(id)+1 {
+			keys[n.Id] = text[len(id)+1:]
+		} else {
+			keys[n.Id] = ""
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		a, b := keys[names[i].Id], keys[names[j].Id]
+		if a != b {
+			return a < b
+		}
+		return names[i].Id < names[j].Id
+	})
+}
+
+// sqliteRealText converts a floating point number to text the way SQLite does, which is printf("%!.15g").
+// That is 15 significant digits without trailing zeros, but always with at least one digit after the
+// decimal point.
+func sqliteRealText(r float64) string {
+	if r == 0 {
+		return "0.0"
+	}
+	sign := ""
+	if r < 0 {
+		sign = "-"
+		r = -r
+	}
+	// Mantissa digits and exponent. ex. 1.23450000000000e+04
+	e := strconv.FormatFloat(r, 'e', 14, 64)
+	parts := strings.Split(e, "e")
+	digits := strings.Replace(parts[0], ".", "", 1)
+	exp, _ := strconv.Atoi(parts[1])
+
+	withPoint := func(whole string, fraction string) string {
+		fraction = strings.TrimRight(fraction, "0")
+		if fraction == "" {
+			fraction = "0"
+		}
+		return whole + "." + fraction
+	}
+	if exp < -4 || exp > 14 {
+		expSign := "+"
+		if exp < 0 {
+			expSign = "-"
+			exp = -exp
+		}
+		expText := strconv.Itoa(exp)
+		if len(expText) < 2 {
+			expText = "0" + expText
+		}
+		return sign + withPoint(digits[:1], digits[1:]) + "e" + expSign + expText
+	}
+	if exp < 0 {
+		return sign + withPoint("0", strings.Repeat("0", -exp-1)+digits)
+	}
+	return sign + withPoint(digits[:exp+1], digits[exp+1:])
+}
@@ -0,0 +1,187 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"reflect"
+	"testing"
+)
+
+func init() {
+	if err := CreateDatabase("memory://", "test_names"); err != nil {
+		panic("unable to create in-memory dictionaries for testing.")
+	}
+}
+
+func Test_memoryStoreMatchesSQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		opts  NameSearchOpts
+	}{
+		{"Common", "?", NameSearchOpts{Count: 50, Full: []int{1, 4}}},
+		{"Uncommon", "?", NameSearchOpts{Count: 50, Sort: UncommonSort, Vowels: []int{3, -11}}},
+		{"UncommonOffset", "?", NameSearchOpts{Count: 50, Offset: 8000, Sort: UncommonSort, Consonants: []int{-9}}},
+		{"Random", "?", NameSearchOpts{Count: 50, Sort: RandomSort, Seed: 3384983, Full: []int{22}}},
+		{"RandomOffset", "?", NameSearchOpts{Count: 50, Offset: 75, Sort: RandomSort, Seed: 42}},
+		{"Gender", "? Doe", NameSearchOpts{Count: 50, Gender: Gender('F'), Full: []int{1, 8}}},
+		{"Male", "?", NameSearchOpts{Count: 50, Offset: 300, Gender: Male, Vowels: []int{6}}},
+		{"Unisex", "?", NameSearchOpts{Count: 50, Gender: Unisex, UnisexWithin: 20}},
+		{"CommonRank", "?", NameSearchOpts{Count: 50, CommonRank: 60, Full: []int{1, 2, 3}}},
+		{"UncommonRank", "?", NameSearchOpts{Count: 50, Sort: UncommonSort, UncommonRank: 9000, Gender: Female}},
+		{"HiddenPassions", "Jane ? Doe", NameSearchOpts{Count: 50, HiddenPassions: []int{5, -1}}},
+		{"NegativeHiddenPassions", "Jane ? Doe", NameSearchOpts{Count: 50, HiddenPassions: []int{-1, -5}}},
+		{"KarmicLessons", "Jane ? Doe", NameSearchOpts{Count: 50, Offset: 200, KarmicLessons: []int{2, -8}}},
+		{"Like", "Ja?", NameSearchOpts{Count: 50, Sort: RandomSort, Seed: 7}},
+		{"NameConstraints", "?", NameSearchOpts{Count: 50, MinLength: 4, MaxLength: 6, FirstLetters: "ms", Exclude: []string{"Mary"}}},
+		{"Pattern", "?", NameSearchOpts{Count: 50, Pattern: "^[A-Z][a-z]+ie$"}},
+		{"SoundsLike", "?", NameSearchOpts{Count: 50, SoundsLike: "Catherine"}},
+		{"Chaldean", "? Doe", NameSearchOpts{Count: 50, Full: []int{5}, HiddenPassions: []int{2}}},
+		{"NoResults", "?", NameSearchOpts{Count: 50, Full: []int{4}, Vowels: []int{-1, -2, -3, -4, -5, -6, -7, -8, -9, -11, -22, -33}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			numberSystem := Pythagorean
+			if tt.name == "Chaldean" {
+				numberSystem = Chaldean
+			}
+			opts := tt.opts
+			opts.Dictionary = "usa_census"
+			opts.Database = "sqlite://file::memory:?cache=shared"
+			sqlResults, sqlOffset, err := nameSearch(tt.query, numberSystem, []int{11, 22, 33}, true, opts)
+			if err != nil {
+				t.Fatalf("nameSearch() error = %v", err)
+			}
+			opts.Database = "memory://"
+			memoryResults, memoryOffset, err := nameSearch(tt.query, numberSystem, []int{11, 22, 33}, true, opts)
+			if err != nil {
+				t.Fatalf("nameSearch() error = %v", err)
+			}
+			sqlNames, memoryNames := []string{}, []string{}
+			for _, r := range sqlResults {
+				sqlNames = append(sqlNames, r.Name)
+			}
+			for _, r := range memoryResults {
+				memoryNames = append(memoryNames, r.Name)
+			}
+			if !reflect.DeepEqual(sqlNames, memoryNames) || sqlOffset != memoryOffset {
+				t.Errorf("memory results do not match SQL. memory = %v %v, sql = %v %v", memoryNames, memoryOffset, sqlNames, sqlOffset)
+			}
+		})
+	}
+	DB = nil
+}
+
+func Test_memoryStoreNotLoaded(t *testing.T) {
+	_, _, err := nameSearch("?", Pythagorean, []int{}, true, NameSearchOpts{Dictionary: "unknown", Database: "memory://"})
+	if err == nil {
+		t.Errorf("nameSearch() expected error for a dictionary that is not loaded")
+	}
+}
+
+func Test_memoryStoreInvalidQuery(t *testing.T) {
+	store := memoryStore{memoryDictionaries}
+	tests := []struct {
+		name string
+		q    nameQuery
+	}{
+		{"Pattern", nameQuery{RankColumn: "popularity_rank", Pattern: "[a-"}},
+		{"Column", nameQuery{RankColumn: "popularity_rank", Conditions: []conditionGroup{{{Left: "missing", Comparator: "=", Adjustment: 1}}}}},
+		{"RightColumn", nameQuery{RankColumn: "popularity_rank", Conditions: []conditionGroup{{{Left: "p1", Right: "missing", Comparator: "="}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := store.find("usa_census", tt.q, CommonSort, 0, 0, 10); err == nil {
+				t.Errorf("find() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func Test_likeMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"ja%", "jane", true},
+		{"ja%", "ajax", false},
+		{"%an%", "jane", true},
+		{"%e", "jane", true},
+		{"j_ne", "jane", true},
+		{"j_ne", "jeanne", false},
+		{"%a%a%", "anna", true},
+		{"%a%a%", "jane", false},
+		{"%", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.s, func(t *testing.T) {
+			if got := likeMatch(tt.pattern, tt.s); got != tt.want {
+				t.Errorf("likeMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sqliteRealText(t *testing.T) {
+	tests := []struct {
+		r    float64
+		want string
+	}{
+		{0, "0.0"},
+		{3, "3.0"},
+		{0.5, "0.5"},
+		{-2.25, "-2.25"},
+		{1234.5678, "1234.5678"},
+		{0.1 + 0.2, "0.3"},
+		{0.00001, "1.0e-05"},
+		{0.0001234, "0.0001234"},
+		{1e20, "1.0e+20"},
+		{2.0 / 3.0, "0.666666666666667"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := sqliteRealText(tt.r); got != tt.want {
+				t.Errorf("sqliteRealText() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_bitmap(t *testing.T) {
+	rowsIn := func(b bitmap) (rows []int) {
+		for i := 0; i < 130; i++ {
+			if b.has(i) {
+				rows = append(rows, i)
+			}
+		}
+		return rows
+	}
+	b := newBitmap(130)
+	for _, i := range []int{0, 63, 64, 129} {
+		b.set(i)
+	}
+	o := newBitmap(130)
+	o.set(64)
+	o.set(100)
+	if got := rowsIn(b); !reflect.DeepEqual(got, []int{0, 63, 64, 129}) {
+		t.Errorf("bitmap.set() = %v", got)
+	}
+	b.and(o)
+	if got := rowsIn(b); !reflect.DeepEqual(got, []int{64}) {
+		t.Errorf("bitmap.and() = %v", got)
+	}
+	b.or(o)
+	if got := rowsIn(b); !reflect.DeepEqual(got, []int{64, 100}) {
+		t.Errorf("bitmap.or() = %v", got)
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LibraryVersion is the version of this library. It is recorded with every dictionary that it creates.
+const LibraryVersion = "1.0.0"
+
+// schemaVersion is the version of the layout of the dictionary tables. Change it whenever the columns of
+// precalculatedNumerology change. Tables from before version 2 stored a row per name and gender, and tables from
+// before version 3 have no trend. Tables from before version 4 have no name metadata.
+const schemaVersion = 4
+
+// vowelPolicy is the version of the rules that decide whether a letter is a vowel, in particular the rules for
+// "Y" in maskConstructor. Change it whenever those rules change.
+const vowelPolicy = "y-rules-1"
+
+// transliteration is the version of the conversion of names to ASCII by ToAscii. Change it whenever that
+// conversion changes.
+const transliteration = "unidecode-1"
+
+// dictionaryMetadata records how the precalculated values of a dictionary were produced. Searches refuse to use
+// a dictionary that was produced by rules that are different from the ones in this version of the library.
+type dictionaryMetadata struct {
+	Dictionary      string `gorm:"primaryKey;type:varchar(255)"`
+	SchemaVersion   int
+	LibraryVersion  string
+	VowelPolicy     string
+	Transliteration string
+	// NumberSystems are the names of the number systems and the checksums of their conversion tables.
+	NumberSystems string
+	// Source is the directory of the source files and SourceChecksum is the checksum of their checksums.
+	Source         string
+	SourceChecksum string `gorm:"type:varchar(64)"`
+	UpdatedAt      time.Time
+}
+
+// TableName is the name of the table that the metadata of every dictionary is stored in.
+func (dictionaryMetadata) TableName() string {
+	return "dictionary_metadata"
+}
+
+// dictionaryKey identifies a dictionary by the DSN of its database and its table name, because databases can
+// have tables with the same name.
+type dictionaryKey struct {
+	dsn   string
+	table string
+}
+
+// dictionarySet is a set of dictionaries that can be used by concurrent searches.
+type dictionarySet struct {
+	mu           sync.RWMutex
+	dictionaries map[dictionaryKey]bool
+}
+
+func (s *dictionarySet) has(dsn string, table string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dictionaries[dictionaryKey{dsn, table}]
+}
+
+func (s *dictionarySet) add(dsn string, table string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dictionaries[dictionaryKey{dsn, table}] = true
+}
+
+// remove drops a table from the set for every database that has it.
+func (s *dictionarySet) remove(table string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.dictionaries {
+		if key.table == table {
+			delete(s.dictionaries, key)
+		}
+	}
+}
+
+func (s *dictionarySet) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dictionaries = map[dictionaryKey]bool{}
+}
+
+// compatibleDictionaries caches the dictionaries whose metadata has been checked so that it is not looked up
+// again on every search.
+var compatibleDictionaries = &dictionarySet{dictionaries: map[dictionaryKey]bool{}}
+
+// numberSystemsFingerprint describes the number systems that are precalculated and the contents of their
+// conversion tables, including the ones that were added by RegisterNumberSystem. ex. chaldean:1a2b3c4d,pythagorean:5e6f7a8b
+func numberSystemsFingerprint() string {
+	fingerprints := []string{}
+	sorted := append([]NumberSystem{}, numberSystems...)
+	sort.Slice(sorted, func(i, j int) bool { return numberSystemKey(sorted[i]) < numberSystemKey(sorted[j]) })
+	for _, ns := range sorted {
+		letters := []int{}
+		for letter := range ns.NumberMapping {
+			letters = append(letters, int(letter))
+		}
+		sort.Ints(letters)
+		hash := sha256.New()
+		for _, letter := range letters {
+			hash.Write([]byte(fmt.Sprintf("%c=%v;", letter, ns.NumberMapping[int32(letter)])))
+		}
+		fingerprints = append(fingerprints, fmt.Sprintf("%v:%v", strings.ToLower(ns.Name), hex.EncodeToString(hash.Sum(nil))[:8]))
+	}
+	return strings.Join(fingerprints, ",")
+}
+
+// sourceChecksum combines the checksums of the source files of a dictionary into one.
+func sourceChecksum(files []sourceFile) string {
+	sorted := append([]sourceFile{}, files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].File < sorted[j].File })
+	hash := sha256.New()
+	for _, f := range sorted {
+		hash.Write([]byte(f.File + ":" + f.Checksum + ";"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// currentMetadata is the metadata of a dictionary that is produced by this version of the library.
+func currentMetadata(dictionary string, source string, files []sourceFile) dictionaryMetadata {
+	return dictionaryMetadata{
+		Dictionary:      dictionary,
+		SchemaVersion:   schemaVersion,
+		LibraryVersion:  LibraryVersion,
+		VowelPolicy:     vowelPolicy,
+		Transliteration: transliteration,
+		NumberSystems:   numberSystemsFingerprint(),
+		Source:          source,
+		SourceChecksum:  sourceChecksum(files),
+	}
+}
+
+// incompatibilities lists the differences between the rules that produced the dictionary and the rules of this
+// version of the library. The library version itself does not matter as long as the rules are the same.
+func (m dictionaryMetadata) incompatibilities() (differences []string) {
+	current := currentMetadata(m.Dictionary, "", nil)
+	if m.SchemaVersion != current.SchemaVersion {
+		differences = append(differences, fmt.Sprintf("schema version %v is not %v", m.SchemaVersion, current.SchemaVersion))
+	}
+	if m.VowelPolicy != current.VowelPolicy {
+		differences = append(differences, fmt.Sprintf("vowel policy %v is not %v", m.VowelPolicy, current.VowelPolicy))
+	}
+	if m.Transliteration != current.Transliteration {
+		differences = append(differences, fmt.Sprintf("transliteration %v is not %v", m.Transliteration, current.Transliteration))
+	}
+	if m.NumberSystems != current.NumberSystems {
+		differences = append(differences, fmt.Sprintf("number systems %v are not %v", m.NumberSystems, current.NumberSystems))
+	}
+	return differences
+}
+
+// readMetadata returns the metadata of a dictionary and whether it has any.
+func readMetadata(db *gorm.DB, dictionary string) (metadata dictionaryMetadata, ok bool) {
+	if !db.Migrator().HasTable(&dictionaryMetadata{}) {
+		return metadata, false
+	}
+	var found []dictionaryMetadata
+	if err := db.Where("dictionary = ?", dictionary).Limit(1).Find(&found).Error; err != nil || len(found) == 0 {
+		return metadata, false
+	}
+	return found[0], true
+}
+
+// writeMetadata records the metadata of a dictionary, replacing any that it already has.
+func writeMetadata(db *gorm.DB, metadata dictionaryMetadata) error {
+	compatibleDictionaries.remove(metadata.Dictionary)
+	return db.Save(&metadata).Error
+}
+
+// checkDictionaryMetadata makes sure that a dictionary was produced by the same rules as this version of the
+// library. A dictionary without metadata was created before metadata was recorded. It is only warned about when
+// it has every column that searches use, and refused when it does not. The dsn is the database of the store.
+func checkDictionaryMetadata(store nameStore, dsn string, dictionary string) error {
+	if compatibleDictionaries.has(dsn, dictionary) {
+		return nil
+	}
+	metadata, ok := store.metadata(dictionary)
+	if !ok {
+		if missing := store.missingColumns(dictionary); len(missing) > 0 {
+			return fmt.Errorf("database table %v has no metadata and is missing columns (%v). Use MigrateDatabase to update it", dictionary, strings.Join(missing, ", "))
+		}
+		logTo(nil, LogWarning, "Database table has no metadata and may be out of date. Use MigrateDatabase to update it.", Fields{"dictionary": dictionary})
+	} else if differences := metadata.incompatibilities(); len(differences) > 0 {
+		return fmt.Errorf("database table %v is out of date (%v). Use MigrateDatabase to update it", dictionary, strings.Join(differences, ", "))
+	}
+	compatibleDictionaries.add(dsn, dictionary)
+	return nil
+}
+
+// recalculateRow calculates the numerological values of a row again using the rules of this version of the
+// library. The id, ranks and name metadata stay the same. Rows from before the gender counts were stored keep their gender.
+func recalculateRow(r precalculatedNumerology) (precalculatedNumerology, error) {
+	row, err := precalculateName(nameEntry{r.Name, int(r.MaleCount), int(r.FemaleCount), r.Trend})
+	if err != nil {
+		return r, err
+	}
+	row.Id = r.Id
+	row.PopularityRank, row.MaleRank, row.FemaleRank = r.PopularityRank, r.MaleRank, r.FemaleRank
+	row.Origin, row.Meaning, row.Variants, row.Nicknames = r.Origin, r.Meaning, r.Variants, r.Nicknames
+	if r.MaleCount == 0 && r.FemaleCount == 0 {
+		row.Gender, row.UnisexRatio = r.Gender, r.UnisexRatio
+	}
+	return row, nil
+}
+
+// legacyTable reports whether a table is from before schema version 2, when there was a row for each name and
+// gender and no ranks. The counts that the ranks are calculated from were never stored, so the rows cannot be
+// merged and ranked in place.
+func legacyTable(db *gorm.DB, table string) bool {
+	if !db.Table(table).Migrator().HasColumn(&precalculatedNumerology{}, "popularity_rank") {
+		return true
+	}
+	var rows, ranked int64
+	db.Table(table).Count(&rows)
+	db.Table(table).Where("popularity_rank > 0").Count(&ranked)
+	return rows > 0 && ranked == 0
+}
+
+// MigrateDatabase brings dictionaries that were created by an older version of the library up to date. Missing
+// columns are added and the numerological values of every name are calculated again in place, so the ids and
+// ranks of the names stay the same. Only the rows whose values changed are written. Tables from before schema
+// version 2 have a row for each name and gender and no ranks, so they cannot be migrated and have to be created
+// again with CreateDatabase.
+//
+// Dictionaries that have no metadata have to be named. When no dictionaries are named, every dictionary that has
+// metadata is checked. Dictionaries that are already up to date are skipped. Dictionaries in memory are always
+// calculated by the current version of the library, so they only have to be calculated again when a number
+// system has been registered since they were loaded.
+func MigrateDatabase(dsn string, dictionaries ...string) error {
+	if isMemoryDSN(dsn) {
+		migrateMemoryDatabase(dictionaries)
+		return nil
+	}
+	logTo(nil, LogInfo, "Connecting to database", nil)
+	if err := connectToDatabase(dsn); err != nil {
+		return errors.New("unable to connect to database. " + err.Error())
+	}
+	if err := DB.AutoMigrate(&dictionaryMetadata{}); err != nil {
+		return err
+	}
+	if len(dictionaries) == 0 {
+		var all []dictionaryMetadata
+		if err := DB.Order("dictionary asc").Find(&all).Error; err != nil {
+			return err
+		}
+		for _, m := range all {
+			dictionaries = append(dictionaries, m.Dictionary)
+		}
+	}
+	for _, dictionary := range dictionaries {
+		table := strings.ToLower(dictionary)
+		metadata, ok := readMetadata(DB, table)
+		if ok && len(metadata.incompatibilities()) == 0 {
+			logTo(nil, LogInfo, "Skipping dictionary", Fields{"dictionary": table, "reason": "dictionary is up to date"})
+			continue
+		}
+		if !DB.Migrator().HasTable(table) {
+			return fmt.Errorf("database table %v does not exist", table)
+		}
+		if (ok && metadata.SchemaVersion < 2) || legacyTable(DB, table) {
+			return fmt.Errorf("database table %v has a row for each name and gender and no ranks, so it cannot be migrated. Drop it and create it again with CreateDatabase", table)
+		}
+		if err := setupDatabaseTable(DB, table); err != nil {
+			return err
+		}
+		var rows []precalculatedNumerology
+		if err := DB.Table(table).Find(&rows).Error; err != nil {
+			return err
+		}
+		if err := loadNumberSystems(DB, table, rows); err != nil {
+			return err
+		}
+		updates := []precalculatedNumerology{}
+		for _, r := range rows {
+			row, err := recalculateRow(r)
+			if err != nil {
+				logTo(nil, LogWarning, "Leaving name with unacceptable characters unchanged", Fields{"dictionary": table, "name": r.Name})
+				continue
+			}
+			if !reflect.DeepEqual(row, r) {
+				updates = append(updates, row)
+			}
+		}
+
+		logTo(nil, LogInfo, "Migrating database table", Fields{"dictionary": table, "changed": len(updates), "names": len(rows)})
+		// The source of the dictionary is still the same.
+		updated := currentMetadata(table, metadata.Source, nil)
+		updated.SourceChecksum = metadata.SourceChecksum
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			for _, r := range updates {
+				if err := tx.Table(table).Save(&r).Error; err != nil {
+					return fmt.Errorf("unable to update record in database: %v", r.Name)
+				}
+			}
+			if err := writeNumberSystems(tx, table, updates); err != nil {
+				return err
+			}
+			return writeMetadata(tx, updated)
+		}); err != nil {
+			return err
+		}
+		// The largest value and the order of the names may have changed.
+		forgetDictionary(table)
+	}
+	return nil
+}
+
+// migrateMemoryDatabase calculates the dictionaries in memory again if a number system has been registered since
+// they were loaded. When no dictionaries are named, every dictionary in memory is checked.
+func migrateMemoryDatabase(dictionaries []string) {
+	if len(dictionaries) == 0 {
+		for dictionary := range memoryDictionaries {
+			dictionaries = append(dictionaries, dictionary)
+		}
+	}
+	fingerprint := numberSystemsFingerprint()
+	for _, dictionary := range dictionaries {
+		d, ok := memoryDictionaries[strings.ToLower(dictionary)]
+		if !ok || d.fingerprint == fingerprint {
+			continue
+		}
+		memoryDictionaries[strings.ToLower(dictionary)] = recalculateMemoryDictionary(d)
+		forgetDictionary(dictionary)
+	}
+}
+
+// recalculateMemoryDictionary calculates every row of a dictionary in memory again. Rows that cannot be
+// calculated are left unchanged.
+func recalculateMemoryDictionary(d *memoryDictionary) *memoryDictionary {
+	rows := d.rows()
+	for i, r := range rows {
+		if row, err := recalculateRow(r); err == nil {
+			rows[i] = row
+		}
+	}
+	return newMemoryDictionary(rows)
+}
@@ -0,0 +1,234 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_dictionaryMetadataIncompatibilities(t *testing.T) {
+	current := currentMetadata("usa_census", "test_names/usa_census", nil)
+	tests := []struct {
+		name   string
+		modify func(m *dictionaryMetadata)
+		want   int
+	}{
+		{"Current", func(m *dictionaryMetadata) {}, 0},
+		{"LibraryVersion", func(m *dictionaryMetadata) { m.LibraryVersion = "0.1.0" }, 0},
+		{"Source", func(m *dictionaryMetadata) { m.Source = "elsewhere" }, 0},
+		{"SchemaVersion", func(m *dictionaryMetadata) { m.SchemaVersion = 1 }, 1},
+		{"VowelPolicy", func(m *dictionaryMetadata) { m.VowelPolicy = "y-rules-0" }, 1},
+		{"Transliteration", func(m *dictionaryMetadata) { m.Transliteration = "none" }, 1},
+		{"NumberSystems", func(m *dictionaryMetadata) { m.NumberSystems = "pythagorean:00000000" }, 1},
+		{"Several", func(m *dictionaryMetadata) { m.SchemaVersion, m.VowelPolicy = 1, "" }, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := current
+			tt.modify(&m)
+			if got := m.incompatibilities(); len(got) != tt.want {
+				t.Errorf("incompatibilities() = %v, want %v differences", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_numberSystemsFingerprint(t *testing.T) {
+	before := numberSystemsFingerprint()
+	if before != numberSystemsFingerprint() {
+		t.Errorf("numberSystemsFingerprint() is not the same every time")
+	}
+	Pythagorean.NumberMapping['a'] = 2
+	changed := numberSystemsFingerprint()
+	Pythagorean.NumberMapping['a'] = 1
+	if changed == before {
+		t.Errorf("numberSystemsFingerprint() did not change with the conversion table")
+	}
+}
+
+// legacyNumerology is the layout of the tables from before schema version 2, which had a row for each name and
+// gender and no ranks.
+type legacyNumerology struct {
+	Id              int64 `gorm:"primaryKey"`
+	Name            string
+	Gender          string `gorm:"type:varchar(1)"`
+	PythagoreanFull uint8
+}
+
+func TestMigrateDatabaseLegacy(t *testing.T) {
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	if err := connectToDatabase(dsn); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DB.Migrator().DropTable("legacy_test")
+		DB.Where("dictionary = ?", "legacy_test").Delete(&dictionaryMetadata{})
+		DB = nil
+	}()
+	if err := DB.Table("legacy_test").AutoMigrate(&legacyNumerology{}); err != nil {
+		t.Fatal(err)
+	}
+	rows := []legacyNumerology{{1, "Kelly", "F", 3}, {2, "Mary", "F", 3}, {3, "Kelly", "M", 3}}
+	if err := DB.Table("legacy_test").Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MigrateDatabase(dsn, "legacy_test"); err == nil {
+		t.Errorf("MigrateDatabase() expected error for a table from before schema version 2")
+	}
+	if _, ok := readMetadata(DB, "legacy_test"); ok {
+		t.Errorf("MigrateDatabase() recorded metadata for a table that it could not migrate")
+	}
+	if DB.Table("legacy_test").Migrator().HasColumn(&precalculatedNumerology{}, "popularity_rank") {
+		t.Errorf("MigrateDatabase() changed the columns of a table that it could not migrate")
+	}
+
+	// A table that an earlier migration added the columns to still has no ranks.
+	if err := setupDatabaseTable(DB, "legacy_test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateDatabase(dsn, "legacy_test"); err == nil {
+		t.Errorf("MigrateDatabase() expected error for a table without ranks")
+	}
+}
+
+func Test_checkDictionaryMetadataDSN(t *testing.T) {
+	rows := precalculateNames(namePopularity{{"Mary", 0, 300, 0}})
+	rows[0].Id = 1
+	memoryDictionaries["dsn_test"] = newMemoryDictionary(rows)
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	if err := connectToDatabase(dsn); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DB.Migrator().DropTable("dsn_test")
+		delete(memoryDictionaries, "dsn_test")
+		compatibleDictionaries.remove("dsn_test")
+		DB = nil
+	}()
+	if err := DB.Table("dsn_test").AutoMigrate(&legacyNumerology{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Searches check the metadata at the same time.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := checkDictionaryMetadata(memoryStore{memoryDictionaries}, "memory://", "dsn_test"); err != nil {
+				t.Errorf("checkDictionaryMetadata() error = %v", err)
+			}
+			compatibleDictionaries.remove("other_test")
+		}()
+	}
+	wg.Wait()
+
+	// The table of the same name in another database is checked on its own.
+	if err := checkDictionaryMetadata(gormStore{DB}, dsn, "dsn_test"); err == nil {
+		t.Errorf("checkDictionaryMetadata() error = nil, want an error for a table that is missing columns")
+	}
+}
+
+func TestMigrateDatabase(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := filepath.Join(baseDir, "migrate_test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "2020.csv"), []byte("Mary,F,300\nJohn,M,200\nKelly,F,60\nKelly,M,50\nZoe,F,40\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	if err := CreateDatabase(dsn, baseDir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DB.Migrator().DropTable("migrate_test")
+		DB.Where("dictionary = ?", "migrate_test").Delete(&importLog{})
+		DB.Where("dictionary = ?", "migrate_test").Delete(&dictionaryMetadata{})
+		compatibleDictionaries.remove("migrate_test")
+		DB = nil
+	}()
+	var want []precalculatedNumerology
+	DB.Table("migrate_test").Order("id asc").Find(&want)
+	search := func() error {
+		_, _, err := nameSearch("?", Pythagorean, []int{}, true, NameSearchOpts{Count: 10, Dictionary: "migrate_test", Database: dsn})
+		return err
+	}
+	if err := search(); err != nil {
+		t.Fatalf("nameSearch() error = %v", err)
+	}
+
+	// Pretend the table was calculated with different Y rules.
+	DB.Table("migrate_test").Where("name = ?", "Mary").Update("pythagorean_vowels", 99)
+	DB.Model(&dictionaryMetadata{}).Where("dictionary = ?", "migrate_test").Update("vowel_policy", "y-rules-0")
+	compatibleDictionaries.remove("migrate_test")
+	if err := search(); err == nil {
+		t.Errorf("nameSearch() expected error for an out of date table")
+	}
+	if err := MigrateDatabase(dsn); err != nil {
+		t.Fatalf("MigrateDatabase() error = %v", err)
+	}
+	var got []precalculatedNumerology
+	DB.Table("migrate_test").Order("id asc").Find(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MigrateDatabase() rows = %v, want %v", got, want)
+	}
+	metadata, ok := readMetadata(DB, "migrate_test")
+	if !ok || len(metadata.incompatibilities()) > 0 || metadata.Source != dir {
+		t.Errorf("MigrateDatabase() metadata = %v", metadata)
+	}
+	if err := search(); err != nil {
+		t.Errorf("nameSearch() error = %v", err)
+	}
+
+	// A table without metadata is only warned about, and has to be named to be migrated.
+	DB.Where("dictionary = ?", "migrate_test").Delete(&dictionaryMetadata{})
+	compatibleDictionaries.remove("migrate_test")
+	if err := search(); err != nil {
+		t.Errorf("nameSearch() error = %v", err)
+	}
+	if err := MigrateDatabase(dsn, "migrate_test"); err != nil {
+		t.Fatalf("MigrateDatabase() error = %v", err)
+	}
+	if _, ok := readMetadata(DB, "migrate_test"); !ok {
+		t.Errorf("MigrateDatabase() did not record metadata")
+	}
+	if err := MigrateDatabase(dsn, "missing_table"); err == nil {
+		t.Errorf("MigrateDatabase() expected error for a table that does not exist")
+	}
+
+	// A table without metadata that is missing columns is refused instead of returning no names.
+	if err := DB.Table("migrate_test").Migrator().DropColumn(&precalculatedNumerology{}, "origin"); err != nil {
+		t.Fatal(err)
+	}
+	DB.Where("dictionary = ?", "migrate_test").Delete(&dictionaryMetadata{})
+	compatibleDictionaries.remove("migrate_test")
+	if err := search(); err == nil {
+		t.Errorf("nameSearch() expected error for a table without metadata that is missing columns")
+	}
+	// The error of the query itself is returned too.
+	if err := writeMetadata(DB, currentMetadata("migrate_test", dir, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := search(); err == nil {
+		t.Errorf("nameSearch() expected error for a query that failed")
+	}
+}
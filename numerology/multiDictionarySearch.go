@@ -0,0 +1,121 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"sort"
+	"strings"
+)
+
+// dictionaryName is a name from one of several dictionaries that are being searched together.
+type dictionaryName struct {
+	precalculatedNumerology
+	search *preparedNameSearch
+	// position is used to merge the names from the different dictionaries. For common and uncommon sorts it is
+	// the popularity of the name relative to the size of its dictionary. For random sort it is the position of
+	// the name in the random order of its dictionary.
+	position float64
+}
+
+// mergeDictionaryNames sorts the names from all the dictionaries by their position and removes names that
+// are in more than one dictionary. The first occurrence of a name is the one that is kept.
+func mergeDictionaryNames(names []dictionaryName) (merged []dictionaryName) {
+	sort.SliceStable(names, func(i, j int) bool {
+		return names[i].position < names[j].position
+	})
+	seen := map[string]bool{}
+	for _, n := range names {
+		key := strings.ToLower(n.Name)
+		if !seen[key] {
+			merged = append(merged, n)
+			seen[key] = true
+		}
+	}
+	return merged
+}
+
+// multiDictionarySearch searches several dictionaries as one pool of names. Because each dictionary has its own
+// ranks, the offset is the position in the merged results instead of a rank.
+func multiDictionarySearch(n string, numberSystem NumberSystem, masterNumbers []int, reduceWords bool, opts NameSearchOpts) (results []NameNumerology, offset int64, err error) {
+	if opts.Count == 0 {
+		opts.Count = 25
+	}
+	// Every dictionary can hold all the results that come before the offset, so enough names are fetched from
+	// each dictionary to fill the page no matter how the names end up merging.
+	needed := opts.Offset + opts.Count + 1
+
+	var names []dictionaryName
+	var ranked bool
+	for _, dictionary := range opts.Dictionaries {
+		dictionaryOpts := opts
+		dictionaryOpts.Dictionary = dictionary
+		dictionaryOpts.Dictionaries = nil
+		dictionaryOpts.Offset = 0
+		search, err := prepareNameSearch(n, numberSystem, masterNumbers, reduceWords, dictionaryOpts)
+		if err != nil {
+			return []NameNumerology{}, 0, err
+		}
+		// The results should show the options that were actually used for the search.
+		search.searchOpts.Dictionary = ""
+		search.searchOpts.Dictionaries = opts.Dictionaries
+		search.searchOpts.Offset = opts.Offset
+
+		var fetched []precalculatedNumerology
+		ranked = search.rankedInGo()
+		if ranked {
+			fetched, err = search.candidates()
+		} else {
+			fetched, _, err = search.fetch(0, needed)
+		}
+		if err != nil {
+			return []NameNumerology{}, 0, err
+		}
+		for i, r := range fetched {
+			// The most popular name of every dictionary is at position 0.
+			position := float64(r.rank(search.query.RankColumn)-1) / float64(search.tableSize)
+			if strings.ToLower(opts.Sort) == RandomSort {
+				position = float64(i)
+			}
+			names = append(names, dictionaryName{r, search, position})
+		}
+	}
+	merged := mergeDictionaryNames(names)
+
+	// Names that are sorted in Go are sorted after merging so that names that sort equally are kept in order of
+	// their relative popularity.
+	if ranked && len(merged) > 0 {
+		dictionaryOf := map[string]dictionaryName{}
+		ordered := []precalculatedNumerology{}
+		for _, m := range merged {
+			dictionaryOf[m.Name] = m
+			ordered = append(ordered, m.precalculatedNumerology)
+		}
+		merged[0].search.sortRanked(ordered)
+		for i, o := range ordered {
+			merged[i] = dictionaryOf[o.Name]
+		}
+	}
+
+	results = []NameNumerology{}
+	if opts.Offset >= len(merged) {
+		return results, 0, nil
+	}
+	merged = merged[opts.Offset:]
+	for i, m := range merged {
+		if i == opts.Count {
+			offset = int64(opts.Offset + opts.Count)
+			break
+		}
+		results = append(results, m.search.result(m.precalculatedNumerology))
+	}
+	return results, offset, nil
+}
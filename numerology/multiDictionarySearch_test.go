@@ -0,0 +1,92 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_multiDictionarySearch(t *testing.T) {
+	for _, sort := range []string{CommonSort, UncommonSort, RandomSort, ScoreSort} {
+		opts := NameSearchOpts{
+			Count:        250,
+			Seed:         3384983,
+			Dictionaries: []string{"usa_census", "irish"},
+			Gender:       Gender('B'),
+			Sort:         sort,
+			Weights:      ScoreWeights{Full: map[int]float64{1: 1}},
+			Database:     "sqlite://file::memory:?cache=shared",
+		}
+		all, offset, err := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+		if err != nil {
+			t.Fatalf("nameSearch() error = %v", err)
+		}
+		if len(all) != 250 || offset != 250 {
+			t.Fatalf("%v unexpected results. %v %v", sort, len(all), offset)
+		}
+		seen := map[string]bool{}
+		dictionaries := map[string]bool{}
+		for _, r := range all {
+			if seen[strings.ToLower(r.Name)] {
+				t.Errorf("%v duplicate name found when there shouldn't be any. %v", sort, r.Name)
+			}
+			seen[strings.ToLower(r.Name)] = true
+			dictionaries[r.Dictionary] = true
+		}
+		if (sort == CommonSort || sort == RandomSort) && (!dictionaries["usa_census"] || !dictionaries["irish"]) {
+			t.Errorf("%v expected names from both dictionaries. %v", sort, dictionaries)
+		}
+
+		// Paging through the merged results gives the same names.
+		opts.Count = 100
+		first, offset, _ := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+		opts.Offset = int(offset)
+		second, _, _ := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+		for i, r := range append(first, second...) {
+			if r.Name != all[i].Name || r.Dictionary != all[i].Dictionary {
+				t.Errorf("%v paging across dictionaries is producing bad results. %v != %v", sort, r.Name, all[i].Name)
+			}
+		}
+	}
+	DB = nil
+}
+
+func Test_multiDictionarySearchSource(t *testing.T) {
+	results, _, err := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, NameSearchOpts{
+		Count:        10,
+		Dictionaries: []string{"usa_census", "irish"},
+		Sort:         CommonSort,
+		Include:      []string{"Caoimhe", "Michael", "Sean"},
+		Database:     "sqlite://file::memory:?cache=shared",
+	})
+	if err != nil {
+		t.Fatalf("nameSearch() error = %v", err)
+	}
+	want := map[string]string{
+		// Most popular name in both dictionaries. The tie goes to the first dictionary.
+		"Michael": "usa_census",
+		// Only in the irish dictionary.
+		"Caoimhe": "irish",
+		// Much more popular in the smaller irish dictionary.
+		"Sean": "irish",
+	}
+	if len(results) != len(want) {
+		t.Fatalf("Unexpected results. %v", results)
+	}
+	for _, r := range results {
+		if want[r.Name] != r.Dictionary {
+			t.Errorf("%v came from %v, want %v", r.Name, r.Dictionary, want[r.Name])
+		}
+	}
+	DB = nil
+}
@@ -0,0 +1,302 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// nameMetadataPatterns are the files of a dictionary directory that describe the names instead of counting them.
+var nameMetadataPatterns = []string{"*.meta.csv", "*.meta.tsv", "*.meta.jsonl"}
+
+// nameListSeparator separates the names of the variants and nicknames in a column.
+const nameListSeparator = "|"
+
+// Column headers of name metadata files that are recognized, and the field that they refer to.
+var nameMetadataAliases = map[string]string{
+	"name":       "name",
+	"first_name": "name",
+	"given_name": "name",
+	"origin":     "origin",
+	"meaning":    "meaning",
+	"variants":   "variants",
+	"variant":    "variants",
+	"spellings":  "variants",
+	"nicknames":  "nicknames",
+	"nickname":   "nicknames",
+}
+
+// nameMetadata describes a name. Variants are other spellings of the same name, and Nicknames are the short
+// forms of it.
+type nameMetadata struct {
+	Origin    string
+	Meaning   string
+	Variants  []string
+	Nicknames []string
+}
+
+// isNameMetadataFile reports whether a file name is a name metadata file.
+func isNameMetadataFile(fileName string) bool {
+	return strings.Contains(strings.ToLower(fileName), ".meta.")
+}
+
+// splitNameList splits a column of names. The names can be separated by | or ;.
+func splitNameList(s string) (names []string) {
+	for _, n := range strings.FieldsFunc(s, func(r rune) bool { return r == '|' || r == ';' }) {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// readNameMetadataFile reads the names of a metadata file. CSV and TSV files need a header with a name column.
+// The variants and nicknames of JSON Lines files can be either arrays or strings.
+// ex. {"name": "William", "origin": "German", "nicknames": ["Bill", "Will"]}
+func readNameMetadataFile(path string) (names map[string]nameMetadata, order []string, rejects []RejectedRow, err error) {
+	input, fileName, closers, err := openInput(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}()
+	file := filepath.Base(path)
+	names = map[string]nameMetadata{}
+	add := func(row int, text string, values map[string][]string) {
+		name := ""
+		if len(values["name"]) > 0 {
+			name = strings.TrimSpace(values["name"][0])
+		}
+		if name == "" {
+			rejects = append(rejects, RejectedRow{File: file, Row: row, Text: text, Reason: "missing name"})
+			return
+		}
+		m := nameMetadata{Variants: values["variants"], Nicknames: values["nicknames"]}
+		if len(values["origin"]) > 0 {
+			m.Origin = strings.TrimSpace(values["origin"][0])
+		}
+		if len(values["meaning"]) > 0 {
+			m.Meaning = strings.TrimSpace(values["meaning"][0])
+		}
+		if _, ok := names[name]; !ok {
+			order = append(order, name)
+		}
+		names[name] = mergeNameMetadata(names[name], m)
+	}
+
+	if strings.ToLower(filepath.Ext(fileName)) == ".jsonl" {
+		scanner := bufio.NewScanner(input)
+		row := 0
+		for scanner.Scan() {
+			row++
+			text := scanner.Text()
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			object := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(text), &object); err != nil {
+				rejects = append(rejects, RejectedRow{File: file, Row: row, Text: text, Reason: "invalid JSON"})
+				continue
+			}
+			values := map[string][]string{}
+			for key, v := range object {
+				field, ok := nameMetadataAliases[strings.ToLower(key)]
+				if !ok {
+					continue
+				}
+				switch v := v.(type) {
+				case string:
+					if field == "variants" || field == "nicknames" {
+						values[field] = splitNameList(v)
+					} else {
+						values[field] = []string{v}
+					}
+				case []interface{}:
+					for _, item := range v {
+						if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+							values[field] = append(values[field], strings.TrimSpace(s))
+						}
+					}
+				}
+			}
+			add(row, text, values)
+		}
+		return names, order, rejects, scanner.Err()
+	}
+
+	reader := csv.NewReader(input)
+	if strings.ToLower(filepath.Ext(fileName)) == ".tsv" {
+		reader.Comma = '\t'
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	var columns map[string]int
+	for row := 1; ; row++ {
+		cols, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		text := strings.Join(cols, string(reader.Comma))
+		if err != nil {
+			rejects = append(rejects, RejectedRow{File: file, Row: row, Text: text, Reason: err.Error()})
+			continue
+		}
+		if columns == nil {
+			columns = map[string]int{}
+			for i, col := range cols {
+				if field, ok := nameMetadataAliases[strings.ToLower(strings.TrimSpace(col))]; ok {
+					columns[field] = i
+				}
+			}
+			if _, ok := columns["name"]; !ok {
+				return nil, nil, nil, fmt.Errorf("name metadata file %v has no name column in its header", file)
+			}
+			continue
+		}
+		values := map[string][]string{}
+		for field, i := range columns {
+			if i >= len(cols) {
+				continue
+			}
+			if field == "variants" || field == "nicknames" {
+				values[field] = splitNameList(cols[i])
+			} else {
+				values[field] = []string{cols[i]}
+			}
+		}
+		add(row, text, values)
+	}
+	return names, order, rejects, nil
+}
+
+// mergeNameMetadata combines two descriptions of the same name. Values that a is missing are taken from b, and
+// the lists of names are joined.
+func mergeNameMetadata(a nameMetadata, b nameMetadata) nameMetadata {
+	if a.Origin == "" {
+		a.Origin = b.Origin
+	}
+	if a.Meaning == "" {
+		a.Meaning = b.Meaning
+	}
+	for _, v := range b.Variants {
+		if !inFoldSlice(v, a.Variants) {
+			a.Variants = append(a.Variants, v)
+		}
+	}
+	for _, n := range b.Nicknames {
+		if !inFoldSlice(n, a.Nicknames) {
+			a.Nicknames = append(a.Nicknames, n)
+		}
+	}
+	return a
+}
+
+// inFoldSlice reports whether a name is in a list of names, ignoring case.
+func inFoldSlice(name string, names []string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// readNameMetadata reads all the name metadata files of a dictionary directory. The metadata is keyed by the
+// lowercase name. Variants are linked in both directions, so every spelling of a name lists all the others even
+// when only one of them has a row.
+func readNameMetadata(directory string) (map[string]nameMetadata, []RejectedRow, error) {
+	files, err := globFiles(directory, nameMetadataPatterns)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata := map[string]nameMetadata{}
+	rejects := []RejectedRow{}
+	// Every group of variants is found by joining the groups of each name with the groups of its variants.
+	group := map[string]string{}
+	spelling := map[string]string{}
+	var find func(key string) string
+	find = func(key string) string {
+		if parent, ok := group[key]; ok && parent != key {
+			root := find(parent)
+			group[key] = root
+			return root
+		}
+		group[key] = key
+		return key
+	}
+	for _, fn := range files {
+		names, order, fileRejects, err := readNameMetadataFile(filepath.Join(directory, fn))
+		if err != nil {
+			return nil, nil, err
+		}
+		rejects = append(rejects, fileRejects...)
+		for _, name := range order {
+			m := names[name]
+			key := strings.ToLower(name)
+			metadata[key] = mergeNameMetadata(metadata[key], nameMetadata{Origin: m.Origin, Meaning: m.Meaning, Nicknames: m.Nicknames})
+			if _, ok := spelling[key]; !ok {
+				spelling[key] = name
+			}
+			root := find(key)
+			for _, v := range m.Variants {
+				vKey := strings.ToLower(v)
+				if _, ok := spelling[vKey]; !ok {
+					spelling[vKey] = v
+				}
+				group[find(vKey)] = root
+			}
+		}
+	}
+	members := map[string][]string{}
+	for key := range group {
+		root := find(key)
+		members[root] = append(members[root], key)
+	}
+	for _, keys := range members {
+		if len(keys) < 2 {
+			continue
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			m := metadata[key]
+			m.Variants = nil
+			for _, other := range keys {
+				if other != key {
+					m.Variants = append(m.Variants, spelling[other])
+				}
+			}
+			metadata[key] = m
+		}
+	}
+	return metadata, rejects, nil
+}
+
+// applyNameMetadata copies the metadata of each name into its row.
+func applyNameMetadata(rows []precalculatedNumerology, metadata map[string]nameMetadata) {
+	for i := range rows {
+		m := metadata[strings.ToLower(rows[i].Name)]
+		rows[i].Origin = m.Origin
+		rows[i].Meaning = m.Meaning
+		rows[i].Variants = strings.Join(m.Variants, nameListSeparator)
+		rows[i].Nicknames = strings.Join(m.Nicknames, nameListSeparator)
+	}
+}
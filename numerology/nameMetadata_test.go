@@ -0,0 +1,163 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_splitNameList(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"Bill", []string{"Bill"}},
+		{"Bill|Will", []string{"Bill", "Will"}},
+		{" Bill ; Will |", []string{"Bill", "Will"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := splitNameList(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitNameList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readNameMetadataFile(t *testing.T) {
+	william := nameMetadata{Origin: "German", Meaning: "resolute protector", Variants: []string{"Wilhelm"}, Nicknames: []string{"Bill", "Will"}}
+	tests := []struct {
+		name        string
+		file        string
+		contents    string
+		want        map[string]nameMetadata
+		wantRejects int
+		wantErr     bool
+	}{
+		{"CSV", "names.meta.csv", "name,origin,meaning,variants,nicknames\nWilliam,German,resolute protector,Wilhelm,Bill|Will\n",
+			map[string]nameMetadata{"William": william}, 0, false},
+		{"TSV", "names.meta.tsv", "Nicknames\tName\tOrigin\tMeaning\tVariant\nBill;Will\tWilliam\tGerman\tresolute protector\tWilhelm\n",
+			map[string]nameMetadata{"William": william}, 0, false},
+		{"JSONLines", "names.meta.jsonl", "{\"name\": \"William\", \"origin\": \"German\", \"meaning\": \"resolute protector\", \"variants\": \"Wilhelm\", \"nicknames\": [\"Bill\", \"Will\"]}\n",
+			map[string]nameMetadata{"William": william}, 0, false},
+		{"Gzip", "names.meta.csv.gz", "name,origin\nSean,Irish\n",
+			map[string]nameMetadata{"Sean": {Origin: "Irish"}}, 0, false},
+		{"Merged", "names.meta.csv", "name,origin,nicknames\nWilliam,German,Bill\nWilliam,,Will|Bill\n",
+			map[string]nameMetadata{"William": {Origin: "German", Nicknames: []string{"Bill", "Will"}}}, 0, false},
+		{"Rejects", "names.meta.jsonl", "{\"origin\": \"Irish\"}\nnot json\n{\"name\": \"Sean\", \"origin\": \"Irish\"}\n",
+			map[string]nameMetadata{"Sean": {Origin: "Irish"}}, 2, false},
+		{"NoNameColumn", "names.meta.csv", "origin,meaning\nIrish,wise\n", nil, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeSourceFile(t, dir, tt.file, tt.contents)
+			got, _, rejects, err := readNameMetadataFile(filepath.Join(dir, tt.file))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readNameMetadataFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readNameMetadataFile() = %v, want %v", got, tt.want)
+			}
+			if len(rejects) != tt.wantRejects {
+				t.Errorf("readNameMetadataFile() rejects = %v, want %v", rejects, tt.wantRejects)
+			}
+		})
+	}
+}
+
+func Test_readNameMetadata(t *testing.T) {
+	dir := t.TempDir()
+	writeSourceFile(t, dir, "a.meta.csv", "name,origin,variants\nCatherine,Greek,Katherine\n")
+	writeSourceFile(t, dir, "b.meta.jsonl", "{\"name\": \"Kathryn\", \"variants\": [\"katherine\"]}\n{\"name\": \"William\", \"nicknames\": [\"Bill\"]}\n")
+	got, rejects, err := readNameMetadata(dir)
+	if err != nil || len(rejects) > 0 {
+		t.Fatalf("readNameMetadata() error = %v %v", err, rejects)
+	}
+	// The variants are linked in both directions and across files.
+	want := map[string]nameMetadata{
+		"catherine": {Origin: "Greek", Variants: []string{"Katherine", "Kathryn"}},
+		"katherine": {Variants: []string{"Catherine", "Kathryn"}},
+		"kathryn":   {Variants: []string{"Catherine", "Katherine"}},
+		"william":   {Nicknames: []string{"Bill"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readNameMetadata() = %v, want %v", got, want)
+	}
+}
+
+func Test_nameSearchNameMetadata(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := filepath.Join(baseDir, "metadata_test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeSourceFile(t, dir, "names.csv", "Sophia,F,300\nSean,M,250\nSofia,F,200\nWilliam,M,150\nSophie,F,100\n")
+	writeSourceFile(t, dir, "names.meta.csv", "name,origin,meaning,variants,nicknames\nSophia,Greek,wisdom,Sofia|Sophie,\nSean,Irish,God is gracious,,\nWilliam,German,resolute protector,,Bill|Will\n")
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	if err := CreateDatabase(dsn, baseDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateDatabase("memory://", baseDir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DB.Migrator().DropTable("metadata_test")
+		DB.Where("dictionary = ?", "metadata_test").Delete(&importLog{})
+		DB.Where("dictionary = ?", "metadata_test").Delete(&dictionaryMetadata{})
+		delete(memoryDictionaries, "metadata_test")
+		DB = nil
+	}()
+
+	for _, database := range []string{dsn, "memory://"} {
+		t.Run(database, func(t *testing.T) {
+			search := func(origins []string) []NameNumerology {
+				opts := NameSearchOpts{Count: 10, Dictionary: "metadata_test", Database: database, Origins: origins}
+				results, _, err := nameSearch("? Doe", Pythagorean, []int{}, true, opts)
+				if err != nil {
+					t.Fatalf("nameSearch() error = %v", err)
+				}
+				return results
+			}
+			names := func(results []NameNumerology) (names []string) {
+				for _, r := range results {
+					names = append(names, r.Name)
+				}
+				return names
+			}
+			if got := names(search([]string{"greek", "IRISH"})); !reflect.DeepEqual(got, []string{"Sophia Doe", "Sean Doe"}) {
+				t.Errorf("nameSearch() origins = %v", got)
+			}
+
+			results := search(nil)
+			sophia, william := results[0], results[3]
+			if sophia.Origin != "Greek" || sophia.Meaning != "wisdom" || !reflect.DeepEqual(sophia.Variants(), []string{"Sofia", "Sophie"}) {
+				t.Errorf("nameSearch() metadata of Sophia = %v %v %v", sophia.Origin, sophia.Meaning, sophia.Variants())
+			}
+			if !reflect.DeepEqual(william.Nicknames(), []string{"Bill", "Will"}) {
+				t.Errorf("nameSearch() nicknames of William = %v", william.Nicknames())
+			}
+			// Sofia has the same numbers as Sophia, so only Sophie is different.
+			if got := names(sophia.SpellingVariants()); !reflect.DeepEqual(got, []string{"Sophie Doe"}) {
+				t.Errorf("SpellingVariants() = %v", got)
+			}
+			if got := william.SpellingVariants(); len(got) != 0 {
+				t.Errorf("SpellingVariants() = %v, want none", got)
+			}
+		})
+	}
+}
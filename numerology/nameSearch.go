@@ -12,22 +12,39 @@
 package numerology
 
 import (
+	"encoding/json"
 	"fmt"
-	"gorm.io/gorm"
 	"math/rand"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 )
 
 var largestNameValueInTable = map[string]int{}
 
+// forgetDictionary drops what is cached about a dictionary after its names have changed.
+func forgetDictionary(table string) {
+	table = strings.ToLower(table)
+	delete(largestNameValueInTable, table)
+	rankedSearches.forget(table)
+}
+
 // Constants that represent the name sorting methods.
 const (
 	CommonSort   = "common"
 	UncommonSort = "uncommon"
 	RandomSort   = "random"
+	PhoneticSort = "phonetic"
+	ScoreSort    = "score"
+)
+
+// Constants that represent the name trend filters.
+const (
+	TrendingNames = "trending"
+	ClassicNames  = "classic"
 )
 
 type queryLookup struct {
@@ -49,16 +66,6 @@ func init() {
 // want. Since all the values in the database are stored unreduced we can then find names that will work.
 func generateLookupNums(minSearchNumber int, maxSearchNumber int, numerologyNums []int, masterNumbers []int, reduceWords bool) []int {
 	var nums []int
-	// Split up positive and negative numbers into separate lists and make negative numbers positive for comparison.
-	positiveNums := []int{}
-	negativeNums := []int{}
-	for _, n := range numerologyNums {
-		if n >= 0 {
-			positiveNums = append(positiveNums, n)
-		} else {
-			negativeNums = append(negativeNums, -n)
-		}
-	}
 	// Iterate through possible numbers looking for matches.
 	for i := 1; minSearchNumber+i <= maxSearchNumber; i++ {
 		// i needs to be in reduced form for proper calculation.
@@ -72,100 +79,166 @@ func generateLookupNums(minSearchNumber int, maxSearchNumber int, numerologyNums
 		// Create the reduced value of this hypothetical name.
 		validNum := reduceNumbers(minSearchNumber+reducedI[idx], masterNumbers, []int{})
 		// Check if the validNum satisfies our numerological criteria.
-		var noMatch bool
-		// If there are positive numbers then noMatch is only false when we specifically find a number.
-		// The alternative is that any number is acceptable as long as it isn't a negative number.
-		if len(positiveNums) > 0 {
-			noMatch = true
-		}
-		for _, v := range validNum {
-			if inIntSlice(v, negativeNums) {
-				noMatch = true
-				break
-			}
-			if inIntSlice(v, positiveNums) {
-				noMatch = false
-				break
-			}
-		}
-		if !noMatch {
+		if matchesNumbers(validNum, numerologyNums) {
 			nums = append(nums, i)
 		}
 	}
 	return nums
 }
 
-func addQueryLookup(query *gorm.DB, q queryLookup) {
-	if len(q.TargetNumbers) == 0 {
-		return
+// matchesNumbers checks the reduce steps of a value against numbers to include and exclude. Positive numbers are
+// inclusive and negative numbers are exclusive. The first step that is in either list decides, so with 11 as a
+// Master Number the steps 29, 11, 2 are excluded by -11 even when 2 is included. When there are no positive
+// numbers any value is acceptable as long as none of its steps are excluded.
+func matchesNumbers(steps []int, numbers []int) bool {
+	// Split up positive and negative numbers into separate lists and make negative numbers positive for comparison.
+	positiveNums := []int{}
+	negativeNums := []int{}
+	for _, n := range numbers {
+		if n >= 0 {
+			positiveNums = append(positiveNums, n)
+		} else {
+			negativeNums = append(negativeNums, -n)
+		}
 	}
-	lookupNums := generateLookupNums(q.MinimumSearchNumber, q.MaximumSearchNumber, q.TargetNumbers, q.MasterNumbers, q.ReduceWords)
-	if len(lookupNums) > 0 {
-		query = query.Where(q.ColumnName+" IN ?", lookupNums)
-	} else {
-		query = query.Where("FALSE")
+	for _, v := range steps {
+		if inIntSlice(v, negativeNums) {
+			return false
+		}
+		if inIntSlice(v, positiveNums) {
+			return true
+		}
 	}
-	return
+	// If there are positive numbers then there is only a match when we specifically find a number. The
+	// alternative is that any number is acceptable as long as it isn't a negative number.
+	return len(positiveNums) == 0
 }
 
-func addKarmicDebtLookup(query *gorm.DB, q queryLookup) {
+// addQueryLookup adds the lookup numbers for a column to the query. The lookup numbers are returned so they
+// can be reported by an explanation of the search. A nil slice means that the column is not being filtered.
+func addQueryLookup(query *nameQuery, q queryLookup) (lookupNums []int) {
 	if len(q.TargetNumbers) == 0 {
-		return
+		return nil
 	}
-	lookupNums := generateLookupNums(q.MinimumSearchNumber, q.MaximumSearchNumber, q.TargetNumbers, q.MasterNumbers, q.ReduceWords)
-	if len(lookupNums) > 0 {
-		query = query.Where(q.ColumnName+" NOT IN ?", lookupNums)
+	lookupNums = generateLookupNums(q.MinimumSearchNumber, q.MaximumSearchNumber, q.TargetNumbers, q.MasterNumbers, q.ReduceWords)
+	if len(lookupNums) == 0 {
+		lookupNums = []int{}
 	}
-	return
+	query.Lookups = append(query.Lookups, columnLookup{q.ColumnName, lookupNums})
+	return lookupNums
 }
 
-func addQueryGender(query *gorm.DB, gender rune) {
+// defaultUnisexWithin is the percent used for unisex searches when NameSearchOpts.UnisexWithin is not set.
+const defaultUnisexWithin = 25
+
+func addQueryGender(query *nameQuery, gender rune, unisexWithin int) {
 	// Add gender parameter if specified. Otherwise include all genders.
-	if unicode.ToUpper(gender) == 'M' || unicode.ToUpper(gender) == 'F' {
-		query = query.Where("gender = ?", string(unicode.ToUpper(gender)))
+	query.RankColumn = "popularity_rank"
+	switch Gender(unicode.ToUpper(gender)) {
+	case Male:
+		query.Gender = string(Male)
+		query.RankColumn = "male_rank"
+	case Female:
+		query.Gender = string(Female)
+		query.RankColumn = "female_rank"
+	case Unisex:
+		if unisexWithin <= 0 {
+			unisexWithin = defaultUnisexWithin
+		}
+		// The less common gender has to be within the percent of the more common gender.
+		query.Gender = string(Unisex)
+		query.UnisexRatio = 1 - float64(unisexWithin)/100
+	}
+}
+
+// addQueryRanks sets the popularity ranks that divide common and uncommon names.
+func addQueryRanks(query *nameQuery, opts NameSearchOpts, tableSize int64) {
+	query.CommonRank = int64(opts.CommonRank)
+	query.UncommonRank = int64(opts.UncommonRank)
+	if query.UncommonRank <= 0 {
+		// Skip past the most popular quarter of the names so the names are less common.
+		query.UncommonRank = tableSize/4 + 1
+	}
+}
+
+// addQueryTrend limits the names by how their popularity has changed.
+func addQueryTrend(query *nameQuery, trend string) error {
+	switch strings.ToLower(trend) {
+	case "":
+	case TrendingNames:
+		query.Trend = TrendingNames
+	case ClassicNames:
+		query.Trend = ClassicNames
+	default:
+		return fmt.Errorf("unknown trend %v", trend)
 	}
-	return
+	return nil
 }
 
-// Order the results based on the selected option
-func addQuerySort(query *gorm.DB, sort string, offset int, seed int64) {
-	switch strings.ToLower(sort) {
-	case UncommonSort:
-		// Todo: Do not hardcode uncommon skip value
-		// Skip down a ways so the names are less common.
-		skip := 5000
-		if offset > skip {
-			skip = offset
+// nameFilter is a check on a name that cannot be done by the database and has to be done after the names are
+// fetched.
+type nameFilter struct {
+	Description string
+	Match       func(name string) bool
+}
+
+// addQueryNameConstraints adds the textual constraints on the searched name to the query. Constraints that the
+// store is not able to check are returned as nameFilters.
+func addQueryNameConstraints(query *nameQuery, opts NameSearchOpts, patternSupported bool) (filters []nameFilter, err error) {
+	query.MinLength = opts.MinLength
+	query.MaxLength = opts.MaxLength
+	for _, r := range strings.ToLower(opts.FirstLetters) {
+		query.FirstLetters = append(query.FirstLetters, string(r))
+	}
+	query.Exclude = lowerStrings(opts.Exclude)
+	query.Include = lowerStrings(opts.Include)
+	if opts.Pattern != "" {
+		pattern, err := regexp.Compile(opts.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name pattern: %v", err)
+		}
+		if patternSupported {
+			query.Pattern = opts.Pattern
+		} else {
+			filters = append(filters, nameFilter{"pattern " + opts.Pattern, pattern.MatchString})
 		}
-		query = query.Where("id >= ?", skip).Order("id asc")
-	case RandomSort:
-		// Randomizing order with seed. https://stackoverflow.com/a/24511461
-		randSource := rand.NewSource(seed)
-		newRand := rand.New(randSource)
-		multiplier := newRand.Float64()
-		query = query.Order(fmt.Sprintf("(substr(id * %v, length(id) + 2))", multiplier))
-		// Offset for random uses regular offset function of db because there is no easier way to skip results.
-		query = query.Offset(offset)
-	default: // Default is a catchall for "common"
-		query = query.Where("id >= ?", offset).Order("id asc")
 	}
-	return
+	if opts.MinSyllables > 0 || opts.MaxSyllables > 0 {
+		filters = append(filters, nameFilter{
+			fmt.Sprintf("syllables between %v and %v", opts.MinSyllables, opts.MaxSyllables),
+			func(name string) bool {
+				syllables := countSyllables(name)
+				return syllables >= opts.MinSyllables && (opts.MaxSyllables == 0 || syllables <= opts.MaxSyllables)
+			},
+		})
+	}
+	return filters, nil
+}
+
+// addQuerySoundsLike limits the query to names that share a phonetic encoding with the given name.
+func addQuerySoundsLike(query *nameQuery, soundsLike string) {
+	if strings.TrimSpace(soundsLike) == "" {
+		return
+	}
+	keys := getPhoneticKeys(soundsLike)
+	query.SoundsLike = &keys
+}
+
+func lowerStrings(s []string) (lower []string) {
+	for _, i := range s {
+		lower = append(lower, strings.ToLower(strings.TrimSpace(i)))
+	}
+	return lower
 }
 
 // A surprisingly difficult function to find the criteria to match the Hidden Passions criteria. Some of the
 // difficulty comes from the fact that negative numbers acting as exclusionary complicates the analysis.
-func queryHiddenPassions(query *gorm.DB, name string, hiddenPassions []int, numberSystem NumberSystem) {
+func queryHiddenPassions(query *nameQuery, name string, hiddenPassions []int, numberSystem NumberSystem) {
 	if len(hiddenPassions) == 0 {
 		return
 	}
-	type BuildQuery struct {
-		Left       string
-		Comparator string
-		Right      interface{}
-		Target     int
-	}
-	// Prefix needed to pick column when querying the database.
-	prefix := string(strings.ToLower(numberSystem.Name)[0])
+	// Columns that hold the counts of the number system. ex. p1, p2
+	columns := columnsOf(numberSystem)
 	// RunCount all the numerological numbers.
 	currentCount, maxCount, _ := countNumerologicalNumbers(name, numberSystem)
 	// Sort the numbers from largest to smallest. This is important because we want negative numbers last.
@@ -176,7 +249,7 @@ func queryHiddenPassions(query *gorm.DB, name string, hiddenPassions []int, numb
 	// If the largest number is negative then all the numbers are negative. Process differently.
 	if prime < 0 {
 		for _, hp := range hiddenPassions {
-			var where *gorm.DB
+			var group conditionGroup
 			hpCount, _ := currentCount[int32(-hp)]
 			// Loop through all valid numbers and find any one that is bigger than the negative prime we do not want.
 			for _, i := range numberSystem.ValidNumbers {
@@ -184,25 +257,12 @@ func queryHiddenPassions(query *gorm.DB, name string, hiddenPassions []int, numb
 					continue
 				}
 				iCount, _ := currentCount[int32(i)]
-				leftCol := fmt.Sprintf("%v%d", prefix, i)
-				rightCol := fmt.Sprintf("%v%d", prefix, -hp)
-
-				if iCount-hpCount == 0 { // If modifier is '0' then no reason for unnecessary addition in query.
-					if where == nil { // If our Where clause hasn't been initialized yet then do it now.
-						where = DB.Where(fmt.Sprintf("%v > %v", leftCol, rightCol))
-					} else { // if already initialized then add an Or clause
-						where.Or(fmt.Sprintf("%v > %v", leftCol, rightCol))
-					}
-				} else {
-					if where == nil { // If our Where clause hasn't been initialized yet then do it now.
-						where = DB.Where(fmt.Sprintf("%v > %v - ?", leftCol, rightCol), iCount-hpCount)
-					} else { // if already initialized then add an Or clause
-						where.Or(fmt.Sprintf("%v > %v - ?", leftCol, rightCol), iCount-hpCount)
-					}
-				}
+				leftCol := columns.Count(i)
+				rightCol := columns.Count(-hp)
+				group = append(group, countCondition{leftCol, ">", rightCol, hpCount - iCount})
 			}
 			// Add grouped query to main query
-			query = query.Where(where)
+			query.Conditions = append(query.Conditions, group)
 		}
 		return
 	}
@@ -211,23 +271,21 @@ func queryHiddenPassions(query *gorm.DB, name string, hiddenPassions []int, numb
 	primeCount, _ := currentCount[int32(prime)]
 
 	// Loop through all valid number system numbers and build the initial query values.
-	buildQuery := map[int]BuildQuery{}
+	buildQuery := map[int]countCondition{}
 	for _, i := range numberSystem.ValidNumbers {
 		if i == prime {
-			// Column name is prefix + number. ex p1, p2
-			col := fmt.Sprintf("%v%d", prefix, i)
+			col := columns.Count(i)
 			// The prime number count needs to be as large or larger than the current largest count.
-			buildQuery[i] = BuildQuery{col, ">=", maxCount - primeCount, 0}
+			buildQuery[i] = countCondition{col, ">=", "", maxCount - primeCount}
 		} else {
-			// Column name is prefix + number. ex p1, p2
-			leftCol := fmt.Sprintf("%v%d", prefix, i)
-			rightCol := fmt.Sprintf("%v%d", prefix, prime)
+			leftCol := columns.Count(i)
+			rightCol := columns.Count(prime)
 			// Get count of number
 			count, _ := currentCount[int32(i)]
 			// In order to satisfy search condition this number needs to be less than or equal to a target
 			// that is equal to the difference between the count of this number and the prime number
 			targetCount := primeCount - count
-			buildQuery[i] = BuildQuery{leftCol, "<=", rightCol, targetCount}
+			buildQuery[i] = countCondition{leftCol, "<=", rightCol, targetCount}
 		}
 	}
 	// Loop through numbers to adjust query. Skip the first number because it was handled as the prime number.
@@ -243,77 +301,324 @@ func queryHiddenPassions(query *gorm.DB, name string, hiddenPassions []int, numb
 		}
 	}
 	// Now that we have sufficiently modified the queries. Add them to the main query.
-	for _, v := range buildQuery {
-		if v.Target != 0 {
-			query = query.Where(fmt.Sprintf("%v %v %v + ?", v.Left, v.Comparator, v.Right), v.Target)
-		} else {
-			query = query.Where(fmt.Sprintf("%v %v %v", v.Left, v.Comparator, v.Right))
-		}
+	for _, i := range numberSystem.ValidNumbers {
+		query.Conditions = append(query.Conditions, conditionGroup{buildQuery[i]})
 	}
 }
 
-func queryKarmicLessons(query *gorm.DB, name string, n []int, numberSystem NumberSystem) {
+func queryKarmicLessons(query *nameQuery, name string, n []int, numberSystem NumberSystem) {
 	if len(n) == 0 {
 		return
 	}
-	// Prefix needed to pick column when querying the database.
-	prefix := string(strings.ToLower(numberSystem.Name)[0])
+	// Columns that hold the counts of the number system. ex. p1, p2
+	columns := columnsOf(numberSystem)
 	// RunCount all the numerological numbers.
 	currentCount, _, _ := countNumerologicalNumbers(name, numberSystem)
 	for _, i := range n {
 		if i < 0 {
-			col := fmt.Sprintf("%v%d", prefix, -i)
+			col := columns.Count(-i)
 			count, _ := currentCount[int32(-i)]
-			query = query.Where(col+" + ? > 0", count)
+			// The number has to show up at least once in the full name.
+			query.Conditions = append(query.Conditions, conditionGroup{{col, ">", "", -count}})
 		} else {
-			col := fmt.Sprintf("%v%d", prefix, i)
+			col := columns.Count(i)
 			count, _ := currentCount[int32(i)]
 			// This will either be 0 or less than 0.
 			// Less than 0 is impossible match and therefore acts to excludes these rows.
-			query = query.Where(col+" <= ?", 0-count)
+			query.Conditions = append(query.Conditions, conditionGroup{{col, "<=", "", 0 - count}})
 		}
 	}
 }
 
-// This function does all the heavy lifting for searching names.
-func nameSearch(n string, numberSystem NumberSystem, masterNumbers []int, reduceWords bool, opts NameSearchOpts) (results []NameNumerology, offset int64, err error) {
+// NameSearchExplanation describes how a name search would be carried out without actually running it. It is
+// meant for debugging searches that return unexpected results.
+type NameSearchExplanation struct {
+	// SQL is the query that would be sent to the database with the values bound in place. It is empty for
+	// in-memory dictionaries.
+	SQL string `json:"sql"`
+
+	// BaseName is the name that is used as the basis of the search. Every result is this name with the '?'
+	// replaced by a name from the dictionary.
+	BaseName string `json:"base_name"`
+
+	// BaseValues contains the unreduced Full, Vowels, and Consonants values of BaseName.
+	BaseValues map[string]int `json:"base_values"`
+
+	// BaseCounts contains how many times each number appears in BaseName. It is the starting point of the
+	// Hidden Passions and Karmic Lessons queries.
+	BaseCounts map[int32]int `json:"base_counts"`
+
+	// Lookups contains the unreduced values that are searched for in each column. An empty slice means that no
+	// value could satisfy the criteria so the search can never return results.
+	Lookups map[string][]int `json:"lookups"`
+
+	// Filters lists the checks that are done on the names after they are fetched from the database because the
+	// database is unable to do them.
+	Filters []string `json:"filters"`
+}
+
+// preparedNameSearch holds a name search query that is ready to be executed along with the information that
+// was used to build it.
+type preparedNameSearch struct {
+	store             nameStore
+	query             nameQuery
+	table             string
+	tableSize         int64
+	opts              NameSearchOpts
+	requiredOpts      *NameOpts
+	searchOpts        *NameSearchOpts
+	reconstructedName string
+	base              NameNumerology
+	lookups           map[string][]int
+	filters           []nameFilter
+}
+
+// postFilterBatchSize is the number of rows fetched at a time when names have to be checked after they are
+// fetched. It is larger than usual because some of the rows are expected to be thrown away.
+const postFilterBatchSize = 500
+
+// match checks whether a name passes all the filters that could not be done by the database.
+func (s *preparedNameSearch) match(name string) bool {
+	for _, f := range s.filters {
+		if !f.Match(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// rankedInGo reports whether the names are sorted after they are fetched instead of by the database.
+func (s *preparedNameSearch) rankedInGo() bool {
+	switch strings.ToLower(s.opts.Sort) {
+	case PhoneticSort:
+		return strings.TrimSpace(s.opts.SoundsLike) != ""
+	case ScoreSort:
+		return true
+	}
+	return false
+}
+
+// result creates the NameNumerology of the full name using a name from the dictionary.
+func (s *preparedNameSearch) result(r precalculatedNumerology) NameNumerology {
+	// Use reconstructedName because we want to replace the whole ? name, and not accidentally include additional letters.
+	// John Da? Doe would come out as John DaDavid Doe. reconstructedName avoids this.
+	newName := strings.Replace(s.reconstructedName, "?", r.Name, 1)
+	return NameNumerology{
+		Name:           newName,
+		NameOpts:       s.requiredOpts,
+		NameSearchOpts: s.searchOpts,
+		Dictionary:     s.table,
+		Origin:         r.Origin,
+		Meaning:        r.Meaning,
+		variants:       r.Variants,
+		nicknames:      r.Nicknames,
+		template:       s.reconstructedName,
+	}
+}
+
+// candidates fetches every name that matches the search in order of popularity.
+func (s *preparedNameSearch) candidates() (names []precalculatedNumerology, err error) {
+	all, err := s.store.find(s.table, s.query, s.opts.Sort, 0, s.opts.Seed, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range all {
+		if s.match(r.Name) {
+			names = append(names, r)
+		}
+	}
+	return names, nil
+}
+
+// sortRanked sorts names in Go using the sort method of the search. Names that sort equally are kept in the
+// order they were given.
+func (s *preparedNameSearch) sortRanked(names []precalculatedNumerology) {
+	switch strings.ToLower(s.opts.Sort) {
+	case ScoreSort:
+		scores := map[string]float64{}
+		for _, r := range names {
+			scores[r.Name] = scoreName(s.result(r), s.opts.Weights).Total
+		}
+		sort.SliceStable(names, func(i, j int) bool {
+			return scores[names[i].Name] > scores[names[j].Name]
+		})
+	case PhoneticSort:
+		type distance struct{ sound, spelling int }
+		distances := map[string]distance{}
+		for _, r := range names {
+			sound, spelling := phoneticDistance(s.opts.SoundsLike, r.Name)
+			distances[r.Name] = distance{sound, spelling}
+		}
+		sort.SliceStable(names, func(i, j int) bool {
+			a, b := distances[names[i].Name], distances[names[j].Name]
+			if a.sound != b.sound {
+				return a.sound < b.sound
+			}
+			return a.spelling < b.spelling
+		})
+	}
+}
+
+// fetch gets up to count names that match the search starting at the given offset. The offset is a rank for
+// common and uncommon sorts and a row position for random sort. Consumed is the number of rows that were looked
+// at, which random sort uses to derive the next offset.
+func (s *preparedNameSearch) fetch(offset int, count int) (names []precalculatedNumerology, consumed int, err error) {
+	limit := count
+	if len(s.filters) > 0 && limit < postFilterBatchSize {
+		limit = postFilterBatchSize
+	}
+	batchOffset := offset
+	for {
+		batch, err := s.store.find(s.table, s.query, s.opts.Sort, batchOffset, s.opts.Seed, limit)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, r := range batch {
+			if len(names) == count {
+				break
+			}
+			consumed++
+			if s.match(r.Name) {
+				names = append(names, r)
+			}
+		}
+		if len(names) == count || len(batch) < limit {
+			return names, consumed, nil
+		}
+		// Fetch the next batch after the last row that was looked at.
+		if strings.ToLower(s.opts.Sort) == RandomSort {
+			batchOffset = offset + consumed
+		} else {
+			batchOffset = int(batch[len(batch)-1].rank(s.query.RankColumn)) + 1
+		}
+	}
+}
+
+// rankedSearchCacheSize is the number of searches whose sorted names are kept by rankedSearches.
+const rankedSearchCacheSize = 8
+
+// rankedSearch is every name that matches a search that is sorted in Go, in sorted order.
+type rankedSearch struct {
+	key   string
+	table string
+	names []precalculatedNumerology
+}
+
+// rankedSearchCache keeps the sorted names of the most recent searches that are sorted in Go. Score and phonetic
+// sorts have to fetch and sort every name that matches before the first page can be returned, so the sorted names
+// are kept for the pages that follow. The least recently used search is dropped first.
+type rankedSearchCache struct {
+	mu       sync.Mutex
+	searches []rankedSearch
+}
+
+var rankedSearches = &rankedSearchCache{}
+
+// get returns the sorted names of a search, and false if they are not cached.
+func (c *rankedSearchCache) get(key string) ([]precalculatedNumerology, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, r := range c.searches {
+		if r.key == key {
+			// Move the search to the front so that it is dropped last.
+			copy(c.searches[1:i+1], c.searches[:i])
+			c.searches[0] = r
+			return r.names, true
+		}
+	}
+	return nil, false
+}
+
+// put adds the sorted names of a search and drops the least recently used search when the cache is full.
+func (c *rankedSearchCache) put(key string, table string, names []precalculatedNumerology) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.searches = append([]rankedSearch{{key, table, names}}, c.searches...)
+	if len(c.searches) > rankedSearchCacheSize {
+		c.searches = c.searches[:rankedSearchCacheSize]
+	}
+}
+
+// forget drops the searches of a dictionary.
+func (c *rankedSearchCache) forget(table string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := c.searches[:0]
+	for _, r := range c.searches {
+		if r.table != table {
+			kept = append(kept, r)
+		}
+	}
+	c.searches = kept
+}
+
+// rankedKey identifies the sorted names of a search. Everything about the search except the page is part of it.
+// ex. The Weights of a score sort and the SoundsLike of a phonetic sort
+func (s *preparedNameSearch) rankedKey() string {
+	opts := s.opts
+	opts.Offset, opts.Count = 0, 0
+	key, _ := json.Marshal(struct {
+		Table    string
+		Name     string
+		NameOpts NameOpts
+		Opts     NameSearchOpts
+	}{s.table, s.reconstructedName, *s.requiredOpts, opts})
+	return string(key)
+}
+
+// fetchRanked gets up to count names that match the search starting at the given position after the names
+// are sorted in Go. The sorted names are cached so that only the first page of a search has to fetch and sort
+// every name that matches.
+func (s *preparedNameSearch) fetchRanked(offset int, count int) (names []precalculatedNumerology, consumed int, err error) {
+	key := s.rankedKey()
+	ranked, ok := rankedSearches.get(key)
+	if !ok {
+		if ranked, err = s.candidates(); err != nil {
+			return nil, 0, err
+		}
+		s.sortRanked(ranked)
+		rankedSearches.put(key, s.table, ranked)
+	}
+	if offset >= len(ranked) {
+		return nil, 0, nil
+	}
+	ranked = ranked[offset:]
+	if len(ranked) > count {
+		ranked = ranked[:count]
+	}
+	// The names are copied so that the cached names cannot be changed.
+	return append([]precalculatedNumerology{}, ranked...), len(ranked), nil
+}
+
+// prepareNameSearch opens the store of the dictionary and builds the query for a name search without executing it.
+func prepareNameSearch(n string, numberSystem NumberSystem, masterNumbers []int, reduceWords bool, opts NameSearchOpts) (search *preparedNameSearch, err error) {
 	requiredOpts := NameOpts{
 		NumberSystem:  numberSystem,
 		MasterNumbers: masterNumbers,
 		ReduceWords:   reduceWords,
 	}
-	searchOpts := NameSearchOpts{
-		Count:          opts.Count,
-		Offset:         opts.Offset,
-		Seed:           opts.Seed,
-		Dictionary:     opts.Dictionary,
-		Gender:         opts.Gender,
-		Sort:           opts.Sort,
-		Full:           opts.Full,
-		Vowels:         opts.Vowels,
-		Consonants:     opts.Consonants,
-		HiddenPassions: opts.HiddenPassions,
-		KarmicLessons:  opts.KarmicLessons,
-		Database:       opts.Database,
-	}
-
-	if DB == nil {
-		err = connectToDatabase(opts.Database)
-		if err != nil {
-			return []NameNumerology{}, 0, err
-		}
+	if opts.Dictionary == "" && usesEmbeddedDictionary(opts.Database) {
+		opts.Dictionary = EmbeddedDictionary
+	}
+	searchOpts := opts
+
+	store, err := openNameStore(opts.Database)
+	if err != nil {
+		return nil, err
 	}
 
 	table := strings.ToLower(opts.Dictionary)
 	// Make sure table is valid and has names.
-	var count int64
-	DB.Table(table).Count(&count)
+	count := store.count(table)
 	if count == 0 {
-		return []NameNumerology{}, 0, fmt.Errorf("database table %v is empty", opts.Dictionary)
+		return nil, fmt.Errorf("database table %v is empty", opts.Dictionary)
+	}
+	if err := checkDictionaryMetadata(store, opts.Database, table); err != nil {
+		return nil, err
 	}
 
-	// Lowercase the number system name for use later.
-	nsName := strings.ToLower(numberSystem.Name)
+	// Columns that hold the values of the number system.
+	columns := columnsOf(numberSystem)
 
 	splitNames := strings.Split(n, " ")
 	var nameToSearch string
@@ -325,88 +630,166 @@ func nameSearch(n string, numberSystem NumberSystem, masterNumbers []int, reduce
 		}
 	}
 	reconstructedName := strings.Join(splitNames, " ")
-	nonSearchNameResults := NameNumerology{reconstructedName, &requiredOpts, nil, nil, nil, nil}
+	nonSearchNameResults := NameNumerology{Name: reconstructedName, NameOpts: &requiredOpts}
 
 	// Begin constructing the query
 	if opts.Count == 0 {
 		opts.Count = 25
 	}
-	query := DB.Table(table).Select("min(id) as id, name").Group("name")
-	// Increase query count by 1 because we will use the last result as an indication that there are more results
-	// that can be paged through. The extra result will be dropped in the return.
-	query = query.Limit(opts.Count + 1)
+	// Sorting and limiting the results are added when the query is executed. See nameStore.find.
+	query := nameQuery{NumberSystem: columns.Key}
 
 	// If there are letters around the ? then we need to do a LIKE search.
 	if len(nameToSearch) > 1 {
-		query = query.Where("LOWER(name) LIKE ?", strings.Replace(strings.ToLower(nameToSearch), "?", "%", -1))
+		query.Like = strings.Replace(strings.ToLower(nameToSearch), "?", "%", -1)
 	}
 
-	addQuerySort(query, opts.Sort, opts.Offset, opts.Seed)
-	addQueryGender(query, rune(opts.Gender))
+	addQueryGender(&query, rune(opts.Gender), opts.UnisexWithin)
+	addQueryRanks(&query, opts, count)
+	if err := addQueryTrend(&query, opts.Trend); err != nil {
+		return nil, err
+	}
+	for _, origin := range opts.Origins {
+		query.Origins = append(query.Origins, strings.ToLower(origin))
+	}
+	filters, err := addQueryNameConstraints(&query, opts, store.supportsPattern())
+	if err != nil {
+		return nil, err
+	}
+	addQuerySoundsLike(&query, opts.SoundsLike)
 
-	queryHiddenPassions(query, reconstructedName, opts.HiddenPassions, numberSystem)
-	queryKarmicLessons(query, reconstructedName, opts.KarmicLessons, numberSystem)
+	queryHiddenPassions(&query, reconstructedName, opts.HiddenPassions, numberSystem)
+	queryKarmicLessons(&query, reconstructedName, opts.KarmicLessons, numberSystem)
 
-	// Get the largest name value from the database and cache it so we don't have to look it up again.
-	var largestNameValueInDb int
-	var ok bool
-	if largestNameValueInDb, ok = largestNameValueInTable[table]; !ok {
-		if err := DB.Table(table).Select("max(max(pythagorean_full), max(chaldean_full)) as largest").Find(&largestNameValueInDb).Error; err != nil {
-			largestNameValueInDb = 100
-		}
-		largestNameValueInTable[table] = largestNameValueInDb
-	}
+	largestNameValueInDb := store.largestValue(table)
 
+	lookups := map[string][]int{}
 	// Lookup for Full numbers
-	addQueryLookup(query, queryLookup{
+	if nums := addQueryLookup(&query, queryLookup{
 		MinimumSearchNumber: nonSearchNameResults.Full().ReduceSteps[0],
 		MaximumSearchNumber: nonSearchNameResults.Full().ReduceSteps[0] + largestNameValueInDb,
 		TargetNumbers:       opts.Full,
-		ColumnName:          nsName + "_full",
+		ColumnName:          columns.Full,
 		MasterNumbers:       masterNumbers,
 		ReduceWords:         reduceWords,
-	})
+	}); nums != nil {
+		lookups[columns.Full] = nums
+	}
 	// Lookup for Vowel numbers
-	addQueryLookup(query, queryLookup{
+	if nums := addQueryLookup(&query, queryLookup{
 		MinimumSearchNumber: nonSearchNameResults.Vowels().ReduceSteps[0],
 		MaximumSearchNumber: nonSearchNameResults.Vowels().ReduceSteps[0] + largestNameValueInDb,
 		TargetNumbers:       opts.Vowels,
-		ColumnName:          nsName + "_vowels",
+		ColumnName:          columns.Vowels,
 		MasterNumbers:       masterNumbers,
 		ReduceWords:         reduceWords,
-	})
+	}); nums != nil {
+		lookups[columns.Vowels] = nums
+	}
 	// Lookup for Consonant numbers
-	addQueryLookup(query, queryLookup{
+	if nums := addQueryLookup(&query, queryLookup{
 		MinimumSearchNumber: nonSearchNameResults.Consonants().ReduceSteps[0],
 		MaximumSearchNumber: nonSearchNameResults.Consonants().ReduceSteps[0] + largestNameValueInDb,
 		TargetNumbers:       opts.Consonants,
-		ColumnName:          nsName + "_consonants",
+		ColumnName:          columns.Consonants,
 		MasterNumbers:       masterNumbers,
 		ReduceWords:         reduceWords,
-	})
+	}); nums != nil {
+		lookups[columns.Consonants] = nums
+	}
+
+	return &preparedNameSearch{
+		store:             store,
+		query:             query,
+		table:             table,
+		tableSize:         count,
+		opts:              opts,
+		requiredOpts:      &requiredOpts,
+		searchOpts:        &searchOpts,
+		reconstructedName: reconstructedName,
+		base:              nonSearchNameResults,
+		lookups:           lookups,
+		filters:           filters,
+	}, nil
+}
+
+// This function does all the heavy lifting for searching names.
+func nameSearch(n string, numberSystem NumberSystem, masterNumbers []int, reduceWords bool, opts NameSearchOpts) (results []NameNumerology, offset int64, err error) {
+	if len(opts.Dictionaries) > 0 {
+		return multiDictionarySearch(n, numberSystem, masterNumbers, reduceWords, opts)
+	}
+	search, err := prepareNameSearch(n, numberSystem, masterNumbers, reduceWords, opts)
+	if err != nil {
+		return []NameNumerology{}, 0, err
+	}
+	opts = search.opts
 
+	// Increase query count by 1 because we will use the last result as an indication that there are more results
+	// that can be paged through. The extra result will be dropped in the return.
 	var selectedNames []precalculatedNumerology
-	func() {
-		query.Find(&selectedNames)
-	}()
+	var consumed int
+	if search.rankedInGo() {
+		// Names that are sorted in Go are paged through using their position in the sorted list.
+		selectedNames, consumed, err = search.fetchRanked(opts.Offset, opts.Count+1)
+	} else {
+		selectedNames, consumed, err = search.fetch(opts.Offset, opts.Count+1)
+	}
+	if err != nil {
+		return []NameNumerology{}, 0, err
+	}
 
 	results = []NameNumerology{}
 	for i, r := range selectedNames {
-		// In order to find out if there are more results, we search for 1 extra and make a note of its id
+		// In order to find out if there are more results, we search for 1 extra and make a note of its rank
 		// to use as an offset later. Then exclude the final result from what is returned.
 		if len(selectedNames) <= opts.Count || i < len(selectedNames)-1 {
-			// Use reconstructedName because we want to replace the whole ? name, and not accidentally include additional letters.
-			// John Da? Doe would come out as John DaDavid Doe. reconstructedName avoids this.
-			newName := strings.Replace(reconstructedName, "?", r.Name, 1)
 			// Calculate the numerology results using the new full name.
-			results = append(results, NameNumerology{newName, &requiredOpts, &searchOpts, nil, nil, nil})
+			results = append(results, search.result(r))
 		} else {
-			offset = r.Id
+			offset = r.rank(search.query.RankColumn)
 		}
 	}
-	// If sort is random then we need to derive the offset a different way.
-	if offset > 0 && strings.ToLower(opts.Sort) == RandomSort {
-		offset = int64(opts.Offset + opts.Count)
+	// If sort is random, or the names were sorted in Go, then we need to derive the offset a different way. The
+	// extra result is the last row that was looked at. Its rank is not used because a row that was inserted by hand
+	// can have a rank of 0.
+	if len(selectedNames) > opts.Count && (strings.ToLower(opts.Sort) == RandomSort || search.rankedInGo()) {
+		offset = int64(opts.Offset + consumed - 1)
 	}
 	return results, offset, nil
 }
+
+// nameSearchExplain builds the query for a name search and reports how it was constructed without executing it.
+func nameSearchExplain(n string, numberSystem NumberSystem, masterNumbers []int, reduceWords bool, opts NameSearchOpts) (explanation NameSearchExplanation, err error) {
+	search, err := prepareNameSearch(n, numberSystem, masterNumbers, reduceWords, opts)
+	if err != nil {
+		return NameSearchExplanation{}, err
+	}
+	limit := search.opts.Count + 1
+	if search.rankedInGo() {
+		limit = 0
+	}
+	sql := search.store.explain(search.table, search.query, search.opts.Sort, search.opts.Offset, search.opts.Seed, limit)
+	filters := []string{}
+	for _, f := range search.filters {
+		filters = append(filters, f.Description)
+	}
+	switch {
+	case search.rankedInGo() && strings.ToLower(search.opts.Sort) == ScoreSort:
+		filters = append(filters, "sort by score")
+	case search.rankedInGo():
+		filters = append(filters, "sort by phonetic distance to "+search.opts.SoundsLike)
+	}
+	counts, _, _ := countNumerologicalNumbers(search.reconstructedName, search.requiredOpts.NumberSystem)
+	return NameSearchExplanation{
+		SQL:      sql,
+		BaseName: search.reconstructedName,
+		BaseValues: map[string]int{
+			"full":       search.base.Full().ReduceSteps[0],
+			"vowels":     search.base.Vowels().ReduceSteps[0],
+			"consonants": search.base.Consonants().ReduceSteps[0],
+		},
+		BaseCounts: counts,
+		Lookups:    search.lookups,
+		Filters:    filters,
+	}, nil
+}
@@ -11,10 +11,38 @@
 package numerology
 
 import (
+	"fmt"
 	mapset "github.com/deckarep/golang-set"
+	"reflect"
+	"strings"
 	"testing"
 )
 
+func Test_matchesNumbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []int
+		numbers []int
+		want    bool
+	}{
+		{"NoNumbers", []int{29, 11, 2}, nil, true},
+		{"IncludeFinal", []int{29, 11, 2}, []int{2}, true},
+		{"IncludeIntermediate", []int{29, 11, 2}, []int{11}, true},
+		{"NotIncluded", []int{29, 11, 2}, []int{3}, false},
+		{"Exclude", []int{29, 11, 2}, []int{-2}, false},
+		{"ExcludeOnly", []int{29, 11, 2}, []int{-3}, true},
+		{"ExcludeBeforeInclude", []int{29, 11, 2}, []int{2, -11}, false},
+		{"IncludeBeforeExclude", []int{29, 11, 2}, []int{11, -2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesNumbers(tt.steps, tt.numbers); got != tt.want {
+				t.Errorf("matchesNumbers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_nameSearchRandomCheck(t *testing.T) {
 	first, offset, _ := nameSearch("Random ? Test", Pythagorean, []int{11, 22, 33}, true, NameSearchOpts{
 		Count:      5,
@@ -252,3 +280,416 @@ func Test_nameSearch(t *testing.T) {
 	}
 	DB = nil
 }
+
+func Test_nameSearchExplain(t *testing.T) {
+	opts := NameSearchOpts{
+		Count:          10,
+		Dictionary:     "usa_census",
+		Gender:         Gender('F'),
+		Sort:           "common",
+		Full:           []int{1, -13},
+		HiddenPassions: []int{5},
+		Database:       "sqlite://file::memory:?cache=shared",
+	}
+	explanation, err := nameSearchExplain("Jane M? Doe", Pythagorean, []int{11, 22, 33}, true, opts)
+	if err != nil {
+		t.Fatalf("nameSearchExplain() error = %v", err)
+	}
+	if explanation.BaseName != "Jane ? Doe" {
+		t.Errorf("nameSearchExplain() BaseName = %v, want %v", explanation.BaseName, "Jane ? Doe")
+	}
+	if explanation.BaseValues["full"] != Name("Jane ? Doe", Pythagorean, []int{11, 22, 33}, true).Full().ReduceSteps[0] {
+		t.Errorf("nameSearchExplain() BaseValues = %v", explanation.BaseValues)
+	}
+	if _, ok := explanation.Lookups["pythagorean_full"]; !ok {
+		t.Errorf("nameSearchExplain() missing lookup for pythagorean_full. %v", explanation.Lookups)
+	}
+	if _, ok := explanation.Lookups["pythagorean_vowels"]; ok {
+		t.Errorf("nameSearchExplain() unexpected lookup for pythagorean_vowels. %v", explanation.Lookups)
+	}
+	for _, want := range []string{"LIKE \"m%\"", "female_rank > 0", "pythagorean_full IN (", "LIMIT 11"} {
+		if !strings.Contains(explanation.SQL, want) {
+			t.Errorf("nameSearchExplain() SQL = %v, missing %v", explanation.SQL, want)
+		}
+	}
+	// The lookups must agree with the results of an actual search.
+	results, _, _ := nameSearch("Jane M? Doe", Pythagorean, []int{11, 22, 33}, true, opts)
+	for _, r := range results {
+		full := r.Full().ReduceSteps
+		if !inIntSlice(full[len(full)-1], []int{1}) || inIntSlice(13, full) {
+			t.Errorf("Unexpected result. %v %v", r.Name, full)
+		}
+	}
+	DB = nil
+}
+
+func Test_nameSearchNameConstraints(t *testing.T) {
+	baseSearch := NameSearchOpts{
+		Count:      30,
+		Dictionary: "usa_census",
+		Gender:     Gender('B'),
+		Sort:       "common",
+		Database:   "sqlite://file::memory:?cache=shared",
+	}
+	tests := []struct {
+		name  string
+		opts  func(opts *NameSearchOpts)
+		check func(name string) bool
+	}{
+		{"Length", func(opts *NameSearchOpts) { opts.MinLength, opts.MaxLength = 4, 5 },
+			func(name string) bool { return len(name) >= 4 && len(name) <= 5 }},
+		{"FirstLetters", func(opts *NameSearchOpts) { opts.FirstLetters = "Jk" },
+			func(name string) bool { return strings.HasPrefix(name, "J") || strings.HasPrefix(name, "K") }},
+		{"Syllables", func(opts *NameSearchOpts) { opts.MinSyllables, opts.MaxSyllables = 3, 3 },
+			func(name string) bool { return countSyllables(name) == 3 }},
+		{"Pattern", func(opts *NameSearchOpts) { opts.Pattern = "^[A-Z][a-z]*ie$" },
+			func(name string) bool { return strings.HasSuffix(name, "ie") }},
+		{"Exclude", func(opts *NameSearchOpts) { opts.Exclude = []string{"james", "JOHN"} },
+			func(name string) bool { return name != "James" && name != "John" }},
+		{"Include", func(opts *NameSearchOpts) { opts.Include = []string{"james", "Mary", "Nonexistent"} },
+			func(name string) bool { return name == "James" || name == "Mary" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := baseSearch
+			tt.opts(&opts)
+			results, _, err := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+			if err != nil {
+				t.Fatalf("nameSearch() error = %v", err)
+			}
+			if len(results) == 0 {
+				t.Errorf("No results when results expected. %v", opts)
+			}
+			for _, r := range results {
+				if !tt.check(r.Name) {
+					t.Errorf("Unexpected result %v", r.Name)
+				}
+			}
+		})
+	}
+	DB = nil
+}
+
+func Test_nameSearchFilteredPaging(t *testing.T) {
+	for _, sort := range []string{CommonSort, RandomSort} {
+		opts := NameSearchOpts{
+			Count:        10,
+			Seed:         3384983,
+			Dictionary:   "usa_census",
+			Gender:       Gender('F'),
+			Sort:         sort,
+			MinSyllables: 3,
+			Database:     "sqlite://file::memory:?cache=shared",
+		}
+		first, offset, _ := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+		opts.Count = 5
+		opts.Offset = int(offset)
+		second, _, _ := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+		opts.Count = 15
+		opts.Offset = 0
+		all, _, _ := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+		if len(first) != 10 || len(second) != 5 || len(all) != 15 {
+			t.Fatalf("Unexpected number of results. %v %v %v", len(first), len(second), len(all))
+		}
+		for i, r := range append(first, second...) {
+			if r.Name != all[i].Name {
+				t.Errorf("%v paging with filters is producing bad results. %v != %v", sort, r.Name, all[i].Name)
+			}
+		}
+	}
+	DB = nil
+}
+
+func Test_nameSearchUnisex(t *testing.T) {
+	opts := NameSearchOpts{
+		Count:        500,
+		Dictionary:   "usa_census",
+		Gender:       Unisex,
+		UnisexWithin: 10,
+		Database:     "sqlite://file::memory:?cache=shared",
+	}
+	results, _, err := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+	if err != nil {
+		t.Fatalf("nameSearch() error = %v", err)
+	}
+	names := map[string]bool{}
+	for _, r := range results {
+		names[r.Name] = true
+		var row precalculatedNumerology
+		DB.Table("usa_census").Where("name = ?", r.Name).First(&row)
+		if row.UnisexRatio < 0.9 || row.MaleCount == 0 || row.FemaleCount == 0 {
+			t.Errorf("%v is not unisex. %v %v", r.Name, row.MaleCount, row.FemaleCount)
+		}
+	}
+	if !names["Kelly"] || names["Mary"] {
+		t.Errorf("Unexpected unisex names. Kelly = %v, Mary = %v", names["Kelly"], names["Mary"])
+	}
+	DB = nil
+}
+
+func Test_nameSearchRankThresholds(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    NameSearchOpts
+		minRank int64
+		maxRank int64
+	}{
+		{"CommonRank", NameSearchOpts{Count: 500, Sort: CommonSort, CommonRank: 100}, 1, 100},
+		{"UncommonRank", NameSearchOpts{Count: 50, Sort: UncommonSort, UncommonRank: 15000}, 15000, 0},
+		{"DefaultUncommonRank", NameSearchOpts{Count: 50, Sort: UncommonSort}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Dictionary = "usa_census"
+			opts.Database = "sqlite://file::memory:?cache=shared"
+			search, err := prepareNameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+			if err != nil {
+				t.Fatalf("prepareNameSearch() error = %v", err)
+			}
+			minRank := tt.minRank
+			if minRank == 0 {
+				minRank = search.tableSize/4 + 1
+			}
+			names, _, err := search.fetch(0, opts.Count)
+			if err != nil {
+				t.Fatalf("fetch() error = %v", err)
+			}
+			if len(names) == 0 {
+				t.Fatalf("No results when results expected.")
+			}
+			for _, r := range names {
+				if r.PopularityRank < minRank || (tt.maxRank > 0 && r.PopularityRank > tt.maxRank) {
+					t.Errorf("%v has rank %v outside of %v-%v", r.Name, r.PopularityRank, minRank, tt.maxRank)
+				}
+			}
+			if tt.maxRank > 0 && int64(len(names)) != tt.maxRank {
+				t.Errorf("Expected %v results, got %v", tt.maxRank, len(names))
+			}
+		})
+	}
+	DB = nil
+}
+
+func Test_nameSearchSoundsLike(t *testing.T) {
+	opts := NameSearchOpts{
+		Count:      100,
+		Dictionary: "usa_census",
+		Gender:     Gender('F'),
+		Sort:       "phonetic",
+		SoundsLike: "Katherine",
+		Database:   "sqlite://file::memory:?cache=shared",
+	}
+	results, _, err := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+	if err != nil {
+		t.Fatalf("nameSearch() error = %v", err)
+	}
+	if len(results) == 0 || results[0].Name != "Katherine" {
+		t.Fatalf("Expected Katherine to be the closest result. %v", results)
+	}
+	found := map[string]bool{}
+	lastSound, lastSpelling := 0, 0
+	for _, r := range results {
+		found[r.Name] = true
+		sound, spelling := phoneticDistance("Katherine", r.Name)
+		if sound < lastSound || (sound == lastSound && spelling < lastSpelling) {
+			t.Errorf("Results are not sorted by phonetic distance. %v", r.Name)
+		}
+		lastSound, lastSpelling = sound, spelling
+	}
+	for _, name := range []string{"Catherine", "Kathryn"} {
+		if !found[name] {
+			t.Errorf("Expected %v in results.", name)
+		}
+	}
+
+	// Sounds like combines with the numerological criteria and pages through the sorted names.
+	opts.Count = 3
+	opts.Full = []int{3, 5, 7}
+	first, offset, _ := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+	opts.Offset = int(offset)
+	second, _, _ := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+	for _, r := range append(first, second...) {
+		if !inIntSlice(r.Full().Value, opts.Full) {
+			t.Errorf("Unexpected result. %v %v", r.Name, r.Full().Value)
+		}
+	}
+	if offset != 3 || len(second) == 0 || first[0].Name == second[0].Name {
+		t.Errorf("Phonetic sort paging is producing bad results. %v %v %v", offset, first, second)
+	}
+
+	// The pages share the sorted names of the search, which depend on the name that they sound like.
+	search, err := prepareNameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer forgetDictionary("usa_census")
+	if _, ok := rankedSearches.get(search.rankedKey()); !ok {
+		t.Errorf("Phonetic sort did not cache the sorted names.")
+	}
+	other := *search
+	other.opts.SoundsLike = "Kathryn"
+	if _, ok := rankedSearches.get(other.rankedKey()); ok {
+		t.Errorf("Phonetic sort shares the sorted names of a different name.")
+	}
+	DB = nil
+}
+
+func Test_nameSearchScoreSort(t *testing.T) {
+	opts := NameSearchOpts{
+		Count:      40,
+		Dictionary: "usa_census",
+		Gender:     Gender('M'),
+		Sort:       "score",
+		Consonants: []int{-9},
+		Weights: ScoreWeights{
+			Full:       map[int]float64{1: 2, 8: 2, 11: 1},
+			Consonants: map[int]float64{4: -3},
+		},
+		Database: "sqlite://file::memory:?cache=shared",
+	}
+	results, offset, err := nameSearch("John ? Doe", Pythagorean, []int{11, 22, 33}, true, opts)
+	if err != nil {
+		t.Fatalf("nameSearch() error = %v", err)
+	}
+	if len(results) != 40 || offset != 40 {
+		t.Fatalf("Unexpected results. %v %v", len(results), offset)
+	}
+	if results[0].Score().Total != 2 {
+		t.Errorf("Expected the best result to have a score of 2. %v", results[0].Score())
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score().Total > results[i-1].Score().Total {
+			t.Errorf("Results are not sorted by score. %v %v", results[i-1].Score(), results[i].Score())
+		}
+	}
+	// Names with the same score are sorted by popularity.
+	common, _, _ := nameSearch("John ? Doe", Pythagorean, []int{11, 22, 33}, true, NameSearchOpts{
+		Count:      5,
+		Dictionary: "usa_census",
+		Gender:     Gender('M'),
+		Sort:       "common",
+		Full:       []int{1, 8},
+		Consonants: []int{-9, -4},
+		Database:   "sqlite://file::memory:?cache=shared",
+	})
+	for i, r := range common {
+		if r.Name != results[i].Name {
+			t.Errorf("Tied scores are not sorted by popularity. %v != %v", r.Name, results[i].Name)
+		}
+	}
+	DB = nil
+}
+
+func Test_rankedSearchCache(t *testing.T) {
+	opts := NameSearchOpts{
+		Count:      10,
+		Dictionary: "usa_census",
+		Sort:       ScoreSort,
+		Weights:    ScoreWeights{Full: map[int]float64{3: 1}},
+		Database:   "memory://",
+	}
+	search, err := prepareNameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer forgetDictionary("usa_census")
+	first, _, err := search.fetchRanked(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rankedSearches.get(search.rankedKey()); !ok {
+		t.Fatalf("fetchRanked() did not cache the sorted names")
+	}
+
+	// The next page comes from the cache instead of the dictionary.
+	cached := []precalculatedNumerology{{Name: "Cached"}, {Name: "Names"}}
+	rankedSearches.put(search.rankedKey(), "usa_census", cached)
+	if got, _, _ := search.fetchRanked(1, 10); len(got) != 1 || got[0].Name != "Names" {
+		t.Errorf("fetchRanked() = %v, want the cached names", got)
+	}
+	// The page does not depend on the offset or the count of the search.
+	page := *search
+	page.opts.Offset, page.opts.Count = 10, 20
+	if page.rankedKey() != search.rankedKey() {
+		t.Errorf("rankedKey() changes with the offset and the count")
+	}
+	page.opts.Weights = ScoreWeights{Full: map[int]float64{4: 1}}
+	if page.rankedKey() == search.rankedKey() {
+		t.Errorf("rankedKey() does not change with the weights")
+	}
+
+	forgetDictionary("USA_Census")
+	if got, _, _ := search.fetchRanked(0, 10); !reflect.DeepEqual(got, first) {
+		t.Errorf("fetchRanked() after forgetDictionary() = %v, want %v", got, first)
+	}
+
+	// The least recently used search is dropped when the cache is full.
+	for i := 0; i < rankedSearchCacheSize; i++ {
+		rankedSearches.put(fmt.Sprint(i), "ranked_test", nil)
+	}
+	defer rankedSearches.forget("ranked_test")
+	if _, ok := rankedSearches.get(search.rankedKey()); ok {
+		t.Errorf("rankedSearches kept more than %v searches", rankedSearchCacheSize)
+	}
+	rankedSearches.get("0")
+	rankedSearches.put("new", "ranked_test", nil)
+	if _, ok := rankedSearches.get("0"); !ok {
+		t.Errorf("rankedSearches dropped a search that was just used")
+	}
+	if _, ok := rankedSearches.get("1"); ok {
+		t.Errorf("rankedSearches kept the least recently used search")
+	}
+}
+
+func Test_nameSearchUnrankedPaging(t *testing.T) {
+	// Names that were inserted by hand have no ranks, and paging does not stop at them.
+	rows := precalculateNames(namePopularity{{"Mary", 0, 300, 0}, {"Marie", 0, 200, 0}, {"Mari", 0, 100, 0}})
+	for i := range rows {
+		rows[i].Id = int64(i + 1)
+		rows[i].PopularityRank, rows[i].MaleRank, rows[i].FemaleRank = 0, 0, 0
+	}
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	if err := connectToDatabase(dsn); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DB.Migrator().DropTable("unranked_test")
+		forgetDictionary("unranked_test")
+		DB = nil
+	}()
+	if err := setupDatabaseTable(DB, "unranked_test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := insertNames("unranked_test", rows, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []NameSearchOpts{
+		{Sort: RandomSort, Seed: 42},
+		{Sort: ScoreSort, Weights: ScoreWeights{Full: map[int]float64{1: 1}}},
+		{Sort: PhoneticSort, SoundsLike: "Mary"},
+	} {
+		t.Run(opts.Sort, func(t *testing.T) {
+			opts.Count, opts.Dictionary, opts.Database = 1, "unranked_test", dsn
+			var names []string
+			for page := 0; page < len(rows); page++ {
+				results, offset, err := nameSearch("?", Pythagorean, []int{11, 22, 33}, true, opts)
+				if err != nil {
+					t.Fatalf("nameSearch() error = %v", err)
+				}
+				for _, r := range results {
+					names = append(names, r.Name)
+				}
+				if offset == 0 {
+					break
+				}
+				opts.Offset = int(offset)
+			}
+			if len(names) != len(rows) {
+				t.Errorf("nameSearch() pages = %v, want every name", names)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"math/rand"
+	"strings"
+)
+
+// columnLookup limits a column to a set of values. An empty set of values can never be satisfied.
+type columnLookup struct {
+	Column string
+	Values []int
+}
+
+// countCondition compares a count column against another count column plus an adjustment. When Right is
+// empty the column is compared against just the adjustment. ex. p1 <= p5 + 2 or c3 > -1
+type countCondition struct {
+	Left       string
+	Comparator string
+	Right      string
+	Adjustment int
+}
+
+// conditionGroup is satisfied when any one of its conditions is satisfied.
+type conditionGroup []countCondition
+
+// nameQuery describes which names of a dictionary a search is looking for. It is independent of how the
+// dictionary is stored so that every nameStore answers the same search in the same way.
+type nameQuery struct {
+	// NumberSystem is the key of a number system that was added by RegisterNumberSystem. Its columns are in the
+	// side table of the dictionary. It is empty for Pythagorean and Chaldean.
+	NumberSystem string
+	// Like is a lowercase LIKE pattern that the name has to match.
+	Like string
+	// Gender is "M" or "F" for names that have been used for that gender, or "U" for names with a UnisexRatio
+	// of at least UnisexRatio.
+	Gender      string
+	UnisexRatio float64
+	// RankColumn is the rank that the names are ordered by. Searches for a gender use the rank of that gender.
+	RankColumn string
+	// CommonRank is the worst rank that common sort returns. Zero means there is no limit. UncommonRank is the
+	// best rank that uncommon sort returns.
+	CommonRank   int64
+	UncommonRank int64
+	// Trend is TrendingNames or ClassicNames to limit the names by how their popularity has changed.
+	Trend string
+	// Origins are the lowercase origins that the name can have.
+	Origins []string
+	// MinLength and MaxLength are in characters. Zero means no limit.
+	MinLength int
+	MaxLength int
+	// FirstLetters are the lowercase letters that the name can start with.
+	FirstLetters []string
+	// Exclude and Include are lowercase names.
+	Exclude []string
+	Include []string
+	// Pattern is a regular expression. It is only set when the store supports it.
+	Pattern    string
+	SoundsLike *phoneticKeys
+	Lookups    []columnLookup
+	Conditions []conditionGroup
+}
+
+// nameStore is a place that dictionaries of precalculated names can be searched. Every store has to return
+// the same names in the same order for the same query.
+type nameStore interface {
+	// count returns the number of rows in the dictionary.
+	count(dictionary string) int64
+	// largestValue returns the largest unreduced Full value of any name in the dictionary.
+	largestValue(dictionary string) int
+	// supportsPattern reports whether the store can match names against a regular expression itself.
+	supportsPattern() bool
+	// find fetches up to limit names that match the query using the given sort. Offset is a rank for common
+	// and uncommon sorts and a row position for random sort. A limit of 0 fetches every matching name in
+	// order of popularity and ignores the sort and offset.
+	find(dictionary string, q nameQuery, sort string, offset int, seed int64, limit int) ([]precalculatedNumerology, error)
+	// explain describes how find would be carried out.
+	explain(dictionary string, q nameQuery, sort string, offset int, seed int64, limit int) string
+	// metadata returns the metadata of the dictionary and whether it has any.
+	metadata(dictionary string) (dictionaryMetadata, bool)
+	// missingColumns returns the columns of precalculatedNumerology that the dictionary does not have.
+	missingColumns(dictionary string) []string
+}
+
+// openNameStore returns the store that the DSN refers to. SQL databases are connected to if there is no
+// connection yet. The embedded dictionary is used when there is neither a DSN nor a connection.
+func openNameStore(dsn string) (nameStore, error) {
+	if isMemoryDSN(dsn) {
+		return memoryStore{memoryDictionaries}, nil
+	}
+	if usesEmbeddedDictionary(dsn) {
+		return openEmbeddedDictionary()
+	}
+	if DB == nil {
+		if err := connectToDatabase(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return gormStore{DB}, nil
+}
+
+// rank returns the value of a rank column of the row.
+func (r precalculatedNumerology) rank(column string) int64 {
+	switch column {
+	case "male_rank":
+		return r.MaleRank
+	case "female_rank":
+		return r.FemaleRank
+	}
+	return r.PopularityRank
+}
+
+// randomSortMultiplier is the number that the ids are multiplied by to shuffle them for random sort.
+func randomSortMultiplier(seed int64) float64 {
+	randSource := rand.NewSource(seed)
+	newRand := rand.New(randSource)
+	return newRand.Float64()
+}
+
+// gormStore searches dictionaries that are tables of a SQL database.
+type gormStore struct {
+	db *gorm.DB
+}
+
+func (s gormStore) count(dictionary string) (count int64) {
+	s.db.Table(dictionary).Count(&count)
+	return count
+}
+
+func (s gormStore) largestValue(dictionary string) int {
+	// Get the largest name value from the database and cache it so we don't have to look it up again.
+	var largestNameValueInDb int
+	var ok bool
+	if largestNameValueInDb, ok = largestNameValueInTable[dictionary]; !ok {
+		if err := s.db.Table(dictionary).Select("max(max(pythagorean_full), max(chaldean_full)) as largest").Find(&largestNameValueInDb).Error; err != nil {
+			largestNameValueInDb = 100
+		}
+		// The registered number systems can have larger values.
+		var largestRegistered int
+		if len(registeredNumberSystems()) > 0 && s.db.Migrator().HasTable(numberSystemsTable(dictionary)) {
+			s.db.Table(numberSystemsTable(dictionary)).Select("coalesce(max(full_value), 0)").Find(&largestRegistered)
+		}
+		if largestRegistered > largestNameValueInDb {
+			largestNameValueInDb = largestRegistered
+		}
+		largestNameValueInTable[dictionary] = largestNameValueInDb
+	}
+	return largestNameValueInDb
+}
+
+func (s gormStore) metadata(dictionary string) (dictionaryMetadata, bool) {
+	return readMetadata(s.db, dictionary)
+}
+
+func (s gormStore) supportsPattern() bool {
+	switch s.db.Dialector.Name() {
+	case "postgres", "mysql":
+		return true
+	}
+	return false
+}
+
+func (s gormStore) find(dictionary string, q nameQuery, sort string, offset int, seed int64, limit int) (names []precalculatedNumerology, err error) {
+	if err := s.query(dictionary, q, sort, offset, seed, limit).Find(&names).Error; err != nil {
+		return nil, fmt.Errorf("unable to search database table %v: %v", dictionary, err)
+	}
+	return names, nil
+}
+
+func (s gormStore) missingColumns(dictionary string) (missing []string) {
+	stmt := &gorm.Statement{DB: s.db}
+	if err := stmt.Parse(&precalculatedNumerology{}); err != nil {
+		return nil
+	}
+	migrator := s.db.Table(dictionary).Migrator()
+	for _, column := range stmt.Schema.DBNames {
+		if !migrator.HasColumn(&precalculatedNumerology{}, column) {
+			missing = append(missing, column)
+		}
+	}
+	return missing
+}
+
+func (s gormStore) explain(dictionary string, q nameQuery, sort string, offset int, seed int64, limit int) string {
+	// DryRun builds the statement without sending it to the database.
+	var names []precalculatedNumerology
+	stmt := s.query(dictionary, q, sort, offset, seed, limit).Session(&gorm.Session{DryRun: true}).Find(&names).Statement
+	return s.db.Dialector.Explain(stmt.SQL.String(), stmt.Vars...)
+}
+
+// query builds the SQL query for find.
+func (s gormStore) query(dictionary string, q nameQuery, sort string, offset int, seed int64, limit int) *gorm.DB {
+	query := s.db.Table(dictionary).Select("id, name, origin, meaning, variants, nicknames, " + q.RankColumn)
+	if q.NumberSystem != "" {
+		query = joinNumberSystem(query, dictionary, numberSystemColumnsOfKey(q.NumberSystem))
+	}
+
+	// If there are letters around the ? then we need to do a LIKE search.
+	if q.Like != "" {
+		query = query.Where("LOWER(name) LIKE ?", q.Like)
+	}
+	switch q.Gender {
+	case "M":
+		query = query.Where("male_rank > 0")
+	case "F":
+		query = query.Where("female_rank > 0")
+	case "U":
+		query = query.Where("unisex_ratio >= ?", q.UnisexRatio)
+	}
+	if len(q.Origins) > 0 {
+		query = query.Where("LOWER(origin) IN ?", q.Origins)
+	}
+	switch q.Trend {
+	case TrendingNames:
+		query = query.Where("trend >= ?", trendingThreshold)
+	case ClassicNames:
+		query = query.Where("trend >= ? AND trend <= ?", -classicThreshold, classicThreshold)
+	}
+	s.addNameConstraints(query, q)
+	if q.SoundsLike != nil {
+		metaphones := q.SoundsLike.metaphones()
+		query = query.Where(s.db.Where("soundex = ?", q.SoundsLike.Soundex).
+			Or("metaphone_primary IN ?", metaphones).
+			Or("metaphone_alternate IN ?", metaphones))
+	}
+	for _, group := range q.Conditions {
+		s.addConditionGroup(query, group)
+	}
+	for _, l := range q.Lookups {
+		if len(l.Values) > 0 {
+			query = query.Where(l.Column+" IN ?", l.Values)
+		} else {
+			query = query.Where("FALSE")
+		}
+	}
+
+	if limit == 0 {
+		return query.Order(q.RankColumn + " asc")
+	}
+	query = query.Limit(limit)
+	s.addSort(query, q, sort, offset, seed)
+	return query
+}
+
+// addNameConstraints adds the textual constraints on the name to the query.
+func (s gormStore) addNameConstraints(query *gorm.DB, q nameQuery) {
+	// MySQL LENGTH counts bytes instead of characters.
+	lengthFunc := "LENGTH"
+	if s.db.Dialector.Name() == "mysql" {
+		lengthFunc = "CHAR_LENGTH"
+	}
+	if q.MinLength > 0 {
+		query = query.Where(lengthFunc+"(name) >= ?", q.MinLength)
+	}
+	if q.MaxLength > 0 {
+		query = query.Where(lengthFunc+"(name) <= ?", q.MaxLength)
+	}
+	if len(q.FirstLetters) > 0 {
+		query = query.Where("LOWER(SUBSTR(name, 1, 1)) IN ?", q.FirstLetters)
+	}
+	if len(q.Exclude) > 0 {
+		query = query.Where("LOWER(name) NOT IN ?", q.Exclude)
+	}
+	if len(q.Include) > 0 {
+		query = query.Where("LOWER(name) IN ?", q.Include)
+	}
+	if q.Pattern != "" {
+		switch s.db.Dialector.Name() {
+		case "postgres":
+			query = query.Where("name ~ ?", q.Pattern)
+		case "mysql":
+			query = query.Where("name REGEXP ?", q.Pattern)
+		}
+	}
+}
+
+// addConditionGroup adds a group of count conditions to the query. Conditions in the group are joined by OR.
+func (s gormStore) addConditionGroup(query *gorm.DB, group conditionGroup) {
+	switch len(group) {
+	case 0:
+		return
+	case 1:
+		sql, args := group[0].sql()
+		query = query.Where(sql, args...)
+		return
+	}
+	var where *gorm.DB
+	for _, c := range group {
+		sql, args := c.sql()
+		if where == nil { // If our Where clause hasn't been initialized yet then do it now.
+			where = s.db.Where(sql, args...)
+		} else { // if already initialized then add an Or clause
+			where.Or(sql, args...)
+		}
+	}
+	// Add grouped query to main query
+	query = query.Where(where)
+}
+
+// sql renders the condition as a SQL expression.
+func (c countCondition) sql() (string, []interface{}) {
+	if c.Right == "" {
+		return fmt.Sprintf("%v %v ?", c.Left, c.Comparator), []interface{}{c.Adjustment}
+	}
+	// If modifier is '0' then no reason for unnecessary addition in query.
+	if c.Adjustment == 0 {
+		return fmt.Sprintf("%v %v %v", c.Left, c.Comparator, c.Right), nil
+	}
+	return fmt.Sprintf("%v %v %v + ?", c.Left, c.Comparator, c.Right), []interface{}{c.Adjustment}
+}
+
+// Order the results based on the selected option
+func (s gormStore) addSort(query *gorm.DB, q nameQuery, sort string, offset int, seed int64) {
+	switch strings.ToLower(sort) {
+	case UncommonSort:
+		// Skip down a ways so the names are less common.
+		skip := q.UncommonRank
+		if int64(offset) > skip {
+			skip = int64(offset)
+		}
+		query = query.Where(q.RankColumn+" >= ?", skip).Order(q.RankColumn + " asc")
+	case RandomSort:
+		// Randomizing order with seed. https://stackoverflow.com/a/24511461
+		query = query.Order(fmt.Sprintf("(substr(id * %v, length(id) + 2))", randomSortMultiplier(seed)))
+		// Offset for random uses regular offset function of db because there is no easier way to skip results.
+		query = query.Offset(offset)
+	default: // Default is a catchall for "common"
+		query = query.Where(q.RankColumn+" >= ?", offset).Order(q.RankColumn + " asc")
+		if q.CommonRank > 0 {
+			query = query.Where(q.RankColumn+" <= ?", q.CommonRank)
+		}
+	}
+}
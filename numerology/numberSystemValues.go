@@ -0,0 +1,170 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+)
+
+// precalculatedSystemNumerology holds the precalculated values of a name for a number system that was added by
+// RegisterNumberSystem. There is one row for each name and registered number system in the side table of the
+// dictionary. Counts N1 to N9 are the same as P1 to P9 of Pythagorean.
+type precalculatedSystemNumerology struct {
+	Id              int64  `gorm:"primaryKey;autoIncrement:false"`
+	NumberSystem    string `gorm:"primaryKey;type:varchar(64)"`
+	FullValue       uint8
+	VowelsValue     uint8
+	ConsonantsValue uint8
+	N1              uint8
+	N2              uint8
+	N3              uint8
+	N4              uint8
+	N5              uint8
+	N6              uint8
+	N7              uint8
+	N8              uint8
+	N9              uint8
+}
+
+// numberSystemsTable is the name of the side table that holds the values of the registered number systems for the
+// names of a dictionary.
+func numberSystemsTable(table string) string {
+	return table + "_number_systems"
+}
+
+// precalculateNumberSystems calculates the values of a name for each of the registered number systems. The values
+// are keyed by the key of the number system. An error is returned if the name has characters that cannot be
+// calculated.
+func precalculateNumberSystems(name string) (map[string]precalculatedSystemNumerology, error) {
+	registered := registeredNumberSystems()
+	if len(registered) == 0 {
+		return nil, nil
+	}
+	values := map[string]precalculatedSystemNumerology{}
+	for _, ns := range registered {
+		calculated := Name(name, ns, []int{}, false)
+		if len(calculated.UnknownCharacters()) > 0 {
+			return nil, fmt.Errorf("unacceptable characters in name: %v", name)
+		}
+		key := numberSystemKey(ns)
+		value := precalculatedSystemNumerology{
+			NumberSystem:    key,
+			FullValue:       uint8(calculated.Full().Breakdown[0].ReduceSteps[0]),
+			VowelsValue:     uint8(calculated.Vowels().Breakdown[0].ReduceSteps[0]),
+			ConsonantsValue: uint8(calculated.Consonants().Breakdown[0].ReduceSteps[0]),
+		}
+		for n, count := range calculated.Counts() {
+			value.setCount(int(n), uint8(count))
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// counts returns the pointers to N1 to N9 so that they can be looked up by their number.
+func (v *precalculatedSystemNumerology) counts() []*uint8 {
+	return []*uint8{&v.N1, &v.N2, &v.N3, &v.N4, &v.N5, &v.N6, &v.N7, &v.N8, &v.N9}
+}
+
+func (v *precalculatedSystemNumerology) setCount(n int, count uint8) {
+	if n >= 1 && n <= 9 {
+		*v.counts()[n-1] = count
+	}
+}
+
+func (v precalculatedSystemNumerology) count(n int) uint8 {
+	if n < 1 || n > 9 {
+		return 0
+	}
+	return *v.counts()[n-1]
+}
+
+// writeNumberSystems saves the values of the registered number systems for the rows, replacing the values that
+// they already have. The side table is created the first time that it is needed.
+func writeNumberSystems(db *gorm.DB, table string, rows []precalculatedNumerology) error {
+	if len(registeredNumberSystems()) == 0 {
+		return nil
+	}
+	if err := db.Table(numberSystemsTable(table)).AutoMigrate(&precalculatedSystemNumerology{}); err != nil {
+		return err
+	}
+	values := []precalculatedSystemNumerology{}
+	for _, r := range rows {
+		for _, v := range r.NumberSystems {
+			v.Id = r.Id
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return db.Table(numberSystemsTable(table)).Clauses(clause.OnConflict{UpdateAll: true}).
+		CreateInBatches(values, insertBatchSize).Error
+}
+
+// deleteNumberSystems deletes the values of the registered number systems of the rows.
+func deleteNumberSystems(db *gorm.DB, table string, rows []precalculatedNumerology) error {
+	if len(rows) == 0 || !db.Migrator().HasTable(numberSystemsTable(table)) {
+		return nil
+	}
+	ids := []int64{}
+	for _, r := range rows {
+		ids = append(ids, r.Id)
+	}
+	return db.Table(numberSystemsTable(table)).Where("id IN ?", ids).Delete(&precalculatedSystemNumerology{}).Error
+}
+
+// loadNumberSystems reads the values of the registered number systems of the rows from the side table.
+func loadNumberSystems(db *gorm.DB, table string, rows []precalculatedNumerology) error {
+	if len(registeredNumberSystems()) == 0 || !db.Migrator().HasTable(numberSystemsTable(table)) {
+		return nil
+	}
+	var values []precalculatedSystemNumerology
+	if err := db.Table(numberSystemsTable(table)).Find(&values).Error; err != nil {
+		return err
+	}
+	byId := map[int64]map[string]precalculatedSystemNumerology{}
+	for _, v := range values {
+		if byId[v.Id] == nil {
+			byId[v.Id] = map[string]precalculatedSystemNumerology{}
+		}
+		id := v.Id
+		// The id is only kept in the side table so that the values match the ones that are calculated.
+		v.Id = 0
+		byId[id][v.NumberSystem] = v
+	}
+	for i := range rows {
+		rows[i].NumberSystems = byId[rows[i].Id]
+	}
+	return nil
+}
+
+// joinNumberSystem joins the values of a registered number system to a query of a dictionary. The columns of the
+// side table are renamed to the namespaced columns of the number system so that the conditions of a nameQuery
+// refer to them the same way for every store.
+func joinNumberSystem(query *gorm.DB, dictionary string, columns numberSystemColumns) *gorm.DB {
+	selected := []string{
+		"id AS " + columns.Key + "_id",
+		"full_value AS " + columns.Full,
+		"vowels_value AS " + columns.Vowels,
+		"consonants_value AS " + columns.Consonants,
+	}
+	for n := 1; n <= 9; n++ {
+		selected = append(selected, fmt.Sprintf("n%d AS %v", n, columns.Count(n)))
+	}
+	return query.Joins(fmt.Sprintf("JOIN (SELECT %v FROM %v WHERE number_system = ?) %v ON %v.%v_id = %v.id",
+		strings.Join(selected, ", "), numberSystemsTable(dictionary), columns.Key, columns.Key, columns.Key, dictionary),
+		columns.Key)
+}
@@ -13,6 +13,9 @@ package numerology
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
 	"strings"
 )
 
@@ -84,3 +87,99 @@ var Pythagorean = NumberSystem{
 	},
 	ValidNumbers: []int{1, 2, 3, 4, 5, 6, 7, 8, 9},
 }
+
+// numberSystems are the number systems whose values are precalculated for every name in the dictionaries. The
+// values of Pythagorean and Chaldean are columns of the dictionary table. The values of the number systems that
+// are added by RegisterNumberSystem are kept in a side table. See numberSystemsTable.
+var numberSystems = []NumberSystem{Pythagorean, Chaldean}
+
+// RegisterNumberSystem adds a number system that names can be calculated and searched with. The name of the
+// number system is how it is found by GetNumberSystem and JSON, so it has to be different from the names of the
+// other number systems. Every letter from a to z needs a value from 0 to 9, and the valid numbers have to be from
+// 1 to 9.
+//
+// The values of the number system are precalculated for every name in the dictionaries that are created after it
+// is registered. Registering it changes the metadata of the dictionaries, so the dictionaries that were created
+// before have to be brought up to date by MigrateDatabase before they can be searched. Number systems should be
+// registered when the program starts, before any dictionary is created or searched.
+func RegisterNumberSystem(ns NumberSystem) error {
+	key := numberSystemKey(ns)
+	if key == "" {
+		return errors.New("number system needs a name")
+	}
+	for _, registered := range numberSystems {
+		if numberSystemKey(registered) == key {
+			return fmt.Errorf("number system %v is already registered", ns.Name)
+		}
+	}
+	for letter := 'a'; letter <= 'z'; letter++ {
+		if v, ok := ns.NumberMapping[letter]; !ok || v < 0 || v > 9 {
+			return fmt.Errorf("number system %v needs a value from 0 to 9 for the letter %c", ns.Name, letter)
+		}
+	}
+	if len(ns.ValidNumbers) == 0 {
+		return fmt.Errorf("number system %v has no valid numbers", ns.Name)
+	}
+	for _, n := range ns.ValidNumbers {
+		if n < 1 || n > 9 {
+			return fmt.Errorf("number system %v has a valid number %v that is not from 1 to 9", ns.Name, n)
+		}
+	}
+	numberSystems = append(numberSystems, ns)
+	// Dictionaries have to be checked again because the number systems that they need have changed.
+	compatibleDictionaries.clear()
+	return nil
+}
+
+// numberSystemKey is the lowercase name of a number system with anything other than letters and numbers replaced
+// by an underscore so that it can be used in column names. ex. "Neo-Pythagorean" is neo_pythagorean
+func numberSystemKey(ns NumberSystem) string {
+	return strings.Trim(nonColumnCharacters.ReplaceAllString(strings.ToLower(strings.TrimSpace(ns.Name)), "_"), "_")
+}
+
+var nonColumnCharacters = regexp.MustCompile("[^a-z0-9]+")
+
+// isBuiltInNumberSystem reports whether the values of a number system are columns of the dictionary table.
+func isBuiltInNumberSystem(ns NumberSystem) bool {
+	return numberSystemColumnsOfKey(numberSystemKey(ns)).Key == ""
+}
+
+// registeredNumberSystems are the number systems that were added by RegisterNumberSystem.
+func registeredNumberSystems() (registered []NumberSystem) {
+	for _, ns := range numberSystems {
+		if !isBuiltInNumberSystem(ns) {
+			registered = append(registered, ns)
+		}
+	}
+	return registered
+}
+
+// numberSystemColumns are the names of the columns that hold the precalculated values of a number system. The
+// columns of Pythagorean are pythagorean_full, pythagorean_vowels, pythagorean_consonants and p1 to p9. The
+// columns of a registered number system are namespaced by its key. ex. hebrew_full, hebrew_n1
+type numberSystemColumns struct {
+	// Key is the key of a registered number system. It is empty for Pythagorean and Chaldean.
+	Key        string
+	Full       string
+	Vowels     string
+	Consonants string
+	countName  string
+}
+
+// Count is the name of the column with the count of the number n.
+func (c numberSystemColumns) Count(n int) string {
+	return fmt.Sprintf("%v%d", c.countName, n)
+}
+
+// columnsOf returns the names of the columns of a number system.
+func columnsOf(ns NumberSystem) numberSystemColumns {
+	return numberSystemColumnsOfKey(numberSystemKey(ns))
+}
+
+// numberSystemColumnsOfKey returns the names of the columns of the number system with the key.
+func numberSystemColumnsOfKey(key string) numberSystemColumns {
+	if key == "pythagorean" || key == "chaldean" {
+		return numberSystemColumns{"", key + "_full", key + "_vowels", key + "_consonants", key[:1]}
+	}
+	return numberSystemColumns{key, key + "_full", key + "_vowels", key + "_consonants", key + "_n"}
+}
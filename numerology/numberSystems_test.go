@@ -12,7 +12,11 @@
 package numerology
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -81,3 +85,150 @@ func TestNumberSystem_UnmarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+// phoenician is a number system that starts with P like Pythagorean so that its columns have to be kept apart.
+var phoenician = NumberSystem{
+	Name: "Phoenician",
+	NumberMapping: map[int32]int{
+		'a': 9, 'j': 9, 's': 9,
+		'b': 8, 'k': 8, 't': 8,
+		'c': 7, 'l': 7, 'u': 7,
+		'd': 6, 'm': 6, 'v': 6,
+		'e': 5, 'n': 5, 'w': 5,
+		'f': 4, 'o': 4, 'x': 4,
+		'g': 3, 'p': 3, 'y': 3,
+		'h': 2, 'q': 2, 'z': 2,
+		'i': 1, 'r': 1,
+		' ': 0, '.': 0, '-': 0,
+	},
+	ValidNumbers: []int{1, 2, 3, 4, 5, 6, 7, 8, 9},
+}
+
+// registerForTest registers a number system until the test is finished.
+func registerForTest(t *testing.T, ns NumberSystem) {
+	registered := numberSystems
+	if err := RegisterNumberSystem(ns); err != nil {
+		t.Fatalf("RegisterNumberSystem() error = %v", err)
+	}
+	t.Cleanup(func() {
+		numberSystems = registered
+		compatibleDictionaries.clear()
+	})
+}
+
+func TestRegisterNumberSystem(t *testing.T) {
+	missingLetter := NumberSystem{Name: "Missing", NumberMapping: map[int32]int{'a': 1}, ValidNumbers: []int{1}}
+	invalidNumber := phoenician
+	invalidNumber.Name = "Invalid"
+	invalidNumber.ValidNumbers = []int{1, 11}
+	tests := []struct {
+		name string
+		ns   NumberSystem
+	}{
+		{"NoName", NumberSystem{NumberMapping: phoenician.NumberMapping, ValidNumbers: []int{1}}},
+		{"AlreadyRegistered", NumberSystem{Name: " PYTHAGOREAN", NumberMapping: Pythagorean.NumberMapping, ValidNumbers: []int{1}}},
+		{"MissingLetter", missingLetter},
+		{"InvalidNumber", invalidNumber},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := RegisterNumberSystem(tt.ns); err == nil {
+				t.Errorf("RegisterNumberSystem() did not return an error")
+			}
+		})
+	}
+
+	fingerprint := numberSystemsFingerprint()
+	registerForTest(t, phoenician)
+	if ns, err := GetNumberSystem("phoenician"); err != nil || ns.Name != "Phoenician" {
+		t.Errorf("GetNumberSystem() = %v, %v", ns.Name, err)
+	}
+	if numberSystemsFingerprint() == fingerprint {
+		t.Errorf("numberSystemsFingerprint() did not change")
+	}
+	if got := columnsOf(phoenician); got.Full != "phoenician_full" || got.Count(1) != "phoenician_n1" {
+		t.Errorf("columnsOf() = %v", got)
+	}
+	if got := columnsOf(Pythagorean); got.Full != "pythagorean_full" || got.Count(1) != "p1" {
+		t.Errorf("columnsOf() = %v", got)
+	}
+}
+
+func Test_registeredNumberSystemSearch(t *testing.T) {
+	registerForTest(t, phoenician)
+	baseDir := t.TempDir()
+	dir := filepath.Join(baseDir, "phoenician_test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, b, _, _ := runtime.Caller(0)
+	source, err := os.ReadFile(filepath.Join(filepath.Dir(b), "..", "test_names", "usa_census", "test_names.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitN(string(source), "\n", 501)
+	if err := os.WriteFile(filepath.Join(dir, "names.csv"), []byte(strings.Join(lines[:500], "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	defer func() {
+		DB.Migrator().DropTable("phoenician_test", numberSystemsTable("phoenician_test"))
+		DB.Where("dictionary = ?", "phoenician_test").Delete(&importLog{})
+		DB.Where("dictionary = ?", "phoenician_test").Delete(&dictionaryMetadata{})
+		delete(memoryDictionaries, "phoenician_test")
+		delete(largestNameValueInTable, "phoenician_test")
+		DB = nil
+	}()
+	if err := CreateDatabase(dsn, baseDir); err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	if err := CreateDatabase("memory://", baseDir); err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts NameSearchOpts
+	}{
+		{"Full", NameSearchOpts{Count: 20, Full: []int{3, 7}}},
+		{"Vowels", NameSearchOpts{Count: 20, Vowels: []int{5}, Consonants: []int{-2}}},
+		{"HiddenPassions", NameSearchOpts{Count: 20, HiddenPassions: []int{9}}},
+		{"KarmicLessons", NameSearchOpts{Count: 20, KarmicLessons: []int{-9, 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := map[string][]string{}
+			for _, database := range []string{dsn, "memory://"} {
+				opts := tt.opts
+				opts.Database = database
+				opts.Dictionary = "phoenician_test"
+				results, _, err := nameSearch("Jane ? Doe", phoenician, []int{11, 22, 33}, true, opts)
+				if err != nil {
+					t.Fatalf("nameSearch() error = %v", err)
+				}
+				if len(results) == 0 {
+					t.Fatalf("nameSearch() %v returned no names", database)
+				}
+				for _, r := range results {
+					found[database] = append(found[database], r.Name)
+					if len(tt.opts.Full) > 0 && !inIntSlice(r.Full().Value, tt.opts.Full) {
+						t.Errorf("nameSearch() %v has full %v", r.Name, r.Full().Value)
+					}
+					if len(tt.opts.Vowels) > 0 && (r.Vowels().Value != 5 || r.Consonants().Value == 2) {
+						t.Errorf("nameSearch() %v has vowels %v and consonants %v", r.Name, r.Vowels().Value, r.Consonants().Value)
+					}
+					if len(tt.opts.HiddenPassions) > 0 && !inIntSlice(9, r.HiddenPassions().Numbers) {
+						t.Errorf("nameSearch() %v has hidden passions %v", r.Name, r.HiddenPassions().Numbers)
+					}
+					if lessons := r.KarmicLessons().Numbers; len(tt.opts.KarmicLessons) > 0 && (inIntSlice(9, lessons) || !inIntSlice(2, lessons)) {
+						t.Errorf("nameSearch() %v has karmic lessons %v", r.Name, lessons)
+					}
+				}
+			}
+			if !reflect.DeepEqual(found[dsn], found["memory://"]) {
+				t.Errorf("memory results %v do not match SQL %v", found["memory://"], found[dsn])
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -47,17 +48,50 @@ type NameSearchOpts struct {
 	Seed int64 `json:"seed,omitempty"`
 
 	// Database is the DSN string that connects to the database that has the precalculated numerological names.
+	// When it is empty and there is no connection in DB, the EmbeddedDictionary is searched.
 	Database string `json:"db,omitempty"`
 
-	// Dictionary is the name of the database table to search.
+	// Dictionary is the name of the database table to search. It defaults to EmbeddedDictionary when the
+	// embedded dictionary is searched.
 	Dictionary string `json:"dictionary,omitempty"`
 
-	// Gender is a filter to limit search results to names that are generally (m)ale, (f)emale, or (b)oth.
+	// Dictionaries is used instead of Dictionary to search several database tables as one pool of names. Names
+	// that are in more than one dictionary are only returned once, from the dictionary where they are the most
+	// popular. Popularity is compared relative to the size of each dictionary.
+	Dictionaries []string `json:"dictionaries,omitempty"`
+
+	// Gender is a filter to limit search results to names that are used for (m)ale, (f)emale, (b)oth, or (u)nisex.
+	// Male and female include every name that has been used for that gender. Unisex only includes names that are
+	// used about as often for both genders. See UnisexWithin.
 	Gender Gender `json:"gender,omitempty"`
 
-	// Sort is the method of sorting used when return names. The options are "common", "uncommon", and "random".
+	// UnisexWithin is how close, as a percent, the counts of both genders have to be for a name to be unisex. The
+	// count of the less common gender has to be within UnisexWithin percent of the count of the more common gender.
+	// Defaults to 25.
+	UnisexWithin int `json:"unisex_within,omitempty"`
+
+	// Sort is the method of sorting used when return names. The options are "common", "uncommon", "random",
+	// "phonetic", and "score". Phonetic sorts the names by how closely they sound like SoundsLike. Score sorts
+	// the names from highest to lowest score using Weights, with more common names first when scores are equal.
+	// Phonetic and score sorts fetch and sort every name that matches before the first page is returned. The
+	// sorted names of the most recent searches are kept in memory so that the pages after it are not sorted again.
 	Sort string `json:"sort,omitempty"`
 
+	// CommonRank and UncommonRank are the popularity ranks that divide common and uncommon names. Common sort only
+	// returns names ranked CommonRank or better, with zero meaning every name. Uncommon sort only returns names
+	// ranked UncommonRank or worse, with zero meaning names past the most popular quarter of the dictionary.
+	CommonRank   int `json:"common_rank,omitempty"`
+	UncommonRank int `json:"uncommon_rank,omitempty"`
+
+	// Trend is a filter to limit search results to names whose popularity is "trending", meaning that their share
+	// of the names has at least doubled, or "classic", meaning that their share has stayed within about 40 percent.
+	// The older and more recent halves of the source files of the dictionary are compared.
+	Trend string `json:"trend,omitempty"`
+
+	// Origins is a filter to limit search results to names with one of the origins, like "Hebrew" or "Irish". The
+	// origins come from the name metadata files of the dictionary. Case is ignored.
+	Origins []string `json:"origins,omitempty"`
+
 	// Full, Vowels, and Consonants are  the numerological numbers to look for while searching. They are calculated
 	// using all the letters of the name, just the vowels, and just the consonants, respectively. There are various
 	// common numerological names for these values; destiny, express, heart's desire, soul's urge, personality, etc.
@@ -98,6 +132,37 @@ type NameSearchOpts struct {
 	// number will exist in the final grouping. Negative numbers are exclusive, they exclude that number from
 	// existing in the final grouping.
 	KarmicLessons []int `json:"karmic_lessons,omitempty"`
+
+	// MinLength and MaxLength limit the number of characters in the names that are returned. Zero means that
+	// there is no limit.
+	MinLength int `json:"min_length,omitempty"`
+	MaxLength int `json:"max_length,omitempty"`
+
+	// FirstLetters limits the results to names that start with one of the given letters. ex. "jkm"
+	FirstLetters string `json:"first_letters,omitempty"`
+
+	// MinSyllables and MaxSyllables limit the number of syllables in the names that are returned. Zero means
+	// that there is no limit. Syllables are estimated by counting groups of vowels so the count is only an
+	// approximation, especially for names with a "Y".
+	MinSyllables int `json:"min_syllables,omitempty"`
+	MaxSyllables int `json:"max_syllables,omitempty"`
+
+	// Pattern is a regular expression that the names must match. It is sent to the database when the database
+	// supports regular expressions (PostgreSQL and MySQL). Otherwise, it is checked after the names are fetched.
+	Pattern string `json:"pattern,omitempty"`
+
+	// Exclude is a list of names that should never be returned; like names that are already used in the family.
+	// Include is a list of names that the results are limited to. Both are case-insensitive.
+	Exclude []string `json:"exclude,omitempty"`
+	Include []string `json:"include,omitempty"`
+
+	// SoundsLike limits the results to names that sound like the given name. Names sound alike when they share
+	// a Soundex or Double Metaphone encoding. ex. Katherine, Catherine, Kathryn
+	SoundsLike string `json:"sounds_like,omitempty"`
+
+	// Weights are soft preferences that are used to score the names. Unlike Full, Vowels, Consonants, etc. they
+	// do not exclude any names. See ScoreWeights.
+	Weights ScoreWeights `json:"weights,omitempty"`
 }
 
 // DateOpts contains the options that are required in order to get a numerological value from a date.
@@ -106,6 +171,11 @@ type DateOpts struct {
 	// considered to have special numerological value. The most commonly considered master numbers
 	// are repeating digits. ex 11, 22, 33, 44, 55
 	MasterNumbers []int `json:"master_numbers"`
+
+	// Calendar is the code of the calendar that the year, month and day of dates are converted to before they are
+	// calculated. ex. "hebrew", "islamic", "persian", "chinese". The Gregorian calendar is used when it is empty.
+	// DateNumerology.CheckSearch reports a code that does not have a calendar.
+	Calendar string `json:"calendar,omitempty"`
 }
 
 // DateSearchOpts contains the search options specific to searching for numerological dates.
@@ -113,20 +183,56 @@ type DateSearchOpts struct {
 	// Count is the number of results to return.
 	Count int `json:"count,omitempty"`
 
-	// Offset is used to page through the search results. Its main use is with an web service.
+	// Offset is used to page through the search results. Its main use is with an web service. It is the number
+	// of days that have already been searched, in the order that they are searched.
 	Offset int `json:"offset,omitempty"`
 
-	// Match is a slice of ints that are the numerological values that we want to find.
+	// Match is a slice of ints that are the numerological values that we want to find. Positive numbers are
+	// inclusive and negative numbers are exclusive, just like the numbers of a name search. Every step of the
+	// reduction is checked, so -11 excludes a date that reduces 29, 11, 2 even when 2 is also in Match.
 	Match []int `json:"match,omitempty"`
 
 	// MonthsForward is the number of months in which to search. Usually one is trying to find a date within
-	// some reasonable time frame; like for a wedding or event.
+	// some reasonable time frame; like for a wedding or event. It is only used when End is zero.
 	MonthsForward int `json:"months_forward,omitempty"`
 
+	// Start and End are the first and last dates to search. Both are inclusive and only the year, month and day
+	// are used. A zero Start is the date of the DateNumerology, and a zero End is MonthsForward after Start.
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+
+	// Windows are several ranges of dates to search in one query, instead of Start and End. Windows that
+	// overlap are merged so that no date is returned twice.
+	Windows []DateRange `json:"windows,omitempty"`
+
+	// Backward searches from the last date to the first. ex. to find the past dates that had a Universal Day.
+	Backward bool `json:"backward,omitempty"`
+
 	// Dow, or Days of the Week, limits the results to ones that fall on certain days.
 	// 0=Sun 1=Mon 2=Tue 3=Wed 4=Thu 5=Fri 6=Sat
 	Dow []int `json:"dow,omitempty"`
 
+	// Days limits the results to certain days of the month (1-31). The days are in the Calendar of the DateOpts.
+	Days []int `json:"days,omitempty"`
+
+	// Months limits the results to certain months (1=Jan to 12=Dec). The months are in the Calendar of the
+	// DateOpts. ex. 7 is Tishri in the Hebrew calendar
+	Months []int `json:"months,omitempty"`
+
+	// Blackouts are dates that are never returned; like the days that a venue is already booked. A range with a
+	// zero End is a single day.
+	Blackouts []DateRange `json:"blackouts,omitempty"`
+
+	// IncludeHolidays limits the results to the holidays of the holiday calendars with these codes, and
+	// ExcludeHolidays skips them. ex. []string{"us"} See HolidayCalendarCodes for the calendars that are
+	// available. A code without a calendar has no holidays. DateNumerology.CheckSearch reports such codes.
+	IncludeHolidays []string `json:"include_holidays,omitempty"`
+	ExcludeHolidays []string `json:"exclude_holidays,omitempty"`
+
+	// AvoidKarmicDebt excludes dates that have a Karmic Debt number (13, 14, 16 or 19) in any step of the
+	// reduction of the date or of its year, month or day.
+	AvoidKarmicDebt bool `json:"avoid_karmic_debt,omitempty"`
+
 	// LifePath is generally used with ones date of birth. If false, then Master Numbers are ignored
 	// for the calculation.
 	LifePath bool `json:"life_path"`
@@ -137,6 +243,7 @@ const (
 	Male   = Gender('M')
 	Female = Gender('F')
 	Both   = Gender('B')
+	Unisex = Gender('U')
 )
 
 // Gender is custom type that is needed so we can customize the Marshaling and Unmarshaling.
@@ -148,12 +255,12 @@ func (g Gender) MarshalJSON() ([]byte, error) {
 }
 
 // UnmarshalJSON will come in as a string, but we want to convert it to a rune. Only accepted options are (M)ale,
-// (F)emale, and (B)oth. If the option is not one of these then it defaults to (B)oth.
+// (F)emale, (B)oth, and (U)nisex.
 func (g *Gender) UnmarshalJSON(value []byte) error {
 	v := strings.Trim(string(value), `"`)
 	r := unicode.ToUpper(rune(v[0]))
 	genderTest := Gender(r)
-	if genderTest == Male || genderTest == Female || genderTest == Both {
+	if genderTest == Male || genderTest == Female || genderTest == Both || genderTest == Unisex {
 		*g = genderTest
 	} else {
 		return fmt.Errorf("unknown gender code: '%v'", v)
@@ -55,6 +55,7 @@ func TestGender_UnmarshalJSON(t *testing.T) {
 		{"Unmarshal Male", 'M', args{[]byte("\"Male\"")}, false},
 		{"Unmarshal Female", 'F', args{[]byte("\"Female\"")}, false},
 		{"Unmarshal Both", 'B', args{[]byte("\"B\"")}, false},
+		{"Unmarshal Unisex", 'U', args{[]byte("\"Unisex\"")}, false},
 		{"Unmarshal Unknown", 'B', args{[]byte("\"Something\"")}, true},
 	}
 	for _, tt := range tests {
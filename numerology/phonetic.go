@@ -0,0 +1,63 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"github.com/antzucaro/matchr"
+	"strings"
+)
+
+// phoneticKeys holds the phonetic encodings of a name. Names that sound alike share the same keys even when
+// they are spelled differently. (Katherine, Catherine, Kathryn)
+type phoneticKeys struct {
+	Soundex            string
+	MetaphonePrimary   string
+	MetaphoneAlternate string
+}
+
+// getPhoneticKeys calculates the Soundex and Double Metaphone encodings of a name. The name is transliterated
+// to ASCII first because the encodings are only defined for the Latin alphabet.
+func getPhoneticKeys(name string) phoneticKeys {
+	ascii := ToAscii(name)
+	primary, alternate := matchr.DoubleMetaphone(ascii)
+	return phoneticKeys{
+		Soundex:            matchr.Soundex(ascii),
+		MetaphonePrimary:   primary,
+		MetaphoneAlternate: alternate,
+	}
+}
+
+// metaphones returns the distinct Double Metaphone keys.
+func (k phoneticKeys) metaphones() []string {
+	if k.MetaphoneAlternate == "" || k.MetaphoneAlternate == k.MetaphonePrimary {
+		return []string{k.MetaphonePrimary}
+	}
+	return []string{k.MetaphonePrimary, k.MetaphoneAlternate}
+}
+
+// phoneticDistance measures how differently two names sound. The closest pair of Double Metaphone keys is
+// compared first. Ties are broken by how differently the names are spelled so that, of two names that sound
+// the same, the one spelled more like the reference comes first.
+func phoneticDistance(reference string, name string) (soundDistance int, spellingDistance int) {
+	refKeys := getPhoneticKeys(reference)
+	nameKeys := getPhoneticKeys(name)
+	soundDistance = -1
+	for _, r := range refKeys.metaphones() {
+		for _, n := range nameKeys.metaphones() {
+			if d := matchr.Levenshtein(r, n); soundDistance < 0 || d < soundDistance {
+				soundDistance = d
+			}
+		}
+	}
+	spellingDistance = matchr.Levenshtein(strings.ToLower(ToAscii(reference)), strings.ToLower(ToAscii(name)))
+	return soundDistance, spellingDistance
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"testing"
+)
+
+func Test_getPhoneticKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		want phoneticKeys
+	}{
+		{"Katherine", phoneticKeys{"K365", "K0RN", "KTRN"}},
+		{"Catherine", phoneticKeys{"C365", "K0RN", "KTRN"}},
+		{"Kathryn", phoneticKeys{"K365", "K0RN", "KTRN"}},
+		{"Zoë", phoneticKeys{"Z000", "S", "S"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPhoneticKeys(tt.name); got != tt.want {
+				t.Errorf("getPhoneticKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_phoneticDistance(t *testing.T) {
+	tests := []struct {
+		name         string
+		reference    string
+		wantSound    int
+		wantSpelling int
+	}{
+		{"Kathryn", "Katherine", 0, 3},
+		{"Katherine", "Katherine", 0, 0},
+		{"Karen", "Katherine", 1, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sound, spelling := phoneticDistance(tt.reference, tt.name)
+			if sound != tt.wantSound || spelling != tt.wantSpelling {
+				t.Errorf("phoneticDistance() = %v, %v, want %v, %v", sound, spelling, tt.wantSound, tt.wantSpelling)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+// ScoreWeights contains soft preferences for numerological numbers. Each map holds the weight that is given to
+// a name when the number shows up in that calculation. Positive weights are preferred numbers and negative
+// weights are numbers to avoid. ex. Full: {1: 2, 8: 2, 11: 1}, Consonants: {4: -3}
+type ScoreWeights struct {
+	// Full, Vowels, and Consonants weights are applied to every number in the reduce steps of the calculation.
+	// This way master numbers and Karmic Debt numbers (13, 14, 16, 19) can be weighted, too.
+	Full       map[int]float64 `json:"full,omitempty"`
+	Vowels     map[int]float64 `json:"vowels,omitempty"`
+	Consonants map[int]float64 `json:"consonants,omitempty"`
+
+	// HiddenPassions weights are applied to each Hidden Passion number of the name.
+	HiddenPassions map[int]float64 `json:"hidden_passions,omitempty"`
+
+	// KarmicLessons weights are applied to each Karmic Lesson number of the name.
+	KarmicLessons map[int]float64 `json:"karmic_lessons,omitempty"`
+}
+
+// ScoreBreakdown contains the score of a name and how much each calculation contributed to it.
+type ScoreBreakdown struct {
+	Total          float64 `json:"total"`
+	Full           float64 `json:"full"`
+	Vowels         float64 `json:"vowels"`
+	Consonants     float64 `json:"consonants"`
+	HiddenPassions float64 `json:"hidden_passions"`
+	KarmicLessons  float64 `json:"karmic_lessons"`
+}
+
+// scoreReduceSteps sums the weights of the distinct numbers in the reduce steps.
+func scoreReduceSteps(reduceSteps []int, weights map[int]float64) (score float64) {
+	seen := map[int]bool{}
+	for _, n := range reduceSteps {
+		if !seen[n] {
+			score += weights[n]
+			seen[n] = true
+		}
+	}
+	return score
+}
+
+// scoreNumbers sums the weights of the numbers.
+func scoreNumbers(numbers []int, weights map[int]float64) (score float64) {
+	for _, n := range numbers {
+		score += weights[n]
+	}
+	return score
+}
+
+// scoreName calculates how well a name matches the weighted preferences. Calculations without weights are
+// skipped so that they do not need to be calculated.
+func scoreName(n NameNumerology, weights ScoreWeights) (score ScoreBreakdown) {
+	if len(weights.Full) > 0 {
+		score.Full = scoreReduceSteps(n.Full().ReduceSteps, weights.Full)
+	}
+	if len(weights.Vowels) > 0 {
+		score.Vowels = scoreReduceSteps(n.Vowels().ReduceSteps, weights.Vowels)
+	}
+	if len(weights.Consonants) > 0 {
+		score.Consonants = scoreReduceSteps(n.Consonants().ReduceSteps, weights.Consonants)
+	}
+	if len(weights.HiddenPassions) > 0 {
+		score.HiddenPassions = scoreNumbers(n.HiddenPassions().Numbers, weights.HiddenPassions)
+	}
+	if len(weights.KarmicLessons) > 0 {
+		score.KarmicLessons = scoreNumbers(n.KarmicLessons().Numbers, weights.KarmicLessons)
+	}
+	score.Total = score.Full + score.Vowels + score.Consonants + score.HiddenPassions + score.KarmicLessons
+	return score
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_scoreName(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights ScoreWeights
+		want    ScoreBreakdown
+	}{
+		// Full: 35 = 8, Consonants: 18 = 9, Hidden Passions: [5 6], Karmic Lessons: [2 3 7 9]
+		{"John Doe", ScoreWeights{
+			Full:           map[int]float64{1: 2, 19: -1, 8: 5},
+			Consonants:     map[int]float64{4: -3},
+			HiddenPassions: map[int]float64{5: 0.5},
+			KarmicLessons:  map[int]float64{3: 1, 9: 1},
+		}, ScoreBreakdown{Total: 7.5, Full: 5, HiddenPassions: 0.5, KarmicLessons: 2}},
+		{"John Doe", ScoreWeights{}, ScoreBreakdown{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := Name(tt.name, Pythagorean, []int{11, 22, 33}, false)
+			if got := scoreName(n, tt.weights); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scoreName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameNumerology_Score(t *testing.T) {
+	n := Name("John Doe", Pythagorean, []int{11, 22, 33}, false)
+	if got := n.Score(); !reflect.DeepEqual(got, ScoreBreakdown{}) {
+		t.Errorf("Score() = %v, want %v", got, ScoreBreakdown{})
+	}
+	n.NameSearchOpts = &NameSearchOpts{Weights: ScoreWeights{Full: map[int]float64{8: 2}}}
+	if got := n.Score(); !reflect.DeepEqual(got, ScoreBreakdown{Total: 2, Full: 2}) {
+		t.Errorf("Score() = %v, want %v", got, ScoreBreakdown{Total: 2, Full: 2})
+	}
+}
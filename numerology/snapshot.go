@@ -0,0 +1,557 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// snapshotManifest describes an exported dictionary so that an import can make sure that it has every row
+// exactly as it was exported. Metadata is nil when the dictionary was created before metadata was recorded.
+type snapshotManifest struct {
+	Dictionary string              `gorm:"primaryKey;type:varchar(255)" json:"dictionary"`
+	Rows       int64               `json:"rows"`
+	Checksum   string              `gorm:"type:varchar(64)" json:"checksum"`
+	ExportedAt time.Time           `json:"exportedAt"`
+	Metadata   *dictionaryMetadata `gorm:"-" json:"metadata,omitempty"`
+}
+
+func (snapshotManifest) TableName() string {
+	return "dictionary_snapshots"
+}
+
+// Formats that dictionaries can be exported to. The format of a file comes from its extension.
+const (
+	csvSnapshot        = "csv"
+	jsonLinesSnapshot  = "jsonl"
+	sqliteSnapshot     = "sqlite"
+	manifestFileSuffix = ".manifest.json"
+)
+
+// snapshotFormat finds the format of a snapshot file from its extension.
+func snapshotFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return csvSnapshot, nil
+	case ".jsonl", ".ndjson":
+		return jsonLinesSnapshot, nil
+	case ".sqlite", ".sqlite3", ".db":
+		return sqliteSnapshot, nil
+	}
+	return "", fmt.Errorf("unknown snapshot format of %v. Use .csv, .jsonl or .sqlite", filepath.Base(path))
+}
+
+// snapshotColumn is a column of a dictionary and the field of precalculatedNumerology that holds it.
+type snapshotColumn struct {
+	name  string
+	field int
+}
+
+// snapshotColumns are the columns of a dictionary in the order that they are exported. They are found from the
+// fields of precalculatedNumerology so that new columns are exported without any changes here.
+func snapshotColumns() (columns []snapshotColumn) {
+	naming := schema.NamingStrategy{}
+	t := reflect.TypeOf(precalculatedNumerology{})
+	for i := 0; i < t.NumField(); i++ {
+		// The values of the registered number systems are not columns of the dictionary.
+		if t.Field(i).Tag.Get("gorm") == "-" {
+			continue
+		}
+		columns = append(columns, snapshotColumn{naming.ColumnName("", t.Field(i).Name), i})
+	}
+	return columns
+}
+
+// formatSnapshotValue converts the value of a field to text. Floats use the fewest digits that convert back to
+// exactly the same value.
+func formatSnapshotValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint8:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	}
+	return v.String()
+}
+
+// parseSnapshotValue sets a field from its text.
+func parseSnapshotValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint8:
+		u, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		v.SetString(s)
+	}
+	return nil
+}
+
+// snapshotRecord converts a row into the text of each of its columns.
+func snapshotRecord(r precalculatedNumerology, columns []snapshotColumn) []string {
+	v := reflect.ValueOf(r)
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = formatSnapshotValue(v.Field(c.field))
+	}
+	return record
+}
+
+// rowsChecksum is the SHA-256 checksum of every value of every row. The rows are in order of id so that the
+// checksum does not depend on the order that a database returns them in.
+func rowsChecksum(rows []precalculatedNumerology) string {
+	sorted := append([]precalculatedNumerology{}, rows...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+	columns := snapshotColumns()
+	hash := sha256.New()
+	for _, r := range sorted {
+		hash.Write([]byte(strings.Join(snapshotRecord(r, columns), "\x1f") + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// verifySnapshot makes sure that the rows are the ones that the manifest describes.
+func verifySnapshot(manifest snapshotManifest, rows []precalculatedNumerology) error {
+	if int64(len(rows)) != manifest.Rows {
+		return fmt.Errorf("dictionary %v has %v names instead of %v", manifest.Dictionary, len(rows), manifest.Rows)
+	}
+	if checksum := rowsChecksum(rows); checksum != manifest.Checksum {
+		return fmt.Errorf("dictionary %v has checksum %v instead of %v", manifest.Dictionary, checksum, manifest.Checksum)
+	}
+	return nil
+}
+
+// readDictionary reads every row of a dictionary along with its metadata.
+func readDictionary(dsn string, dictionary string) (rows []precalculatedNumerology, metadata *dictionaryMetadata, err error) {
+	if isMemoryDSN(dsn) {
+		d, ok := memoryDictionaries[dictionary]
+		if !ok {
+			return nil, nil, fmt.Errorf("dictionary %v is not loaded in memory", dictionary)
+		}
+		m, _ := memoryStore{memoryDictionaries}.metadata(dictionary)
+		return d.rows(), &m, nil
+	}
+	if err := connectToDatabase(dsn); err != nil {
+		return nil, nil, errors.New("unable to connect to database. " + err.Error())
+	}
+	if !DB.Migrator().HasTable(dictionary) {
+		return nil, nil, fmt.Errorf("database table %v does not exist", dictionary)
+	}
+	if err := DB.Table(dictionary).Order("id asc").Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	if m, ok := readMetadata(DB, dictionary); ok {
+		metadata = &m
+	}
+	return rows, metadata, nil
+}
+
+// ExportDictionary exports a dictionary, along with its metadata, to a file that can be imported into another
+// database by ImportDictionary. The format comes from the extension of the path.
+//
+//	.csv      A CSV file with a header, and the manifest in a .manifest.json file next to it.
+//	.jsonl    A JSON Lines file with the manifest on its first line.
+//	.sqlite   A SQLite database with the table, its indexes, its metadata and the manifest.
+//
+// The manifest has the number of names and the checksum of their values. The file is read again once it is
+// written to make sure that it matches. The file must not already exist.
+func ExportDictionary(dsn string, dictionary string, path string) error {
+	format, err := snapshotFormat(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("snapshot file %v already exists", path)
+	}
+	table := strings.ToLower(dictionary)
+	rows, metadata, err := readDictionary(dsn, table)
+	if err != nil {
+		return err
+	}
+	manifest := snapshotManifest{
+		Dictionary: table,
+		Rows:       int64(len(rows)),
+		Checksum:   rowsChecksum(rows),
+		ExportedAt: time.Now().UTC(),
+		Metadata:   metadata,
+	}
+
+	logTo(nil, LogInfo, "Exporting dictionary", Fields{"dictionary": table, "names": len(rows), "path": path})
+	switch format {
+	case csvSnapshot:
+		err = writeCSVSnapshot(path, manifest, rows)
+	case jsonLinesSnapshot:
+		err = writeJSONLinesSnapshot(path, manifest, rows)
+	case sqliteSnapshot:
+		err = writeSQLiteSnapshot(path, manifest, rows)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to export dictionary %v: %v", table, err)
+	}
+	if _, _, err := readSnapshot(path); err != nil {
+		return fmt.Errorf("export of dictionary %v could not be verified: %v", table, err)
+	}
+	return nil
+}
+
+// ImportDictionary imports a dictionary that was exported by ExportDictionary. The table is named after the
+// exported dictionary and has to be empty. It is created with the same indexes as CreateDatabase creates. The
+// names keep their ids, so random sort is the same in both databases.
+//
+// The file is checked against its manifest before anything is imported, and the table is checked against it
+// once the names are inserted. A dictionary that was exported by an older version of the library has to be
+// brought up to date by MigrateDatabase before it can be searched.
+func ImportDictionary(dsn string, path string) error {
+	manifest, rows, err := readSnapshot(path)
+	if err != nil {
+		return err
+	}
+	table := manifest.Dictionary
+	// The values of the registered number systems are not exported, so they are calculated again.
+	for i := range rows {
+		rows[i].NumberSystems, _ = precalculateNumberSystems(rows[i].Name)
+	}
+	if isMemoryDSN(dsn) {
+		if d, ok := memoryDictionaries[table]; ok && len(d.ids) > 0 {
+			return fmt.Errorf("dictionary %v is already loaded in memory", table)
+		}
+		memoryDictionaries[table] = newMemoryDictionary(rows)
+		forgetDictionary(table)
+		return nil
+	}
+
+	logTo(nil, LogInfo, "Connecting to database", nil)
+	if err := connectToDatabase(dsn); err != nil {
+		return errors.New("unable to connect to database. " + err.Error())
+	}
+	if err := DB.AutoMigrate(&importLog{}, &dictionaryMetadata{}); err != nil {
+		return err
+	}
+	if err := setupDatabaseTable(DB, table); err != nil {
+		return err
+	}
+	var count int64
+	DB.Table(table).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("database table %v is not empty", table)
+	}
+
+	logTo(nil, LogInfo, "Importing dictionary", Fields{"dictionary": table, "names": len(rows)})
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if len(rows) > 0 {
+			if err := tx.Table(table).CreateInBatches(rows, insertBatchSize).Error; err != nil {
+				return fmt.Errorf("unable to insert records into database table %v: %v", table, err)
+			}
+			if err := writeNumberSystems(tx, table, rows); err != nil {
+				return err
+			}
+		}
+		// PostgreSQL does not move the sequence of the ids past ids that are inserted, so the next name that
+		// UpdateDatabase adds would get an id that is already used.
+		if tx.Dialector.Name() == "postgres" && len(rows) > 0 {
+			if err := tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%v', 'id'), (SELECT max(id) FROM %v))", table, table)).Error; err != nil {
+				return err
+			}
+		}
+		if manifest.Metadata == nil {
+			return nil
+		}
+		metadata := *manifest.Metadata
+		metadata.Dictionary = table
+		return writeMetadata(tx, metadata)
+	}); err != nil {
+		return err
+	}
+
+	var imported []precalculatedNumerology
+	if err := DB.Table(table).Find(&imported).Error; err != nil {
+		return err
+	}
+	if err := verifySnapshot(manifest, imported); err != nil {
+		return fmt.Errorf("import of %v could not be verified: %v", path, err)
+	}
+	forgetDictionary(table)
+	return nil
+}
+
+// readSnapshot reads the manifest and the rows of a snapshot file and makes sure that they match.
+func readSnapshot(path string) (manifest snapshotManifest, rows []precalculatedNumerology, err error) {
+	format, err := snapshotFormat(path)
+	if err != nil {
+		return manifest, nil, err
+	}
+	switch format {
+	case csvSnapshot:
+		manifest, rows, err = readCSVSnapshot(path)
+	case jsonLinesSnapshot:
+		manifest, rows, err = readJSONLinesSnapshot(path)
+	case sqliteSnapshot:
+		manifest, rows, err = readSQLiteSnapshot(path)
+	}
+	if err != nil {
+		return manifest, nil, fmt.Errorf("unable to read snapshot %v: %v", path, err)
+	}
+	if manifest.Dictionary == "" {
+		return manifest, nil, fmt.Errorf("snapshot %v does not name its dictionary", path)
+	}
+	if err := verifySnapshot(manifest, rows); err != nil {
+		return manifest, nil, fmt.Errorf("snapshot %v is damaged: %v", path, err)
+	}
+	return manifest, rows, nil
+}
+
+// writeCSVSnapshot writes the rows to a CSV file with a header of the column names. The manifest is written to
+// a file next to it.
+func writeCSVSnapshot(path string, manifest snapshotManifest, rows []precalculatedNumerology) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	columns := snapshotColumns()
+	writer := csv.NewWriter(file)
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := writer.Write(snapshotRecord(r, columns)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+manifestFileSuffix, data, 0644)
+}
+
+// readCSVSnapshot reads a CSV file that was written by writeCSVSnapshot. The columns are found by the header, so
+// a column that the file does not have is left empty.
+func readCSVSnapshot(path string) (manifest snapshotManifest, rows []precalculatedNumerology, err error) {
+	data, err := os.ReadFile(path + manifestFileSuffix)
+	if err != nil {
+		return manifest, nil, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return manifest, nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return manifest, nil, err
+	}
+	fields := map[string]int{}
+	for _, c := range snapshotColumns() {
+		fields[c.name] = c.field
+	}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, nil, err
+		}
+		var r precalculatedNumerology
+		v := reflect.ValueOf(&r).Elem()
+		for i, column := range header {
+			field, ok := fields[column]
+			if !ok || i >= len(record) {
+				continue
+			}
+			if err := parseSnapshotValue(v.Field(field), record[i]); err != nil {
+				return manifest, nil, fmt.Errorf("row %v: %v is not valid: %v", row, column, err)
+			}
+		}
+		rows = append(rows, r)
+	}
+	return manifest, rows, nil
+}
+
+// writeJSONLinesSnapshot writes the manifest as {"snapshot": ...} on the first line followed by a JSON object
+// for each row.
+func writeJSONLinesSnapshot(path string, manifest snapshotManifest, rows []precalculatedNumerology) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	if err := encoder.Encode(map[string]snapshotManifest{"snapshot": manifest}); err != nil {
+		return err
+	}
+	columns := snapshotColumns()
+	for _, r := range rows {
+		v := reflect.ValueOf(r)
+		object := map[string]interface{}{}
+		for _, c := range columns {
+			object[c.name] = v.Field(c.field).Interface()
+		}
+		if err := encoder.Encode(object); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// readJSONLinesSnapshot reads a JSON Lines file that was written by writeJSONLinesSnapshot.
+func readJSONLinesSnapshot(path string) (manifest snapshotManifest, rows []precalculatedNumerology, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return manifest, nil, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	decoder.UseNumber()
+	var first map[string]snapshotManifest
+	if err := decoder.Decode(&first); err != nil {
+		return manifest, nil, fmt.Errorf("first line is not a snapshot manifest: %v", err)
+	}
+	manifest = first["snapshot"]
+	fields := map[string]int{}
+	for _, c := range snapshotColumns() {
+		fields[c.name] = c.field
+	}
+	for row := 2; ; row++ {
+		object := map[string]interface{}{}
+		if err := decoder.Decode(&object); err == io.EOF {
+			break
+		} else if err != nil {
+			return manifest, nil, fmt.Errorf("row %v: %v", row, err)
+		}
+		var r precalculatedNumerology
+		v := reflect.ValueOf(&r).Elem()
+		for column, value := range object {
+			field, ok := fields[column]
+			if !ok {
+				continue
+			}
+			if err := parseSnapshotValue(v.Field(field), fmt.Sprint(value)); err != nil {
+				return manifest, nil, fmt.Errorf("row %v: %v is not valid: %v", row, column, err)
+			}
+		}
+		rows = append(rows, r)
+	}
+	return manifest, rows, nil
+}
+
+// openSQLiteSnapshot opens a SQLite snapshot file as its own database so that it does not replace DB.
+func openSQLiteSnapshot(path string) (*gorm.DB, func(), error) {
+	db, err := gorm.Open(openSQLite(path, sqliteDriver), &gorm.Config{Logger: dbLogger})
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}, nil
+}
+
+// writeSQLiteSnapshot writes the rows to a table with the same name and indexes as the dictionary, along with its
+// metadata and the manifest.
+func writeSQLiteSnapshot(path string, manifest snapshotManifest, rows []precalculatedNumerology) error {
+	db, closeDB, err := openSQLiteSnapshot(path)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	if err := db.AutoMigrate(&dictionaryMetadata{}, &snapshotManifest{}); err != nil {
+		return err
+	}
+	if err := setupDatabaseTable(db, manifest.Dictionary); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if len(rows) > 0 {
+			if err := tx.Table(manifest.Dictionary).CreateInBatches(rows, insertBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		if manifest.Metadata != nil {
+			if err := tx.Save(manifest.Metadata).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&manifest).Error
+	})
+}
+
+// readSQLiteSnapshot reads a SQLite file that was written by writeSQLiteSnapshot.
+func readSQLiteSnapshot(path string) (manifest snapshotManifest, rows []precalculatedNumerology, err error) {
+	if _, err := os.Stat(path); err != nil {
+		return manifest, nil, err
+	}
+	db, closeDB, err := openSQLiteSnapshot(path)
+	if err != nil {
+		return manifest, nil, err
+	}
+	defer closeDB()
+	var manifests []snapshotManifest
+	if err := db.Limit(1).Find(&manifests).Error; err != nil || len(manifests) == 0 {
+		return manifest, nil, errors.New("file has no snapshot manifest")
+	}
+	manifest = manifests[0]
+	if err := db.Table(manifest.Dictionary).Order("id asc").Find(&rows).Error; err != nil {
+		return manifest, nil, err
+	}
+	if metadata, ok := readMetadata(db, manifest.Dictionary); ok {
+		manifest.Metadata = &metadata
+	}
+	return manifest, rows, nil
+}
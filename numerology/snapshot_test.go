@@ -0,0 +1,169 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_snapshotFormat(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"names.csv", csvSnapshot, false},
+		{"names.JSONL", jsonLinesSnapshot, false},
+		{"names.ndjson", jsonLinesSnapshot, false},
+		{"names.sqlite", sqliteSnapshot, false},
+		{"names.db", sqliteSnapshot, false},
+		{"names.xml", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := snapshotFormat(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("snapshotFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("snapshotFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rowsChecksum(t *testing.T) {
+	a := precalculatedNumerology{Id: 1, Name: "Mary", Gender: "F", Trend: 0.1}
+	b := precalculatedNumerology{Id: 2, Name: "John", Gender: "M", Trend: -2.5}
+	checksum := rowsChecksum([]precalculatedNumerology{a, b})
+	if got := rowsChecksum([]precalculatedNumerology{b, a}); got != checksum {
+		t.Errorf("rowsChecksum() depends on the order of the rows")
+	}
+	changed := b
+	changed.P1 = 1
+	if got := rowsChecksum([]precalculatedNumerology{a, changed}); got == checksum {
+		t.Errorf("rowsChecksum() did not change when a value changed")
+	}
+}
+
+func TestExportImportDictionary(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := filepath.Join(baseDir, "snapshot_test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeSourceFile(t, dir, "yob2000.txt", "Mary,F,100\nJohn,M,80\nKelly,F,40\nKelly,M,30\n")
+	writeSourceFile(t, dir, "yob2020.txt", "Mary,F,90\nJohn,M,20\nZoe,F,60\n")
+	writeSourceFile(t, dir, "names.meta.csv", "name,origin,nicknames\nJohn,Hebrew,Jack|Johnny\n")
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	if err := CreateDatabase(dsn, baseDir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DB.Migrator().DropTable("snapshot_test")
+		DB.Where("dictionary = ?", "snapshot_test").Delete(&importLog{})
+		DB.Where("dictionary = ?", "snapshot_test").Delete(&dictionaryMetadata{})
+		delete(memoryDictionaries, "snapshot_test")
+		DB = nil
+	}()
+	want, wantMetadata, err := readDictionary(dsn, "snapshot_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"names.csv", "names.jsonl", "names.sqlite"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := ExportDictionary(dsn, "Snapshot_Test", path); err != nil {
+				t.Fatalf("ExportDictionary() error = %v", err)
+			}
+			if err := ExportDictionary(dsn, "snapshot_test", path); err == nil {
+				t.Errorf("ExportDictionary() overwrote an existing file")
+			}
+			if err := ImportDictionary(dsn, path); err == nil {
+				t.Errorf("ImportDictionary() imported into a table that is not empty")
+			}
+
+			DB.Migrator().DropTable("snapshot_test")
+			DB.Where("dictionary = ?", "snapshot_test").Delete(&dictionaryMetadata{})
+			if err := ImportDictionary(dsn, path); err != nil {
+				t.Fatalf("ImportDictionary() error = %v", err)
+			}
+			got, gotMetadata, err := readDictionary(dsn, "snapshot_test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ImportDictionary() rows = %v, want %v", got, want)
+			}
+			gotMetadata.UpdatedAt = wantMetadata.UpdatedAt
+			if !reflect.DeepEqual(gotMetadata, wantMetadata) {
+				t.Errorf("ImportDictionary() metadata = %v, want %v", gotMetadata, wantMetadata)
+			}
+			for _, idx := range []string{"pythagorean_full", "popularity_rank", "origin", "soundex"} {
+				if !DB.Table("snapshot_test").Migrator().HasIndex(&precalculatedNumerology{}, "snapshot_test_idx_"+idx) {
+					t.Errorf("ImportDictionary() did not create index snapshot_test_idx_%v", idx)
+				}
+			}
+
+			delete(memoryDictionaries, "snapshot_test")
+			if err := ImportDictionary("memory://", path); err != nil {
+				t.Fatalf("ImportDictionary() memory error = %v", err)
+			}
+			if got := memoryDictionaries["snapshot_test"].rows(); !reflect.DeepEqual(got, want) {
+				t.Errorf("ImportDictionary() memory rows = %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("Damaged", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "names.csv")
+		if err := ExportDictionary(dsn, "snapshot_test", path); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Replace(string(data), "Zoe", "Zoa", 1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := readSnapshot(path); err == nil || !strings.Contains(err.Error(), "checksum") {
+			t.Errorf("readSnapshot() error = %v, want a checksum error", err)
+		}
+		lines := strings.Split(string(data), "\n")
+		if err := os.WriteFile(path, []byte(strings.Join(lines[:len(lines)-2], "\n")), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := readSnapshot(path); err == nil || !strings.Contains(err.Error(), "names instead of") {
+			t.Errorf("readSnapshot() error = %v, want a row count error", err)
+		}
+	})
+
+	t.Run("Memory", func(t *testing.T) {
+		if err := CreateDatabase("memory://", baseDir); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "names.jsonl")
+		if err := ExportDictionary("memory://", "snapshot_test", path); err != nil {
+			t.Fatalf("ExportDictionary() error = %v", err)
+		}
+		if _, rows, err := readSnapshot(path); err != nil || !reflect.DeepEqual(rows, want) {
+			t.Errorf("readSnapshot() = %v %v, want %v", rows, err, want)
+		}
+	})
+}
@@ -0,0 +1,324 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spkg/bom"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sourceFilePatterns are the files of a dictionary directory that names are read from. Any of them can also be
+// compressed with gzip.
+var sourceFilePatterns = []string{"*.csv", "*.tsv", "*.jsonl", "*.ndjson", "yob*.txt"}
+
+// ssaFileName matches the files of the Social Security Administration baby names. ex. yob1987.txt
+var ssaFileName = regexp.MustCompile(`(?i)^yob(\d{4})\.txt$`)
+
+// Column headers that are recognized, and the column that they refer to.
+var sourceHeaderAliases = map[string]string{
+	"name":        "name",
+	"first_name":  "name",
+	"firstname":   "name",
+	"given_name":  "name",
+	"forename":    "name",
+	"gender":      "gender",
+	"sex":         "gender",
+	"count":       "count",
+	"popularity":  "count",
+	"number":      "count",
+	"frequency":   "count",
+	"occurrences": "count",
+	"births":      "count",
+	"year":        "year",
+	"yob":         "year",
+}
+
+// sourceRecord is a name and its count from a row of a source file. Year is 0 when it is not known.
+type sourceRecord struct {
+	Name   string
+	Gender byte
+	Count  int
+	Year   int
+}
+
+// RejectedRow is a row of a source file or name metadata file that could not be read. Row counts from 1 and is 0
+// when the whole file is rejected.
+type RejectedRow struct {
+	File   string `json:"file"`
+	Row    int    `json:"row"`
+	Text   string `json:"text,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (r *RejectedRow) Error() string {
+	return fmt.Sprintf("%v:%v: %v: %v", r.File, r.Row, r.Reason, r.Text)
+}
+
+// sourceReader reads the records of a source file one at a time. next returns io.EOF after the last record. A row
+// that cannot be read is returned as a *RejectedRow error, and reading can continue after it.
+type sourceReader interface {
+	next() (sourceRecord, error)
+	Close() error
+}
+
+// listSourceFiles finds the source files of a dictionary directory in sorted order. Name metadata files are not
+// source files even though they have the same extensions.
+func listSourceFiles(directory string) ([]string, error) {
+	matches, err := globFiles(directory, sourceFilePatterns)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, fn := range matches {
+		if !isNameMetadataFile(fn) {
+			files = append(files, fn)
+		}
+	}
+	return files, nil
+}
+
+// globFiles finds the files of a directory that match any of the patterns, either as they are or compressed
+// with gzip, in sorted order.
+func globFiles(directory string, patterns []string) ([]string, error) {
+	fileSystem := os.DirFS(directory)
+	files := []string{}
+	for _, pattern := range patterns {
+		for _, p := range []string{pattern, pattern + ".gz"} {
+			matches, err := fs.Glob(fileSystem, p)
+			if err != nil {
+				return nil, errors.New("unable to scan directory")
+			}
+			files = append(files, matches...)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// openInput opens a file for reading, decompressing it if its name ends in .gz. It returns the name of the file
+// without the .gz and the things that have to be closed when reading is done.
+func openInput(path string) (input io.Reader, fileName string, closers []io.Closer, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	closers = []io.Closer{file}
+	input = file
+	fileName = filepath.Base(path)
+	if strings.HasSuffix(strings.ToLower(fileName), ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, "", nil, err
+		}
+		closers = append([]io.Closer{gz}, closers...)
+		input = gz
+		fileName = fileName[:len(fileName)-3]
+	}
+	// bom.NewReader gets rid of UTF-8 byte order marks that can cause problems.
+	return bom.NewReader(input), fileName, closers, nil
+}
+
+// openSourceFile opens a source file with the reader for its format. The format comes from the extension of the
+// file once any .gz is removed.
+func openSourceFile(path string) (sourceReader, error) {
+	input, fileName, closers, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	year := 0
+	if m := ssaFileName.FindStringSubmatch(fileName); m != nil {
+		year, _ = strconv.Atoi(m[1])
+	}
+	base := sourceFileBase{file: filepath.Base(path), year: year, closers: closers}
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".jsonl", ".ndjson":
+		return &jsonLinesReader{sourceFileBase: base, scanner: bufio.NewScanner(input)}, nil
+	case ".tsv":
+		return newDelimitedReader(base, input, '\t'), nil
+	}
+	return newDelimitedReader(base, input, ','), nil
+}
+
+// sourceFileBase is what every sourceReader has in common.
+type sourceFileBase struct {
+	file string
+	// year is the year of every name in the file when the file name says what it is.
+	year    int
+	row     int
+	closers []io.Closer
+}
+
+func (b *sourceFileBase) Close() error {
+	for _, c := range b.closers {
+		c.Close()
+	}
+	return nil
+}
+
+func (b *sourceFileBase) reject(text string, reason string) *RejectedRow {
+	return &RejectedRow{File: b.file, Row: b.row, Text: text, Reason: reason}
+}
+
+// record checks the values of a row and converts them into a record.
+func (b *sourceFileBase) record(text string, name string, gender string, count string, year string) (sourceRecord, error) {
+	record := sourceRecord{Name: strings.TrimSpace(name), Year: b.year}
+	if record.Name == "" {
+		return record, b.reject(text, "missing name")
+	}
+	gender = strings.TrimSpace(strings.ToUpper(gender))
+	if gender == "" || (gender[0] != 'M' && gender[0] != 'F') {
+		return record, b.reject(text, "unknown gender")
+	}
+	record.Gender = gender[0]
+	c, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || c < 0 {
+		return record, b.reject(text, "count is not a number")
+	}
+	record.Count = c
+	if year = strings.TrimSpace(year); year != "" {
+		y, err := strconv.Atoi(year)
+		if err != nil {
+			return record, b.reject(text, "year is not a number")
+		}
+		record.Year = y
+	}
+	return record, nil
+}
+
+// delimitedReader reads CSV and TSV files. Without a header the columns are name, gender, count. A header row
+// maps the columns by their names, so they can be in any order and extra columns are ignored.
+type delimitedReader struct {
+	sourceFileBase
+	reader  *csv.Reader
+	columns map[string]int
+	started bool
+}
+
+func newDelimitedReader(base sourceFileBase, input io.Reader, delimiter rune) *delimitedReader {
+	reader := csv.NewReader(input)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+	return &delimitedReader{
+		sourceFileBase: base,
+		reader:         reader,
+		columns:        map[string]int{"name": 0, "gender": 1, "count": 2},
+	}
+}
+
+// headerColumns maps the columns of a row if it is a header. A header has to name the name, gender and count
+// columns.
+func headerColumns(cols []string) (map[string]int, bool) {
+	columns := map[string]int{}
+	for i, col := range cols {
+		if alias, ok := sourceHeaderAliases[strings.ToLower(strings.TrimSpace(col))]; ok {
+			if _, exists := columns[alias]; !exists {
+				columns[alias] = i
+			}
+		}
+	}
+	for _, required := range []string{"name", "gender", "count"} {
+		if _, ok := columns[required]; !ok {
+			return nil, false
+		}
+	}
+	return columns, true
+}
+
+func (r *delimitedReader) next() (sourceRecord, error) {
+	cols, err := r.reader.Read()
+	r.row++
+	if err == io.EOF {
+		return sourceRecord{}, io.EOF
+	}
+	text := strings.Join(cols, string(r.reader.Comma))
+	if _, ok := err.(*csv.ParseError); ok {
+		return sourceRecord{}, r.reject(text, err.Error())
+	}
+	if err != nil {
+		// The file cannot be read any further. ex. A truncated .gz file keeps returning unexpected EOF.
+		return sourceRecord{}, err
+	}
+	if !r.started {
+		r.started = true
+		if columns, ok := headerColumns(cols); ok {
+			r.columns = columns
+			return r.next()
+		}
+	}
+	value := func(column string) string {
+		if i, ok := r.columns[column]; ok && i < len(cols) {
+			return cols[i]
+		}
+		return ""
+	}
+	return r.record(text, value("name"), value("gender"), value("count"), value("year"))
+}
+
+// jsonLinesReader reads files with a JSON object on every line. The keys of the objects are the same as the
+// headers of a CSV file. ex. {"name": "Mary", "gender": "F", "count": 100}
+type jsonLinesReader struct {
+	sourceFileBase
+	scanner *bufio.Scanner
+}
+
+func (r *jsonLinesReader) next() (sourceRecord, error) {
+	for r.scanner.Scan() {
+		r.row++
+		text := r.scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		object := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(text), &object); err != nil {
+			return sourceRecord{}, r.reject(text, "invalid JSON")
+		}
+		values := map[string]string{}
+		for key, v := range object {
+			alias, ok := sourceHeaderAliases[strings.ToLower(key)]
+			if !ok {
+				continue
+			}
+			switch v := v.(type) {
+			case string:
+				values[alias] = v
+			case float64:
+				if v != math.Trunc(v) {
+					return sourceRecord{}, r.reject(text, alias+" is not a whole number")
+				}
+				values[alias] = strconv.FormatFloat(v, 'f', 0, 64)
+			}
+		}
+		return r.record(text, values["name"], values["gender"], values["count"], values["year"])
+	}
+	if err := r.scanner.Err(); err != nil {
+		return sourceRecord{}, err
+	}
+	return sourceRecord{}, io.EOF
+}
@@ -0,0 +1,197 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSourceFile writes a source file into dir, compressing it when the name ends in .gz.
+func writeSourceFile(t *testing.T, dir string, name string, contents string) {
+	data := []byte(contents)
+	if filepath.Ext(name) == ".gz" {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(data)
+		gz.Close()
+		data = buf.Bytes()
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_openSourceFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		contents    string
+		wantRecords []sourceRecord
+		wantRejects []int
+	}{
+		{"Headerless", "names.csv", "Mary,F,100\n John , m , 90 \n",
+			[]sourceRecord{{"Mary", 'F', 100, 0}, {"John", 'M', 90, 0}}, nil},
+		{"Header", "names.csv", "Year,Sex,Births,Name,Rank\n2001,F,100,Mary,1\n",
+			[]sourceRecord{{"Mary", 'F', 100, 2001}}, nil},
+		{"TSV", "names.tsv", "name\tgender\tcount\nMary\tF\t100\nJohn\tM\t90\n",
+			[]sourceRecord{{"Mary", 'F', 100, 0}, {"John", 'M', 90, 0}}, nil},
+		{"JSONLines", "names.jsonl", "{\"name\": \"Mary\", \"gender\": \"F\", \"count\": 100}\n\n{\"Name\": \"John\", \"Sex\": \"M\", \"Count\": \"90\", \"year\": 1999}\n",
+			[]sourceRecord{{"Mary", 'F', 100, 0}, {"John", 'M', 90, 1999}}, nil},
+		{"Gzip", "names.csv.gz", "Mary,F,100\n",
+			[]sourceRecord{{"Mary", 'F', 100, 0}}, nil},
+		{"SSA", "yob1987.txt", "Jessica,F,55991\nMichael,M,64195\n",
+			[]sourceRecord{{"Jessica", 'F', 55991, 1987}, {"Michael", 'M', 64195, 1987}}, nil},
+		{"SSAGzip", "yob1987.txt.gz", "Jessica,F,55991\n",
+			[]sourceRecord{{"Jessica", 'F', 55991, 1987}}, nil},
+		{"MalformedCSV", "names.csv", "Mary,F,100\nJohn\nSam,X,5\n,F,3\nAnn,F,lots\nZoe,F,-1\nJane,F,7\n",
+			[]sourceRecord{{"Mary", 'F', 100, 0}, {"Jane", 'F', 7, 0}}, []int{2, 3, 4, 5, 6}},
+		{"MalformedJSONLines", "names.jsonl", "{\"name\": \"Mary\"\n{\"name\": \"Ann\", \"gender\": \"F\", \"count\": 1.5}\n{\"name\": \"Jane\", \"gender\": \"F\", \"count\": 7}\n",
+			[]sourceRecord{{"Jane", 'F', 7, 0}}, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeSourceFile(t, dir, tt.file, tt.contents)
+			reader, err := openSourceFile(filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatalf("openSourceFile() error = %v", err)
+			}
+			defer reader.Close()
+			records := []sourceRecord{}
+			rejects := []int{}
+			for {
+				record, err := reader.next()
+				if err == io.EOF {
+					break
+				}
+				if reject, ok := err.(*RejectedRow); ok {
+					rejects = append(rejects, reject.Row)
+					continue
+				}
+				if err != nil {
+					t.Fatalf("next() error = %v", err)
+				}
+				records = append(records, record)
+			}
+			if !reflect.DeepEqual(records, tt.wantRecords) {
+				t.Errorf("next() records = %v, want %v", records, tt.wantRecords)
+			}
+			if len(tt.wantRejects) == 0 {
+				tt.wantRejects = []int{}
+			}
+			if !reflect.DeepEqual(rejects, tt.wantRejects) {
+				t.Errorf("next() rejected rows = %v, want %v", rejects, tt.wantRejects)
+			}
+		})
+	}
+}
+
+func Test_listSourceFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.csv", "a.tsv.gz", "c.jsonl", "yob2000.txt", "README.txt", "notes.md", "b.meta.csv"} {
+		writeSourceFile(t, dir, name, "")
+	}
+	got, err := listSourceFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.tsv.gz", "b.csv", "c.jsonl", "yob2000.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listSourceFiles() = %v, want %v", got, want)
+	}
+}
+
+func Test_readSourceFiles(t *testing.T) {
+	counts := func(names namePopularity) map[string]int {
+		c := map[string]int{}
+		for _, n := range names {
+			c[n.Name] = n.Popularity()
+		}
+		return c
+	}
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        map[string]int
+		wantRejects int
+	}{
+		// Without years each file is weighted by its position.
+		{"FileOrder", map[string]string{"a.csv": "Mary,F,100\n", "b.csv": "Mary,F,100\nJohn,M,50\n"},
+			map[string]int{"Mary": 150, "John": 50}, 0},
+		// SSA files are weighted by the year in their names.
+		{"SSAYears", map[string]string{"yob2000.txt": "Mary,F,100\n", "yob1990.txt.gz": "Mary,F,100\nJohn,M,50\n"},
+			map[string]int{"Mary": 150, "John": 25}, 0},
+		// A year column spreads the years of one file.
+		{"YearColumn", map[string]string{"names.csv": "name,gender,count,year\nMary,F,100,2000\nMary,F,100,1990\nJohn,M,50,1990\n"},
+			map[string]int{"Mary": 150, "John": 25}, 0},
+		{"Rejects", map[string]string{"names.csv": "Mary,F,100\nbroken\n", "names.jsonl": "not json\n"},
+			map[string]int{"Mary": 50}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, contents := range tt.files {
+				writeSourceFile(t, dir, name, contents)
+			}
+			names, rejects, err := readSourceFiles(dir, nil)
+			if err != nil {
+				t.Fatalf("readSourceFiles() error = %v", err)
+			}
+			if got := counts(names); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readSourceFiles() = %v, want %v", got, tt.want)
+			}
+			if len(rejects) != tt.wantRejects {
+				t.Errorf("readSourceFiles() rejects = %v, want %v", rejects, tt.wantRejects)
+			}
+		})
+	}
+}
+
+func Test_readSourceFilesTruncated(t *testing.T) {
+	dir := t.TempDir()
+	var contents strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&contents, "Name%v,F,%v\n", i, 5000-i)
+	}
+	writeSourceFile(t, dir, "names.csv.gz", contents.String())
+	path := filepath.Join(dir, "names.csv.gz")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan []RejectedRow)
+	go func() {
+		_, rejects, _ := readSourceFiles(dir, nil)
+		done <- rejects
+	}()
+	select {
+	case rejects := <-done:
+		if len(rejects) != 1 || rejects[0].File != "names.csv.gz" {
+			t.Errorf("readSourceFiles() rejects = %v, want the truncated file once", rejects)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("readSourceFiles() did not stop reading a truncated file")
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	_ "modernc.org/sqlite" // Cgo-free version of sqlite. Registers the "sqlite" driver.
+)
+
+const (
+	// cgoSQLiteDriver is the database/sql driver of github.com/mattn/go-sqlite3, which gorm.io/driver/sqlite
+	// uses by default. It needs Cgo and therefore doesn't work well for some target architectures.
+	cgoSQLiteDriver = "sqlite3"
+	// pureGoSQLiteDriver is the database/sql driver of modernc.org/sqlite. It is SQLite translated to Go, so it
+	// works without Cgo and gives the same results for the same SQL.
+	pureGoSQLiteDriver = "sqlite"
+)
+
+// openSQLite opens a SQLite database with the given database/sql driver. Both drivers use the SQLite dialect of
+// gorm.io/driver/sqlite.
+func openSQLite(dsn string, driver string) gorm.Dialector {
+	return &sqlite.Dialector{DriverName: driver, DSN: dsn}
+}
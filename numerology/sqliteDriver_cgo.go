@@ -0,0 +1,19 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+//go:build cgo && !puresqlite
+// +build cgo,!puresqlite
+
+package numerology
+
+// sqliteDriver is the driver of sqlite:// DSNs and SQLite snapshots. Build with the puresqlite tag, or with Cgo
+// disabled, to use the Cgo-free driver instead.
+const sqliteDriver = cgoSQLiteDriver
@@ -0,0 +1,82 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"gorm.io/driver/sqlite"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_pureGoSQLiteMatchesMemory(t *testing.T) {
+	connection := DB
+	DB = nil
+	dsn := "moderncsqlite://" + filepath.Join(t.TempDir(), "names.db")
+	defer func() {
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+		DB = connection
+		delete(largestNameValueInTable, "irish")
+	}()
+	if err := CreateDatabase(dsn, "test_names"); err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	if d, ok := DB.Dialector.(*sqlite.Dialector); !ok || d.DriverName != pureGoSQLiteDriver {
+		t.Errorf("moderncsqlite:// dialector = %#v, want the %v driver", DB.Dialector, pureGoSQLiteDriver)
+	}
+	delete(largestNameValueInTable, "irish")
+	got, want := gormStore{DB}.largestValue("irish"), memoryStore{memoryDictionaries}.largestValue("irish")
+	if got != want {
+		t.Errorf("largestValue() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		opts  NameSearchOpts
+	}{
+		{"Common", "?", NameSearchOpts{Count: 20, Full: []int{1, 4}}},
+		{"Uncommon", "? Doe", NameSearchOpts{Count: 20, Sort: UncommonSort, Vowels: []int{3, -11}}},
+		{"Random", "?", NameSearchOpts{Count: 20, Sort: RandomSort, Seed: 7, Consonants: []int{2, 5, 8}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Dictionary = "irish"
+			opts.Database = dsn
+			sqlResults, sqlOffset, err := nameSearch(tt.query, Pythagorean, []int{11, 22, 33}, true, opts)
+			if err != nil {
+				t.Fatalf("nameSearch() error = %v", err)
+			}
+			opts.Database = "memory://"
+			memoryResults, memoryOffset, err := nameSearch(tt.query, Pythagorean, []int{11, 22, 33}, true, opts)
+			if err != nil {
+				t.Fatalf("nameSearch() error = %v", err)
+			}
+			sqlNames, memoryNames := []string{}, []string{}
+			for _, r := range sqlResults {
+				sqlNames = append(sqlNames, r.Name)
+			}
+			for _, r := range memoryResults {
+				memoryNames = append(memoryNames, r.Name)
+			}
+			if len(sqlNames) == 0 {
+				t.Errorf("moderncsqlite returned no names")
+			}
+			if !reflect.DeepEqual(sqlNames, memoryNames) || sqlOffset != memoryOffset {
+				t.Errorf("moderncsqlite results do not match memory. sqlite = %v %v, memory = %v %v", sqlNames, sqlOffset, memoryNames, memoryOffset)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Distribution is how many names have each value. Male and Female only count the names that have been used for
+// that gender, so a unisex name is in both.
+type Distribution struct {
+	All    map[int]int `json:"all"`
+	Male   map[int]int `json:"male"`
+	Female map[int]int `json:"female"`
+}
+
+func newDistribution() Distribution {
+	return Distribution{map[int]int{}, map[int]int{}, map[int]int{}}
+}
+
+// add counts a value of a name.
+func (d Distribution) add(r precalculatedNumerology, value int) {
+	d.All[value]++
+	if r.MaleRank > 0 {
+		d.Male[value]++
+	}
+	if r.FemaleRank > 0 {
+		d.Female[value]++
+	}
+}
+
+// NumberSystemStatistics are the distributions of the numerological values of the names in a dictionary for one
+// number system. The values are of each name on its own, reduced with the master numbers that the statistics
+// were asked for.
+type NumberSystemStatistics struct {
+	Full       Distribution `json:"full"`
+	Vowels     Distribution `json:"vowels"`
+	Consonants Distribution `json:"consonants"`
+	// HiddenPassions is how many names have each number as a hidden passion. A name with several hidden passions
+	// is counted once for each of them.
+	HiddenPassions Distribution `json:"hidden_passions"`
+	// KarmicLessons is how many names are missing each number.
+	KarmicLessons Distribution `json:"karmic_lessons"`
+}
+
+// YStatistics counts the names with a "Y" by how the "Y" is treated. A name is counted once in each group that
+// any of its "Y"s are in. See maskConstructor for the rules.
+type YStatistics struct {
+	// Names is the number of names that have a "Y".
+	Names int `json:"names"`
+	// Vowel and Consonant are the names with a "Y" that is a vowel or a consonant.
+	Vowel     int `json:"vowel"`
+	Consonant int `json:"consonant"`
+	// Ambiguous are the names with a "Y" between a vowel and a consonant. The rules give it a default, but there
+	// are exceptions, so these names may be calculated differently by other numerologists.
+	Ambiguous int `json:"ambiguous"`
+}
+
+// DictionaryStatistics summarizes the names in a dictionary. It shows which numerological values are rare, so
+// that it is known before searching which targets will only find a few names.
+type DictionaryStatistics struct {
+	Dictionary string `json:"dictionary"`
+	Names      int    `json:"names"`
+	Male       int    `json:"male"`
+	Female     int    `json:"female"`
+	// NumberSystems has the statistics of every number system by its lowercase name.
+	NumberSystems map[string]NumberSystemStatistics `json:"number_systems"`
+	// Lengths is how many names have each number of characters.
+	Lengths Distribution `json:"lengths"`
+	Y       YStatistics  `json:"y"`
+}
+
+// storedNumerology returns the precalculated values of a row for a number system. Counts are indexed by number.
+func storedNumerology(r precalculatedNumerology, ns NumberSystem) (full int, vowels int, consonants int, counts map[int32]int) {
+	counts = map[int32]int{}
+	columns := columnsOf(ns)
+	if columns.Key != "" {
+		values := r.NumberSystems[columns.Key]
+		for _, n := range ns.ValidNumbers {
+			counts[int32(n)] = int(values.count(n))
+		}
+		return int(values.FullValue), int(values.VowelsValue), int(values.ConsonantsValue), counts
+	}
+	v := reflect.ValueOf(r)
+	field := func(name string) int {
+		return int(v.FieldByName(name).Uint())
+	}
+	key := numberSystemKey(ns)
+	prefix := strings.ToUpper(key[:1]) + key[1:]
+	for _, n := range ns.ValidNumbers {
+		counts[int32(n)] = field(fmt.Sprintf("%v%d", strings.ToUpper(prefix[:1]), n))
+	}
+	return field(prefix + "Full"), field(prefix + "Vowels"), field(prefix + "Consonants"), counts
+}
+
+// reducedValue reduces a precalculated value of a name. Zero stays zero.
+func reducedValue(value int, masterNumbers []int) int {
+	steps := reduceNumbers(value, masterNumbers, []int{})
+	return steps[len(steps)-1]
+}
+
+// countY adds the "Y"s of a name to the statistics.
+func countY(stats *YStatistics, name string) {
+	runes := []rune(strings.ToLower(name))
+	mask := maskConstructor(name)
+	isVowel := func(r rune) bool { return strings.ContainsRune("aeiou", r) }
+	var hasY, vowel, consonant, ambiguous bool
+	for i, letter := range runes {
+		if letter != 'y' {
+			continue
+		}
+		hasY = true
+		if mask.letterMask[i] {
+			vowel = true
+		} else {
+			consonant = true
+		}
+		if i > 0 && i < len(runes)-1 && isVowel(runes[i-1]) != isVowel(runes[i+1]) {
+			ambiguous = true
+		}
+	}
+	if !hasY {
+		return
+	}
+	stats.Names++
+	for _, c := range []struct {
+		in    bool
+		count *int
+	}{{vowel, &stats.Vowel}, {consonant, &stats.Consonant}, {ambiguous, &stats.Ambiguous}} {
+		if c.in {
+			*c.count++
+		}
+	}
+}
+
+// calculateStatistics summarizes the rows of a dictionary.
+func calculateStatistics(dictionary string, rows []precalculatedNumerology, masterNumbers []int) DictionaryStatistics {
+	stats := DictionaryStatistics{
+		Dictionary:    dictionary,
+		Names:         len(rows),
+		NumberSystems: map[string]NumberSystemStatistics{},
+		Lengths:       newDistribution(),
+	}
+	for _, ns := range numberSystems {
+		stats.NumberSystems[strings.ToLower(ns.Name)] = NumberSystemStatistics{
+			newDistribution(), newDistribution(), newDistribution(), newDistribution(), newDistribution(),
+		}
+	}
+	for _, r := range rows {
+		if r.MaleRank > 0 {
+			stats.Male++
+		}
+		if r.FemaleRank > 0 {
+			stats.Female++
+		}
+		stats.Lengths.add(r, utf8.RuneCountInString(r.Name))
+		countY(&stats.Y, r.Name)
+		for _, ns := range numberSystems {
+			s := stats.NumberSystems[strings.ToLower(ns.Name)]
+			full, vowels, consonants, counts := storedNumerology(r, ns)
+			s.Full.add(r, reducedValue(full, masterNumbers))
+			s.Vowels.add(r, reducedValue(vowels, masterNumbers))
+			s.Consonants.add(r, reducedValue(consonants, masterNumbers))
+			for _, n := range hiddenPassions(counts).Numbers {
+				s.HiddenPassions.add(r, n)
+			}
+			for _, n := range karmicLessons(counts).Numbers {
+				s.KarmicLessons.add(r, n)
+			}
+		}
+	}
+	return stats
+}
+
+// GetDictionaryStatistics summarizes the names in a dictionary. For each number system it reports how the names
+// are distributed across the Full, Vowels and Consonants values, reduced with the given master numbers, and how
+// often each number is a Hidden Passion or a Karmic Lesson. It also reports the lengths of the names and how many
+// of them have a "Y" that is a vowel, a consonant, or could be either. Every distribution is also split by gender.
+//
+// The values are of the names on their own. Use NameNumerology.CountMatches to find how many names a search
+// would return once the rest of the name is added.
+func GetDictionaryStatistics(dsn string, dictionary string, masterNumbers []int) (stats DictionaryStatistics, err error) {
+	table := strings.ToLower(dictionary)
+	var rows []precalculatedNumerology
+	switch {
+	case usesEmbeddedDictionary(dsn):
+		if table == "" {
+			table = EmbeddedDictionary
+		}
+		store, err := openEmbeddedDictionary()
+		if err != nil {
+			return stats, err
+		}
+		d, ok := store.(memoryStore).dictionaries[table]
+		if !ok {
+			return stats, fmt.Errorf("dictionary %v is not embedded", table)
+		}
+		rows = d.rows()
+	default:
+		if rows, _, err = readDictionary(dsn, table); err != nil {
+			return stats, err
+		}
+		if !isMemoryDSN(dsn) {
+			if err := loadNumberSystems(DB, table, rows); err != nil {
+				return stats, err
+			}
+		}
+	}
+	return calculateStatistics(table, rows, masterNumbers), nil
+}
+
+// NameSearchCount is how many names a search would return.
+type NameSearchCount struct {
+	// Matches is the number of names that satisfy the search.
+	Matches int64 `json:"matches"`
+	// Names is the number of names in the dictionaries that were searched.
+	Names int64 `json:"names"`
+	// Fraction is Matches divided by Names.
+	Fraction float64 `json:"fraction"`
+}
+
+// nameSearchCount counts the names that a search would return across all of its pages.
+func nameSearchCount(n string, numberSystem NumberSystem, masterNumbers []int, reduceWords bool, opts NameSearchOpts) (count NameSearchCount, err error) {
+	dictionaries := opts.Dictionaries
+	if len(dictionaries) == 0 {
+		dictionaries = []string{opts.Dictionary}
+	}
+	// A name that is in more than one dictionary is only returned once.
+	seen := map[string]bool{}
+	for _, dictionary := range dictionaries {
+		dictionaryOpts := opts
+		dictionaryOpts.Dictionary = dictionary
+		dictionaryOpts.Dictionaries = nil
+		search, err := prepareNameSearch(n, numberSystem, masterNumbers, reduceWords, dictionaryOpts)
+		if err != nil {
+			return NameSearchCount{}, err
+		}
+		count.Names += search.tableSize
+		candidates, err := search.candidates()
+		if err != nil {
+			return NameSearchCount{}, err
+		}
+		for _, r := range candidates {
+			rank := r.rank(search.query.RankColumn)
+			switch strings.ToLower(opts.Sort) {
+			case "", CommonSort:
+				if search.query.CommonRank > 0 && rank > search.query.CommonRank {
+					continue
+				}
+			case UncommonSort:
+				if rank < search.query.UncommonRank {
+					continue
+				}
+			}
+			seen[strings.ToLower(r.Name)] = true
+		}
+	}
+	count.Matches = int64(len(seen))
+	if count.Names > 0 {
+		count.Fraction = float64(count.Matches) / float64(count.Names)
+	}
+	return count, nil
+}
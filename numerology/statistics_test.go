@@ -0,0 +1,131 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func Test_calculateStatistics(t *testing.T) {
+	names := namePopularity{{"Mary", 0, 300, 0}, {"Yolanda", 0, 200, 0}, {"Kyle", 150, 0, 0}, {"Maya", 0, 100, 0}, {"Tyanna", 0, 90, 0}, {"John", 80, 10, 0}}
+	sort.Sort(sort.Reverse(names))
+	masterNumbers := []int{11, 22, 33}
+	stats := calculateStatistics("test", precalculateNames(names), masterNumbers)
+	if stats.Names != 6 || stats.Male != 2 || stats.Female != 5 {
+		t.Errorf("calculateStatistics() names = %v %v %v", stats.Names, stats.Male, stats.Female)
+	}
+	want := YStatistics{Names: 5, Vowel: 3, Consonant: 2, Ambiguous: 1}
+	if stats.Y != want {
+		t.Errorf("calculateStatistics() y = %+v, want %+v", stats.Y, want)
+	}
+	if stats.Lengths.All[4] != 4 || stats.Lengths.Male[4] != 2 || stats.Lengths.All[7] != 1 {
+		t.Errorf("calculateStatistics() lengths = %+v", stats.Lengths)
+	}
+	for _, ns := range []NumberSystem{Pythagorean, Chaldean} {
+		s, ok := stats.NumberSystems[strings.ToLower(ns.Name)]
+		if !ok {
+			t.Fatalf("calculateStatistics() has no %v statistics", ns.Name)
+		}
+		full, hidden, lessons := map[int]int{}, map[int]int{}, map[int]int{}
+		for _, n := range names {
+			name := Name(n.Name, ns, masterNumbers, true)
+			full[name.Full().Value]++
+			for _, p := range name.HiddenPassions().Numbers {
+				hidden[p]++
+			}
+			for _, l := range name.KarmicLessons().Numbers {
+				lessons[l]++
+			}
+		}
+		for value, count := range full {
+			if s.Full.All[value] != count {
+				t.Errorf("calculateStatistics() %v full %v = %v, want %v", ns.Name, value, s.Full.All[value], count)
+			}
+		}
+		for value, count := range hidden {
+			if s.HiddenPassions.All[value] != count {
+				t.Errorf("calculateStatistics() %v hidden passion %v = %v, want %v", ns.Name, value, s.HiddenPassions.All[value], count)
+			}
+		}
+		for value, count := range lessons {
+			if s.KarmicLessons.All[value] != count {
+				t.Errorf("calculateStatistics() %v karmic lesson %v = %v, want %v", ns.Name, value, s.KarmicLessons.All[value], count)
+			}
+		}
+	}
+}
+
+func TestGetDictionaryStatistics(t *testing.T) {
+	for _, dsn := range []string{"sqlite://file::memory:?cache=shared", "memory://"} {
+		t.Run(dsn, func(t *testing.T) {
+			stats, err := GetDictionaryStatistics(dsn, "Irish", []int{11, 22, 33})
+			if err != nil {
+				t.Fatalf("GetDictionaryStatistics() error = %v", err)
+			}
+			if stats.Names != int(memoryStore{memoryDictionaries}.count("irish")) {
+				t.Errorf("GetDictionaryStatistics() names = %v", stats.Names)
+			}
+			total := 0
+			for _, count := range stats.NumberSystems["chaldean"].Vowels.All {
+				total += count
+			}
+			if total != stats.Names {
+				t.Errorf("GetDictionaryStatistics() vowels distribution has %v names, want %v", total, stats.Names)
+			}
+		})
+	}
+	if _, err := GetDictionaryStatistics("memory://", "missing", nil); err == nil {
+		t.Errorf("GetDictionaryStatistics() of a missing dictionary did not return an error")
+	}
+}
+
+func TestNameNumerology_CountMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		opts NameSearchOpts
+	}{
+		{"Full", NameSearchOpts{Full: []int{1, 8}}},
+		{"CommonRank", NameSearchOpts{Vowels: []int{6}, CommonRank: 2000}},
+		{"Uncommon", NameSearchOpts{Sort: UncommonSort, Gender: Female, HiddenPassions: []int{5}}},
+		{"Dictionaries", NameSearchOpts{Dictionaries: []string{"usa_census", "irish"}, Consonants: []int{3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Database = "memory://"
+			if len(opts.Dictionaries) == 0 {
+				opts.Dictionary = "usa_census"
+			}
+			name := Name("Jane ? Doe", Pythagorean, []int{11, 22, 33}, true)
+			count, err := name.CountMatches(opts)
+			if err != nil {
+				t.Fatalf("CountMatches() error = %v", err)
+			}
+			opts.Count = 100000
+			results, _, err := name.Search(opts)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if count.Matches == 0 || count.Matches != int64(len(results)) {
+				t.Errorf("CountMatches() = %v, want %v", count.Matches, len(results))
+			}
+			if count.Fraction <= 0 || count.Fraction >= 1 {
+				t.Errorf("CountMatches() fraction = %v", count.Fraction)
+			}
+		})
+	}
+	if _, err := Name("Jane Doe", Pythagorean, nil, true).CountMatches(NameSearchOpts{}); err == nil {
+		t.Errorf("CountMatches() without '?' did not return an error")
+	}
+}
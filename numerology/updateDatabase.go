@@ -0,0 +1,352 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// importLog records a source file that has been applied to a dictionary. Every import of a dictionary has its
+// own Batch number so that the files of the most recent import can be compared against the files on disk. The
+// files of an import are Pending while its rows are inserted, so that an import that did not finish can be told
+// apart from one that did.
+type importLog struct {
+	Id         int64  `gorm:"primaryKey"`
+	Dictionary string `gorm:"index;type:varchar(255)"`
+	Batch      int64
+	File       string
+	Checksum   string `gorm:"type:varchar(64)"`
+	AppliedAt  time.Time
+	Pending    bool
+}
+
+// sourceFile is a source file of a dictionary and the SHA-256 checksum of its contents.
+type sourceFile struct {
+	File     string
+	Checksum string
+}
+
+// getSourceFiles finds the source files of a dictionary directory and calculates their checksums. The files are
+// in the same order that extractNamesFromFiles reads them, followed by the name metadata files and the weighting
+// file if there is one.
+func getSourceFiles(directory string) ([]sourceFile, error) {
+	files, err := listSourceFiles(directory)
+	if err != nil {
+		return nil, err
+	}
+	// Changes to the name metadata or the weighting change the dictionary as much as a change to the names does.
+	metadataFiles, err := globFiles(directory, nameMetadataPatterns)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, metadataFiles...)
+	if _, err := os.Stat(filepath.Join(directory, weightingFile)); err == nil {
+		files = append(files, weightingFile)
+	}
+	sourceFiles := []sourceFile{}
+	for _, fn := range files {
+		file, err := os.Open(filepath.Join(directory, fn))
+		if err != nil {
+			return nil, fmt.Errorf("unable to open file %v", filepath.Join(directory, fn))
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read file %v", filepath.Join(directory, fn))
+		}
+		sourceFiles = append(sourceFiles, sourceFile{fn, hex.EncodeToString(hash.Sum(nil))})
+	}
+	return sourceFiles, nil
+}
+
+// appliedSourceFiles returns the source files of the most recent finished import of a dictionary and its batch
+// number.
+func appliedSourceFiles(db *gorm.DB, dictionary string) (files []sourceFile, batch int64) {
+	var logs []importLog
+	db.Where("dictionary = ? AND pending = ?", dictionary, false).Order("batch desc, id asc").Find(&logs)
+	for _, l := range logs {
+		if batch == 0 {
+			batch = l.Batch
+		}
+		if l.Batch != batch {
+			break
+		}
+		files = append(files, sourceFile{l.File, l.Checksum})
+	}
+	return files, batch
+}
+
+// pendingSourceFiles returns the source files of an import of a dictionary that has not finished.
+func pendingSourceFiles(db *gorm.DB, dictionary string) (files []sourceFile) {
+	var logs []importLog
+	db.Where("dictionary = ? AND pending = ?", dictionary, true).Order("id asc").Find(&logs)
+	for _, l := range logs {
+		files = append(files, sourceFile{l.File, l.Checksum})
+	}
+	return files
+}
+
+// recordImport adds the source files of an import of a dictionary to the import log. The files of a pending
+// import replace those of an earlier import that did not finish, and are applied by finishImport.
+func recordImport(db *gorm.DB, dictionary string, files []sourceFile, pending bool) error {
+	if err := db.Where("dictionary = ? AND pending = ?", dictionary, true).Delete(&importLog{}).Error; err != nil {
+		return err
+	}
+	_, batch := appliedSourceFiles(db, dictionary)
+	now := time.Now()
+	for _, f := range files {
+		if err := db.Create(&importLog{
+			Dictionary: dictionary,
+			Batch:      batch + 1,
+			File:       f.File,
+			Checksum:   f.Checksum,
+			AppliedAt:  now,
+			Pending:    pending,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishImport marks the pending import of a dictionary as finished.
+func finishImport(db *gorm.DB, dictionary string) error {
+	return db.Model(&importLog{}).Where("dictionary = ? AND pending = ?", dictionary, true).
+		Updates(map[string]interface{}{"pending": false, "applied_at": time.Now()}).Error
+}
+
+// sameSourceFiles reports whether two lists of source files have the same files with the same checksums.
+func sameSourceFiles(a []sourceFile, b []sourceFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sorted := func(files []sourceFile) []sourceFile {
+		s := append([]sourceFile{}, files...)
+		sort.Slice(s, func(i, j int) bool { return s[i].File < s[j].File })
+		return s
+	}
+	return reflect.DeepEqual(sorted(a), sorted(b))
+}
+
+// dictionaryChanges are the changes needed to bring a dictionary up to date with its source files.
+type dictionaryChanges struct {
+	Inserts []precalculatedNumerology
+	Updates []precalculatedNumerology
+	Deletes []precalculatedNumerology
+}
+
+// mergeNames merges the names from the source files with the rows that are already in a dictionary and ranks
+// them again. The counts from the source files replace the counts of the rows. Names that are no longer in the
+// source files keep their counts unless deleteMissing is true, in which case they are deleted. Rows keep their
+// ids so that random sort stays the same for existing names.
+func mergeNames(existing []precalculatedNumerology, names namePopularity, metadata map[string]nameMetadata, deleteMissing bool) (rows []precalculatedNumerology, changes dictionaryChanges) {
+	existingByName := map[string]precalculatedNumerology{}
+	for _, r := range existing {
+		existingByName[r.Name] = r
+	}
+	inFiles := map[string]bool{}
+	merged := namePopularity{}
+	for _, entry := range names {
+		inFiles[entry.Name] = true
+		merged = append(merged, entry)
+	}
+	for _, r := range existing {
+		if inFiles[r.Name] {
+			continue
+		}
+		if deleteMissing {
+			changes.Deletes = append(changes.Deletes, r)
+			continue
+		}
+		merged = append(merged, nameEntry{r.Name, int(r.MaleCount), int(r.FemaleCount), r.Trend})
+	}
+	sort.Sort(sort.Reverse(merged))
+
+	rows = precalculateNames(merged)
+	applyNameMetadata(rows, metadata)
+	for i, r := range rows {
+		old, ok := existingByName[r.Name]
+		if !ok {
+			changes.Inserts = append(changes.Inserts, r)
+			continue
+		}
+		rows[i].Id = old.Id
+		if !reflect.DeepEqual(rows[i], old) {
+			changes.Updates = append(changes.Updates, rows[i])
+		}
+	}
+	return rows, changes
+}
+
+// UpdateDatabase merges the CSV files in baseDir into dictionaries that already exist, or creates them if they
+// do not. Unlike CreateDatabase, a table that is not empty is not skipped. All the files of a dictionary are read
+// again, and the counts from the files replace the counts of the names that are already in the table; they are
+// not added to them. Every name is then ranked again and the rows that changed are updated. Names that are no
+// longer in any of the files keep their counts, unless deleteMissing is true, in which case they are deleted.
+//
+// The files that are applied are recorded with their checksums in an import log table. A dictionary whose files
+// have not changed since the last import is skipped. Dictionaries in memory have no import log and are always
+// merged.
+//
+// UpdateDatabase is quiet. Use UpdateDatabaseWithOpts to follow the progress and to get a report of the update.
+func UpdateDatabase(dsn string, baseDir string, deleteMissing bool) error {
+	_, err := UpdateDatabaseWithOpts(dsn, baseDir, deleteMissing, ImportOpts{})
+	return err
+}
+
+// UpdateDatabaseWithOpts is UpdateDatabase with options for the progress and the logging of the update. The
+// report has the number of names that were inserted, updated and deleted in each dictionary, the dictionaries
+// that were skipped, the rows of the source files that could not be read, and the names that have characters
+// that cannot be calculated.
+func UpdateDatabaseWithOpts(dsn string, baseDir string, deleteMissing bool, opts ImportOpts) (ImportReport, error) {
+	run := &importRun{opts: opts}
+	if isMemoryDSN(dsn) {
+		err := run.updateMemoryDatabase(baseDir, deleteMissing)
+		return run.report, err
+	}
+	run.log(LogInfo, "Connecting to database", nil)
+	if err := connectToDatabase(dsn); err != nil {
+		return run.report, errors.New("unable to connect to database. " + err.Error())
+	}
+	if err := DB.AutoMigrate(&importLog{}, &dictionaryMetadata{}); err != nil {
+		return run.report, err
+	}
+	for _, dir := range getAllDirectories(baseDir) {
+		d := run.dictionary(dir)
+		files, err := getSourceFiles(filepath.Join(baseDir, dir))
+		if err != nil {
+			return run.report, err
+		}
+		if applied, _ := appliedSourceFiles(DB, dir); sameSourceFiles(files, applied) {
+			run.skip(d, SkipUpToDate)
+			continue
+		}
+		if err := setupDatabaseTable(DB, dir); err != nil {
+			return run.report, err
+		}
+		names, err := run.readNames(d, filepath.Join(baseDir, dir))
+		if err != nil {
+			return run.report, err
+		}
+		metadata, err := run.readNameMetadata(d, filepath.Join(baseDir, dir))
+		if err != nil {
+			return run.report, err
+		}
+		var existing []precalculatedNumerology
+		if err := DB.Table(dir).Find(&existing).Error; err != nil {
+			return run.report, err
+		}
+		if err := loadNumberSystems(DB, dir, existing); err != nil {
+			return run.report, err
+		}
+		rows, changes := mergeNames(existing, names, metadata, deleteMissing)
+		run.unacceptable(d, names, rows)
+
+		run.log(LogInfo, "Updating database table", Fields{
+			"dictionary": dir, "inserts": len(changes.Inserts), "updates": len(changes.Updates), "deletes": len(changes.Deletes),
+		})
+		total := len(changes.Inserts) + len(changes.Updates) + len(changes.Deletes)
+		done := 0
+		step := func(n int) {
+			done += n
+			run.progress(ProgressUpdating, done, total)
+		}
+		// All the changes are made in one transaction so that an update that fails leaves the table as it was.
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			if len(changes.Inserts) > 0 {
+				if err := tx.Table(dir).CreateInBatches(changes.Inserts, insertBatchSize).Error; err != nil {
+					return fmt.Errorf("unable to insert records into database: %v", err)
+				}
+				step(len(changes.Inserts))
+			}
+			for _, r := range changes.Updates {
+				if err := tx.Table(dir).Save(&r).Error; err != nil {
+					return fmt.Errorf("unable to update record in database: %v", r.Name)
+				}
+				step(1)
+			}
+			for _, r := range changes.Deletes {
+				if err := tx.Table(dir).Delete(&precalculatedNumerology{}, r.Id).Error; err != nil {
+					return fmt.Errorf("unable to delete record from database: %v", r.Name)
+				}
+				step(1)
+			}
+			if err := writeNumberSystems(tx, dir, append(changes.Inserts, changes.Updates...)); err != nil {
+				return err
+			}
+			if err := deleteNumberSystems(tx, dir, changes.Deletes); err != nil {
+				return err
+			}
+			if err := recordImport(tx, dir, files, false); err != nil {
+				return err
+			}
+			return writeMetadata(tx, currentMetadata(dir, filepath.Join(baseDir, dir), files))
+		}); err != nil {
+			return run.report, err
+		}
+		d.Inserted, d.Updated, d.Deleted = len(changes.Inserts), len(changes.Updates), len(changes.Deletes)
+		// The largest value and the order of the names may have changed.
+		forgetDictionary(dir)
+	}
+	return run.report, nil
+}
+
+// updateMemoryDatabase merges the CSV files in baseDir into the dictionaries that are loaded in memory.
+func (run *importRun) updateMemoryDatabase(baseDir string, deleteMissing bool) error {
+	for _, dir := range getAllDirectories(baseDir) {
+		dictionary := strings.ToLower(dir)
+		report := run.dictionary(dictionary)
+		names, err := run.readNames(report, filepath.Join(baseDir, dir))
+		if err != nil {
+			return err
+		}
+		metadata, err := run.readNameMetadata(report, filepath.Join(baseDir, dir))
+		if err != nil {
+			return err
+		}
+		var existing []precalculatedNumerology
+		var lastId int64
+		if d, ok := memoryDictionaries[dictionary]; ok {
+			existing = d.rows()
+		}
+		for _, r := range existing {
+			if r.Id > lastId {
+				lastId = r.Id
+			}
+		}
+		rows, changes := mergeNames(existing, names, metadata, deleteMissing)
+		run.unacceptable(report, names, rows)
+		report.Inserted, report.Updated, report.Deleted = len(changes.Inserts), len(changes.Updates), len(changes.Deletes)
+		for i := range rows {
+			// New names get the next id just like an auto increment primary key.
+			if rows[i].Id == 0 {
+				lastId++
+				rows[i].Id = lastId
+			}
+		}
+		memoryDictionaries[dictionary] = newMemoryDictionary(rows)
+		forgetDictionary(dictionary)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_mergeNames(t *testing.T) {
+	existingNames := namePopularity{{"Mary", 0, 300, 0}, {"John", 200, 0, 0}, {"Kelly", 50, 60, 0}}
+	sort.Sort(sort.Reverse(existingNames))
+	existing := precalculateNames(existingNames)
+	for i := range existing {
+		existing[i].Id = int64(i + 1)
+	}
+	names := namePopularity{{"Mary", 0, 300, 0}, {"John", 200, 150, 0}, {"Zoe", 0, 250, 0}}
+	sort.Sort(sort.Reverse(names))
+
+	tests := []struct {
+		name          string
+		deleteMissing bool
+		wantNames     []string
+		wantInserts   int
+		wantUpdates   int
+		wantDeletes   int
+	}{
+		{"KeepMissing", false, []string{"John", "Mary", "Zoe", "Kelly"}, 1, 3, 0},
+		{"DeleteMissing", true, []string{"John", "Mary", "Zoe"}, 1, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, changes := mergeNames(existing, names, nil, tt.deleteMissing)
+			gotNames := []string{}
+			for _, r := range rows {
+				gotNames = append(gotNames, r.Name)
+			}
+			if !reflect.DeepEqual(gotNames, tt.wantNames) {
+				t.Errorf("mergeNames() names = %v, want %v", gotNames, tt.wantNames)
+			}
+			if len(changes.Inserts) != tt.wantInserts || len(changes.Updates) != tt.wantUpdates || len(changes.Deletes) != tt.wantDeletes {
+				t.Errorf("mergeNames() changes = %v %v %v", len(changes.Inserts), len(changes.Updates), len(changes.Deletes))
+			}
+			// Existing names keep their ids.
+			for _, r := range rows {
+				if r.Name == "Mary" && r.Id != existing[0].Id {
+					t.Errorf("mergeNames() id of Mary = %v, want %v", r.Id, existing[0].Id)
+				}
+			}
+		})
+	}
+}
+
+func Test_UpdateDatabase(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := filepath.Join(baseDir, "update_test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile := func(name string, contents string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	if err := connectToDatabase(dsn); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DB.Migrator().DropTable("update_test")
+		DB.Where("dictionary = ?", "update_test").Delete(&importLog{})
+		delete(memoryDictionaries, "update_test")
+		DB = nil
+	}()
+
+	// rowsOf returns the rows of the SQL table and of the memory dictionary in order of rank.
+	rowsOf := func() (sqlRows []precalculatedNumerology, memoryRows []precalculatedNumerology) {
+		DB.Table("update_test").Order("popularity_rank asc").Find(&sqlRows)
+		memoryRows = memoryDictionaries["update_test"].rows()
+		sort.Slice(memoryRows, func(i, j int) bool { return memoryRows[i].PopularityRank < memoryRows[j].PopularityRank })
+		return sqlRows, memoryRows
+	}
+	update := func(deleteMissing bool) {
+		if err := UpdateDatabase(dsn, baseDir, deleteMissing); err != nil {
+			t.Fatalf("UpdateDatabase() error = %v", err)
+		}
+		if err := UpdateDatabase("memory://", baseDir, deleteMissing); err != nil {
+			t.Fatalf("UpdateDatabase() error = %v", err)
+		}
+	}
+
+	writeFile("2019.csv", "Mary,F,300\nJohn,M,200\nKelly,F,60\nKelly,M,50\n")
+	update(false)
+	sqlRows, memoryRows := rowsOf()
+	if len(sqlRows) != 3 || sqlRows[0].Name != "Mary" {
+		t.Fatalf("UpdateDatabase() first import = %v", sqlRows)
+	}
+	if !reflect.DeepEqual(sqlRows, memoryRows) {
+		t.Errorf("UpdateDatabase() memory rows = %v, want %v", memoryRows, sqlRows)
+	}
+	ids := map[string]int64{}
+	for _, r := range sqlRows {
+		ids[r.Name] = r.Id
+	}
+
+	// Nothing changed so nothing is imported.
+	update(false)
+	if _, batch := appliedSourceFiles(DB, "update_test"); batch != 1 {
+		t.Errorf("UpdateDatabase() batch = %v, want 1", batch)
+	}
+	if DB.Table("update_test").Migrator().HasIndex(&precalculatedNumerology{}, "idx_precalculated_numerologies_gender") {
+		t.Errorf("UpdateDatabase() left a duplicate index")
+	}
+
+	// A new file is merged in. John is now used for both genders and is the most popular.
+	writeFile("2020.csv", "John,F,400\nZoe,F,100\n")
+	update(false)
+	sqlRows, memoryRows = rowsOf()
+	if len(sqlRows) != 4 || sqlRows[0].Name != "John" || sqlRows[0].FemaleCount == 0 {
+		t.Fatalf("UpdateDatabase() merged import = %v", sqlRows)
+	}
+	for _, r := range sqlRows {
+		if id, ok := ids[r.Name]; ok && id != r.Id {
+			t.Errorf("UpdateDatabase() id of %v changed from %v to %v", r.Name, id, r.Id)
+		}
+	}
+	if !reflect.DeepEqual(sqlRows, memoryRows) {
+		t.Errorf("UpdateDatabase() memory rows = %v, want %v", memoryRows, sqlRows)
+	}
+	if files, batch := appliedSourceFiles(DB, "update_test"); batch != 2 || len(files) != 2 {
+		t.Errorf("UpdateDatabase() import log = %v %v", files, batch)
+	}
+
+	// Names that are no longer in any file are deleted when asked.
+	if err := os.Remove(filepath.Join(dir, "2019.csv")); err != nil {
+		t.Fatal(err)
+	}
+	update(true)
+	sqlRows, memoryRows = rowsOf()
+	names := []string{}
+	for _, r := range sqlRows {
+		names = append(names, r.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"John", "Zoe"}) {
+		t.Errorf("UpdateDatabase() names after delete = %v", names)
+	}
+	if !reflect.DeepEqual(sqlRows, memoryRows) {
+		t.Errorf("UpdateDatabase() memory rows = %v, want %v", memoryRows, sqlRows)
+	}
+}
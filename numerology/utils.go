@@ -61,6 +61,15 @@ func inIntSlice(v int, slice []int) bool {
 	return false
 }
 
+func inStringSlice(v string, slice []string) bool {
+	for _, s := range slice {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 // Convert a number into a slice of numbers. 1234 -> [1,2,3,4]
 func splitNumber(i uint64) []int {
 	listNumbers := []int{}
@@ -74,6 +83,34 @@ func splitNumber(i uint64) []int {
 	return listNumbers
 }
 
+// countSyllables estimates the number of syllables in a name by counting the groups of vowels in each word. It
+// uses the same vowel rules as the numerological calculations so that "Y" is treated consistently. A silent "e"
+// at the end of a word (Jane, Kyle) does not count as its own syllable.
+func countSyllables(name string) (syllables int) {
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '-'
+	}) {
+		runes := []rune(word)
+		mask := maskConstructor(word)
+		vowels := mask.Vowels()
+		var groups int
+		for i := range runes {
+			if vowels[i] && (i == 0 || !vowels[i-1]) {
+				groups++
+			}
+		}
+		last := len(runes) - 1
+		if groups > 1 && runes[last] == 'e' && !vowels[last-1] {
+			groups--
+		}
+		if groups == 0 {
+			groups = 1
+		}
+		syllables += groups
+	}
+	return syllables
+}
+
 // countNumerologicalNumbers converts the letters of a name into the corresponding numerological values based on the given
 // numberSystem argument. Those numbers are then compiled into a map that counts the occurrences of each number.
 func countNumerologicalNumbers(name string, numberSystem NumberSystem) (counts map[int32]int, maxCount int, unknownChars unknownCharacters) {
@@ -102,14 +139,14 @@ func countNumerologicalNumbers(name string, numberSystem NumberSystem) (counts m
 	return counts, maxCount, unknownChars
 }
 
-// GetNumberSystem returns the appropriate NumberSystem type from the number systems name. (Pythagorean, Chaldean).
+// GetNumberSystem returns the appropriate NumberSystem type from the number systems name. (Pythagorean, Chaldean,
+// or any number system that was added by RegisterNumberSystem).
 func GetNumberSystem(s string) (NumberSystem, error) {
-	switch strings.ToLower(s) {
-	case "pythagorean":
-		return Pythagorean, nil
-	case "chaldean":
-		return Chaldean, nil
-	default:
-		return NumberSystem{}, errors.New("unknown number system: " + s)
+	key := numberSystemKey(NumberSystem{Name: s})
+	for _, ns := range numberSystems {
+		if numberSystemKey(ns) == key && key != "" {
+			return ns, nil
+		}
 	}
+	return NumberSystem{}, errors.New("unknown number system: " + s)
 }
@@ -197,3 +197,26 @@ func TestToAscii(t *testing.T) {
 		})
 	}
 }
+
+func Test_countSyllables(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"Jane", 1},
+		{"Kyle", 1},
+		{"Michael", 2},
+		{"Sydney", 2},
+		{"Elizabeth", 4},
+		{"Anna-Marie", 4},
+		{"John Doe", 2},
+		{"Lee", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countSyllables(tt.name); got != tt.want {
+				t.Errorf("countSyllables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// weightingFile is the name of the file in a dictionary directory that chooses how its periods are weighted.
+const weightingFile = "weighting.json"
+
+// Thresholds of the trend of a name for the TrendingNames and ClassicNames filters. A trend of 1 means that the
+// share of the name has doubled, and -1 means that it has halved.
+const (
+	trendingThreshold = 1.0
+	classicThreshold  = 0.5
+	// maxTrend is the trend of names that are only in the recent or only in the older periods.
+	maxTrend = 10.0
+)
+
+// Weightings chooses how the periods of a dictionary are weighted by the name of its directory. It takes
+// precedence over a weighting.json file in the directory. Dictionaries that have neither use LinearWeighting.
+var Weightings = map[string]Weighting{}
+
+// WeightPeriod is a period of the source files of a dictionary. When every name has a year, from a year column
+// or an SSA file name, the periods are the years. Otherwise each file is a period.
+type WeightPeriod struct {
+	// File is the source file that the names are from.
+	File string
+	// Year is 0 when the periods are files.
+	Year int
+	// Position is the order of the period from oldest to newest starting at 0, out of Periods.
+	Position int
+	Periods  int
+	// Age is the number of years, or files when there are no years, between the period and the newest period.
+	Age int
+}
+
+// Weighting decides how much the counts of a period are worth when the periods of a dictionary are combined.
+// Older names are usually weighted less than modern names.
+type Weighting interface {
+	Weight(period WeightPeriod) float64
+}
+
+// LinearWeighting weights the periods from 1/Periods for the oldest up to 1 for the newest.
+type LinearWeighting struct{}
+
+func (LinearWeighting) Weight(period WeightPeriod) float64 {
+	return float64(period.Position+1) / float64(period.Periods)
+}
+
+// ExponentialWeighting halves the weight of a period for every HalfLife of its age.
+type ExponentialWeighting struct {
+	HalfLife float64
+}
+
+func (w ExponentialWeighting) Weight(period WeightPeriod) float64 {
+	return math.Pow(0.5, float64(period.Age)/w.HalfLife)
+}
+
+// FileWeighting gives each source file its own weight. Files that are not listed have a weight of 1.
+type FileWeighting struct {
+	Weights map[string]float64
+}
+
+func (w FileWeighting) Weight(period WeightPeriod) float64 {
+	if weight, ok := w.Weights[period.File]; ok {
+		return weight
+	}
+	return 1
+}
+
+// RecentWeighting only counts the periods that are younger than Years and weights them all equally.
+type RecentWeighting struct {
+	Years int
+}
+
+func (w RecentWeighting) Weight(period WeightPeriod) float64 {
+	if period.Age < w.Years {
+		return 1
+	}
+	return 0
+}
+
+// weightingConfig is the contents of a weighting.json file.
+// ex. {"strategy": "exponential", "halfLife": 10}
+type weightingConfig struct {
+	Strategy string
+	HalfLife float64
+	Weights  map[string]float64
+	Years    int
+}
+
+// readWeighting returns the weighting of a dictionary directory.
+func readWeighting(directory string) (Weighting, error) {
+	if w, ok := Weightings[filepath.Base(directory)]; ok {
+		return w, nil
+	}
+	data, err := os.ReadFile(filepath.Join(directory, weightingFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return LinearWeighting{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var config weightingConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", filepath.Join(directory, weightingFile), err)
+	}
+	switch strings.ToLower(config.Strategy) {
+	case "", "linear":
+		return LinearWeighting{}, nil
+	case "exponential":
+		if config.HalfLife <= 0 {
+			return nil, errors.New("exponential weighting needs a halfLife greater than 0")
+		}
+		return ExponentialWeighting{config.HalfLife}, nil
+	case "files":
+		return FileWeighting{config.Weights}, nil
+	case "recent":
+		if config.Years <= 0 {
+			return nil, errors.New("recent weighting needs years greater than 0")
+		}
+		return RecentWeighting{config.Years}, nil
+	}
+	return nil, fmt.Errorf("unknown weighting strategy %v", config.Strategy)
+}
+
+// popularityTrend compares the share of all the names that a name had in the older half of the periods with its
+// share in the recent half. It is the base 2 logarithm of how much the share changed, so it is positive for names
+// that are rising and negative for names that are falling.
+func popularityTrend(older int, olderTotal int, recent int, recentTotal int) float64 {
+	switch {
+	case older == 0 && recent == 0:
+		return 0
+	case older == 0:
+		return maxTrend
+	case recent == 0:
+		return -maxTrend
+	}
+	trend := math.Log2((float64(recent) / float64(recentTotal)) / (float64(older) / float64(olderTotal)))
+	return math.Max(-maxTrend, math.Min(maxTrend, trend))
+}
@@ -0,0 +1,204 @@
+// Copyright 2021 Robert D. Wukmir
+// This file is subject to the terms and conditions defined in
+// the LICENSE file, which is part of this source code package.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific
+// language governing permissions and limitations under the
+// License.
+
+package numerology
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWeighting_Weight(t *testing.T) {
+	oldest := WeightPeriod{File: "yob1990.txt", Year: 1990, Position: 0, Periods: 4, Age: 30}
+	newest := WeightPeriod{File: "yob2020.txt", Year: 2020, Position: 3, Periods: 4, Age: 0}
+	tests := []struct {
+		name       string
+		weighting  Weighting
+		wantOldest float64
+		wantNewest float64
+	}{
+		{"Linear", LinearWeighting{}, 0.25, 1},
+		{"Exponential", ExponentialWeighting{HalfLife: 10}, 0.125, 1},
+		{"Files", FileWeighting{map[string]float64{"yob1990.txt": 0.3}}, 0.3, 1},
+		{"Recent", RecentWeighting{Years: 20}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.weighting.Weight(oldest); got != tt.wantOldest {
+				t.Errorf("Weight() oldest = %v, want %v", got, tt.wantOldest)
+			}
+			if got := tt.weighting.Weight(newest); got != tt.wantNewest {
+				t.Errorf("Weight() newest = %v, want %v", got, tt.wantNewest)
+			}
+		})
+	}
+}
+
+func Test_readWeighting(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    Weighting
+		wantErr bool
+	}{
+		{"None", "", LinearWeighting{}, false},
+		{"Linear", `{"strategy": "linear"}`, LinearWeighting{}, false},
+		{"Exponential", `{"strategy": "exponential", "halfLife": 12.5}`, ExponentialWeighting{12.5}, false},
+		{"Files", `{"strategy": "files", "weights": {"a.csv": 0.5}}`, FileWeighting{map[string]float64{"a.csv": 0.5}}, false},
+		{"Recent", `{"strategy": "Recent", "years": 20}`, RecentWeighting{20}, false},
+		{"NoHalfLife", `{"strategy": "exponential"}`, nil, true},
+		{"NoYears", `{"strategy": "recent"}`, nil, true},
+		{"Unknown", `{"strategy": "quadratic"}`, nil, true},
+		{"InvalidJSON", `{"strategy": `, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.config != "" {
+				if err := os.WriteFile(filepath.Join(dir, weightingFile), []byte(tt.config), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := readWeighting(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readWeighting() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readWeighting() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A weighting that is set in code takes precedence over the file.
+	dir := filepath.Join(t.TempDir(), "weighted")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	Weightings["weighted"] = RecentWeighting{5}
+	defer delete(Weightings, "weighted")
+	if got, _ := readWeighting(dir); got != (RecentWeighting{5}) {
+		t.Errorf("readWeighting() = %v, want the weighting set in code", got)
+	}
+}
+
+func Test_popularityTrend(t *testing.T) {
+	tests := []struct {
+		name                                   string
+		older, olderTotal, recent, recentTotal int
+		want                                   float64
+	}{
+		{"Steady", 10, 100, 20, 200, 0},
+		{"Doubled", 10, 100, 40, 200, 1},
+		{"Halved", 20, 100, 10, 100, -1},
+		{"New", 0, 100, 10, 100, maxTrend},
+		{"Gone", 10, 100, 0, 100, -maxTrend},
+		{"Neither", 0, 100, 0, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := popularityTrend(tt.older, tt.olderTotal, tt.recent, tt.recentTotal); got != tt.want {
+				t.Errorf("popularityTrend() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readSourceFilesWeighting(t *testing.T) {
+	dir := t.TempDir()
+	writeSourceFile(t, dir, "yob2000.txt", "Mary,F,100\nEmma,F,10\nAnn,F,50\n")
+	writeSourceFile(t, dir, "yob2010.txt", "Mary,F,100\nEmma,F,60\nAnn,F,50\n")
+	writeSourceFile(t, dir, "yob2020.txt", "Mary,F,100\nEmma,F,100\nAnn,F,5\nZoe,F,20\n")
+	if err := os.WriteFile(filepath.Join(dir, weightingFile), []byte(`{"strategy": "exponential", "halfLife": 10}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	names, _, err := readSourceFiles(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type want struct {
+		count int
+		trend float64
+	}
+	got := map[string]want{}
+	for _, n := range names {
+		got[n.Name] = want{n.Popularity(), n.Trend}
+	}
+	// The counts of 2000 and 2010 are worth a quarter and a half. The trend compares 2000 with 2020.
+	wants := map[string]want{
+		"Mary": {175, popularityTrend(100, 160, 100, 225)},
+		"Emma": {133, popularityTrend(10, 160, 100, 225)},
+		"Ann":  {43, popularityTrend(50, 160, 5, 225)},
+		"Zoe":  {20, maxTrend},
+	}
+	if !reflect.DeepEqual(got, wants) {
+		t.Errorf("readSourceFiles() = %v, want %v", got, wants)
+	}
+}
+
+func Test_nameSearchTrend(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := filepath.Join(baseDir, "trend_test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeSourceFile(t, dir, "yob2000.txt", "Mary,F,100\nEmma,F,10\nAnn,F,50\nJohn,M,80\n")
+	writeSourceFile(t, dir, "yob2020.txt", "Mary,F,100\nEmma,F,100\nAnn,F,5\nJohn,M,80\nZoe,F,20\n")
+	dsn := "sqlite://file::memory:?cache=shared"
+	DB = nil
+	if err := CreateDatabase(dsn, baseDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateDatabase("memory://", baseDir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DB.Migrator().DropTable("trend_test")
+		DB.Where("dictionary = ?", "trend_test").Delete(&importLog{})
+		DB.Where("dictionary = ?", "trend_test").Delete(&dictionaryMetadata{})
+		delete(memoryDictionaries, "trend_test")
+		DB = nil
+	}()
+
+	tests := []struct {
+		name    string
+		trend   string
+		want    []string
+		wantErr bool
+	}{
+		{"Any", "", []string{"Mary", "John", "Emma", "Ann", "Zoe"}, false},
+		{"Trending", TrendingNames, []string{"Emma", "Zoe"}, false},
+		{"Classic", "Classic", []string{"Mary", "John"}, false},
+		{"Unknown", "vintage", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, database := range []string{dsn, "memory://"} {
+				opts := NameSearchOpts{Count: 10, Dictionary: "trend_test", Database: database, Trend: tt.trend}
+				results, _, err := nameSearch("?", Pythagorean, []int{}, true, opts)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("nameSearch() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				got := []string{}
+				for _, r := range results {
+					got = append(got, r.Name)
+				}
+				if tt.want == nil {
+					tt.want = []string{}
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("nameSearch() %v = %v, want %v", database, got, tt.want)
+				}
+			}
+		})
+	}
+}